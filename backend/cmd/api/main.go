@@ -14,8 +14,10 @@ import (
 	"github.com/macal/inventory/internal/config"
 	"github.com/macal/inventory/internal/handlers"
 	"github.com/macal/inventory/internal/middleware"
+	"github.com/macal/inventory/internal/notifications"
 	"github.com/macal/inventory/internal/repository"
 	"github.com/macal/inventory/internal/services"
+	"github.com/macal/inventory/internal/webhooks"
 	"github.com/macal/inventory/pkg/storage"
 	"go.uber.org/zap"
 )
@@ -50,10 +52,44 @@ func main() {
 		sugar.Fatalf("Failed to initialize storage: %v", err)
 	}
 
+	// Seed system form templates
+	if seeded, err := services.SeedPredefinedTemplates(db); err != nil {
+		sugar.Errorf("Failed to seed predefined templates: %v", err)
+	} else {
+		sugar.Infof("Seeded %d predefined templates", seeded)
+	}
+
+	// Initialize client notifications
+	webhookDispatcher := webhooks.NewDispatcher(db)
+	channels := []notifications.Channel{
+		notifications.NewInAppChannel(redisClient),
+		webhookDispatcher,
+	}
+	if cfg.SMTP.Host != "" {
+		channels = append(channels, notifications.NewSMTPChannel(cfg.SMTP))
+	}
+	notifier := notifications.NewNotifier(db, channels...)
+	if err := notifier.RegisterCallbacks(db); err != nil {
+		sugar.Fatalf("Failed to register notification callbacks: %v", err)
+	}
+
 	// Initialize services
 	vehicleService := services.NewVehicleService(db, redisClient, storageService)
-	inspectionService := services.NewInspectionService(db, redisClient, storageService)
+	inspectionService := services.NewInspectionService(db, redisClient, storageService, notifier)
 	authService := services.NewAuthService(db, redisClient)
+	reportScheduler := services.NewReportScheduler(db, redisClient, storageService, notifier)
+	inspectionPersister := inspectionService.Persister()
+	photoPipeline := services.NewPhotoPipeline(db, storageService, services.DefaultPhotoWorkers)
+
+	// Background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go reportScheduler.Run(workerCtx)
+	go webhookDispatcher.Run(workerCtx)
+	go inspectionPersister.Run(workerCtx)
+	go photoPipeline.Run(workerCtx)
+	go inspectionService.Hub().Run(workerCtx)
 
 	// Initialize handlers
 	handlers := handlers.NewHandlers(vehicleService, inspectionService, authService, sugar)
@@ -84,6 +120,7 @@ func main() {
 	<-quit
 
 	sugar.Info("Shutting down server...")
+	stopWorkers()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -93,6 +130,12 @@ func main() {
 		sugar.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Write pending inspection edits now that no more are accepted; anything
+	// left stays queued in Redis for the next start
+	if err := inspectionPersister.Flush(ctx); err != nil {
+		sugar.Errorf("Failed to flush inspections: %v", err)
+	}
+
 	sugar.Info("Server exited")
 }
 
@@ -131,9 +174,17 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *zap.SugaredLo
 			clientPortal.GET("/info", h.GetClientInfo)
 			clientPortal.GET("/vehicles", h.GetClientVehicles)
 			clientPortal.GET("/vehicles/:id", h.GetClientVehicle)
+			clientPortal.GET("/vehicles/:id/timeline", h.GetClientVehicleTimeline)
+			clientPortal.GET("/vehicles/:id/photos/:photoId", h.DownloadClientVehiclePhoto)
+			clientPortal.GET("/vehicles/:id/damage-map", h.GetClientVehicleDamageMap)
 			clientPortal.GET("/vehicles/:vehicleId/inspections/:inspectionId", h.GetClientVehicleInspection)
+			clientPortal.GET("/vehicles/:vehicleId/inspections/:inspectionId/pdf", h.DownloadClientInspectionPDF)
+			clientPortal.GET("/damage-catalogue", h.GetDamageCatalogue)
 			clientPortal.GET("/stats", h.GetClientStats)
 			clientPortal.GET("/reports/download", h.DownloadClientReport)
+			clientPortal.GET("/notifications", h.GetClientNotifications)
+			clientPortal.POST("/notifications/read-all", h.MarkAllClientNotificationsRead)
+			clientPortal.POST("/notifications/:id/read", h.MarkClientNotificationRead)
 		}
 
 		// Protected routes
@@ -150,6 +201,10 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *zap.SugaredLo
 				vehicles.DELETE("/:id", h.DeleteVehicle)
 				vehicles.POST("/:id/photos", h.UploadVehiclePhotos)
 				vehicles.GET("/:id/photos", h.GetVehiclePhotos)
+				vehicles.PUT("/:id/status", h.UpdateVehicleStatus)
+				vehicles.GET("/:id/status-history", h.GetVehicleStatusHistory)
+				vehicles.GET("/:id/timeline", h.GetVehicleTimeline)
+				vehicles.GET("/:id/damage-map", h.GetVehicleDamageMap)
 			}
 
 			// Inspection routes
@@ -159,26 +214,72 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *zap.SugaredLo
 				inspections.POST("", h.CreateInspection)
 				inspections.GET("/:id", h.GetInspection)
 				inspections.PUT("/:id", h.UpdateInspection)
+				inspections.POST("/:id/start", h.StartInspection)
+				inspections.POST("/:id/complete", h.CompleteInspection)
+				inspections.POST("/:id/approve", h.ApproveInspection)
+				inspections.POST("/:id/reject", h.RejectInspection)
+				inspections.POST("/:id/reopen", h.ReopenInspection)
+				inspections.GET("/:id/history", h.GetInspectionHistory)
 				inspections.GET("/:id/pdf", h.GenerateInspectionPDF)
+				inspections.GET("/:id/form", h.GetInspectionForm)
 				inspections.GET("/:id/ws", h.InspectionWebSocket)
+				inspections.GET("/:id/events", h.InspectionEvents)
+				inspections.POST("/:id/sync", h.SyncInspection)
+				inspections.POST("/:id/photos/uploads", h.CreatePhotoUpload)
+				inspections.POST("/:id/photos/uploads/:uploadId/confirm", h.ConfirmPhotoUpload)
+				inspections.DELETE("/:id/photos/uploads/:uploadId", h.AbortPhotoUpload)
+				inspections.GET("/:id/photos/:photoId/annotations", h.ListPhotoAnnotations)
+				inspections.POST("/:id/photos/:photoId/annotations", h.CreatePhotoAnnotation)
+				inspections.PUT("/:id/photos/:photoId/annotations/:annotationId", h.UpdatePhotoAnnotation)
+				inspections.DELETE("/:id/photos/:photoId/annotations/:annotationId", h.DeletePhotoAnnotation)
+				inspections.GET("/:id/photos/:photoId/annotated", h.GetAnnotatedPhoto)
+				inspections.GET("/:id/damages", h.ListInspectionDamages)
+				inspections.POST("/:id/damages", h.CreateInspectionDamage)
+				inspections.PUT("/:id/damages/:damageId", h.UpdateInspectionDamage)
+				inspections.DELETE("/:id/damages/:damageId", h.DeleteInspectionDamage)
 			}
 
+			// Write-behind persistence backlog
+			protected.GET("/system/persistence", middleware.RequireRole("admin"), h.GetPersistenceStats)
+
 			// Real-time inspection updates
 			protected.GET("/ws/inspection/:id", h.InspectionWebSocket)
 
+			// Body zones, damage types and severities of damage maps
+			protected.GET("/damage-catalogue", h.GetDamageCatalogue)
+
 			// Form template routes
 			formTemplates := protected.Group("/form-templates")
 			{
 				formTemplates.GET("", h.ListFormTemplates)
+				formTemplates.GET("/predefined", h.ListPredefinedTemplates)
+				formTemplates.GET("/predefined/:key", h.GetPredefinedTemplate)
+				formTemplates.POST("/predefined/:key/install", h.InstallPredefinedTemplate)
 				formTemplates.POST("", h.CreateFormTemplate)
 				formTemplates.GET("/:id", h.GetFormTemplate)
 				formTemplates.PUT("/:id", h.UpdateFormTemplate)
+				formTemplates.GET("/:id/versions", h.ListFormTemplateVersions)
+				formTemplates.GET("/:id/diff", h.DiffFormTemplateVersions)
+				formTemplates.POST("/:id/rollback", h.RollbackFormTemplate)
 				formTemplates.DELETE("/:id", h.DeleteFormTemplate)
 				formTemplates.POST("/:id/clone", h.CloneFormTemplate)
 				formTemplates.GET("/:id/export", h.ExportFormTemplate)
 				formTemplates.POST("/import", h.ImportFormTemplate)
 			}
 
+			// Template marketplace
+			marketplace := protected.Group("/marketplace")
+			{
+				marketplace.GET("", h.ListMarketplaceTemplates)
+				marketplace.POST("", h.PublishMarketplaceTemplate)
+				marketplace.GET("/:id", h.GetMarketplaceTemplate)
+				marketplace.DELETE("/:id", h.UnpublishMarketplaceTemplate)
+				marketplace.POST("/:id/install", h.InstallMarketplaceTemplate)
+				marketplace.GET("/:id/ratings", h.ListMarketplaceRatings)
+				marketplace.PUT("/:id/rating", h.RateMarketplaceTemplate)
+				marketplace.PUT("/:id/flags", middleware.RequireRole("admin"), h.UpdateMarketplaceFlags)
+			}
+
 			// Client management routes (admin only)
 			clients := protected.Group("/clients")
 			clients.Use(middleware.RequireRole("admin"))
@@ -189,6 +290,17 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *zap.SugaredLo
 				clients.DELETE("/:id", h.DeleteClient)
 				clients.POST("/:id/regenerate-token", h.RegenerateClientToken)
 				clients.GET("/:id/access-logs", h.GetClientAccessLogs)
+				clients.GET("/:id/reports", h.ListClientReports)
+				clients.POST("/:id/reports", h.CreateClientReport)
+				clients.PUT("/:id/reports/:reportId", h.UpdateClientReport)
+				clients.DELETE("/:id/reports/:reportId", h.DeleteClientReport)
+				clients.GET("/:id/webhooks", h.ListClientWebhooks)
+				clients.POST("/:id/webhooks", h.CreateClientWebhook)
+				clients.PUT("/:id/webhooks/:webhookId", h.UpdateClientWebhook)
+				clients.DELETE("/:id/webhooks/:webhookId", h.DeleteClientWebhook)
+				clients.GET("/:id/webhooks/deliveries", h.GetClientWebhookDeliveries)
+				clients.GET("/:id/webhooks/dead-letters", h.GetClientWebhookDeadLetters)
+				clients.POST("/:id/webhooks/deliveries/:deliveryId/replay", h.ReplayClientWebhookDelivery)
 			}
 		}
 	}
@@ -13,6 +13,7 @@ type Config struct {
 	Storage  StorageConfig
 	JWT      JWTConfig
 	CORS     CORSConfig
+	SMTP     SMTPConfig
 }
 
 type ServerConfig struct {
@@ -52,6 +53,14 @@ type JWTConfig struct {
 	RefreshExpiry    int // days
 }
 
+type SMTPConfig struct {
+	Host     string // empty disables email delivery
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
 type CORSConfig struct {
 	AllowedOrigins []string
 	AllowedMethods []string
@@ -97,6 +106,13 @@ func Load() *Config {
 			AllowedMethods: getEnvAsSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"}),
 			AllowedHeaders: getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-Requested-With"}),
 		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnvAsInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "MACAL Inventario <no-reply@macal.cl>"),
+		},
 	}
 }
 
@@ -1,13 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/reports"
+	"github.com/macal/inventory/internal/services"
 )
 
 // Client Portal Handlers (for external clients)
@@ -103,31 +105,7 @@ func (h *Handlers) GetClientVehicles(c *gin.Context) {
 	query := h.db.Model(&models.Vehicle{}).Preload("Owner")
 	
 	// Apply vehicle filters
-	filters := client.Permissions.VehicleFilters
-	
-	if len(filters.VehicleIDs) > 0 {
-		query = query.Where("id IN ?", filters.VehicleIDs)
-	}
-	
-	if len(filters.OwnerIDs) > 0 {
-		query = query.Where("owner_id IN ?", filters.OwnerIDs)
-	}
-	
-	if len(filters.LicensePlates) > 0 {
-		query = query.Where("license_plate IN ?", filters.LicensePlates)
-	}
-	
-	if len(filters.Statuses) > 0 {
-		query = query.Where("status IN ?", filters.Statuses)
-	}
-	
-	if filters.DateFrom != nil {
-		query = query.Where("check_in_date >= ?", filters.DateFrom)
-	}
-	
-	if filters.DateTo != nil {
-		query = query.Where("check_in_date <= ?", filters.DateTo)
-	}
+	query = client.Permissions.VehicleFilters.Apply(query)
 	
 	// Apply additional filters from query params
 	if status := c.Query("status"); status != "" {
@@ -154,7 +132,7 @@ func (h *Handlers) GetClientVehicles(c *gin.Context) {
 	}
 	
 	// Filter fields based on permissions
-	filteredVehicles := h.filterVehicleFields(vehicles, client.Permissions)
+	filteredVehicles := h.filterVehicleFields(c, vehicles, client)
 	
 	// Log access
 	h.logClientAccess(c, client, "view_vehicles", "vehicle", "list", http.StatusOK)
@@ -194,7 +172,7 @@ func (h *Handlers) GetClientVehicle(c *gin.Context) {
 	}
 	
 	// Filter fields
-	filteredVehicle := h.filterVehicleFields([]models.Vehicle{vehicle}, client.Permissions)[0]
+	filteredVehicle := h.filterVehicleFields(c, []models.Vehicle{vehicle}, client)[0]
 	
 	// Log access
 	h.logClientAccess(c, client, "view_vehicle", "vehicle", vehicleID, http.StatusOK)
@@ -232,6 +210,13 @@ func (h *Handlers) GetClientVehicleInspection(c *gin.Context) {
 		return
 	}
 	
+	// Links expire with the request and never outlive the client's access
+	if err := h.inspectionService.MediaLinks().SignInspection(c.Request.Context(), &inspection, client); err != nil {
+		h.logger.Errorf("Failed to sign links of inspection %s: %v", inspectionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inspection"})
+		return
+	}
+	
 	// Log access
 	h.logClientAccess(c, client, "view_inspection", "inspection", inspectionID, http.StatusOK)
 	
@@ -294,44 +279,70 @@ func (h *Handlers) DownloadClientReport(c *gin.Context) {
 	}
 	
 	reportType := c.Query("type")
-	format := c.Query("format") // pdf, excel, csv
-	
-	// Generate report based on type
-	var reportData []byte
-	var filename string
-	var contentType string
-	
-	switch format {
-	case "pdf":
-		// Generate PDF report
-		contentType = "application/pdf"
-		filename = "reporte-vehiculos.pdf"
-		// reportData = generatePDFReport(...)
-		
-	case "excel":
-		// Generate Excel report
-		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
-		filename = "reporte-vehiculos.xlsx"
-		// reportData = generateExcelReport(...)
-		
-	case "csv":
-		// Generate CSV report
-		contentType = "text/csv"
-		filename = "reporte-vehiculos.csv"
-		// reportData = generateCSVReport(...)
-		
-	default:
+	format := reports.Format(c.Query("format")) // pdf, excel, csv
+	
+	if !format.Valid() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
 		return
 	}
 	
+	// Additional filters from query params, same as the vehicle list
+	opts := reports.Options{Status: c.Query("status")}
+	if dateFrom := c.Query("dateFrom"); dateFrom != "" {
+		t, err := time.Parse("2006-01-02", dateFrom)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dateFrom"})
+			return
+		}
+		opts.DateFrom = &t
+	}
+	if dateTo := c.Query("dateTo"); dateTo != "" {
+		t, err := time.Parse("2006-01-02", dateTo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dateTo"})
+			return
+		}
+		opts.DateTo = &t
+	}
+	
+	report, err := reports.New(h.db, client, reportType, opts)
+	if errors.Is(err, reports.ErrNotPermitted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to download this report"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report type"})
+		return
+	}
+	
 	// Log access
 	h.logClientAccess(c, client, "download_report", "report", reportType, http.StatusOK)
 	
-	// Send file
-	c.Header("Content-Type", contentType)
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Data(http.StatusOK, contentType, reportData)
+	// Stream file, rows are written as they are read from the database. The
+	// server's write timeout is extended batch by batch so large exports are
+	// not cut off mid-file.
+	report.Progress = extendWriteDeadline(c, downloadWriteWait)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", "attachment; filename="+report.Filename(format))
+	c.Status(http.StatusOK)
+	
+	if err := report.Write(c.Request.Context(), format, c.Writer); err != nil {
+		// Headers are already sent, the download will be truncated
+		h.logger.Errorf("Failed to generate %s report for client %s: %v", format, client.ID, err)
+	}
+}
+
+// downloadWriteWait is how long a download may stall before the connection
+// is dropped. Downloads extend the server's write timeout as they progress.
+const downloadWriteWait = 30 * time.Second
+
+// extendWriteDeadline returns a function that pushes the response's write
+// deadline wait into the future
+func extendWriteDeadline(c *gin.Context, wait time.Duration) func() {
+	rc := http.NewResponseController(c.Writer)
+	return func() {
+		rc.SetWriteDeadline(time.Now().Add(wait))
+	}
 }
 
 // Admin handlers for managing clients
@@ -461,66 +472,12 @@ func (h *Handlers) GetClientAccessLogs(c *gin.Context) {
 
 // Helper functions
 
-func (h *Handlers) filterVehicleFields(vehicles []models.Vehicle, permissions models.ClientPermissions) []map[string]interface{} {
+func (h *Handlers) filterVehicleFields(c *gin.Context, vehicles []models.Vehicle, client *models.ClientOrganization) []map[string]interface{} {
+	sign := h.inspectionService.MediaLinks().Signer(c.Request.Context(), client.LinkExpiry(services.ClientLinkExpiry))
 	filtered := make([]map[string]interface{}, len(vehicles))
-	
-	for i, vehicle := range vehicles {
-		v := make(map[string]interface{})
-		
-		// Always include basic fields
-		v["id"] = vehicle.ID
-		v["licensePlate"] = vehicle.LicensePlate
-		v["make"] = vehicle.Make
-		v["model"] = vehicle.Model
-		v["year"] = vehicle.Year
-		v["status"] = vehicle.Status
-		v["checkInDate"] = vehicle.CheckInDate
-		
-		// Conditionally include fields based on permissions
-		if !contains(permissions.HiddenFields, "vin") {
-			v["vin"] = vehicle.VIN
-		}
-		if !contains(permissions.HiddenFields, "mileage") {
-			v["mileage"] = vehicle.Mileage
-		}
-		if !contains(permissions.HiddenFields, "color") {
-			v["color"] = vehicle.Color
-		}
-		if vehicle.CheckOutDate != nil && !contains(permissions.HiddenFields, "checkOutDate") {
-			v["checkOutDate"] = vehicle.CheckOutDate
-		}
-		
-		// Owner info
-		if permissions.CanViewOwnerInfo && vehicle.Owner != nil {
-			v["owner"] = map[string]interface{}{
-				"id": vehicle.Owner.ID,
-				"name": vehicle.Owner.Name,
-				"companyName": vehicle.Owner.CompanyName,
-			}
-		}
-		
-		// Photos
-		if permissions.CanViewPhotos && len(vehicle.Photos) > 0 {
-			photos := make([]map[string]interface{}, len(vehicle.Photos))
-			for j, photo := range vehicle.Photos {
-				photos[j] = map[string]interface{}{
-					"id": photo.ID,
-					"url": photo.URL,
-					"thumbnail": photo.Thumbnail,
-					"category": photo.Category,
-				}
-			}
-			v["photos"] = photos
-		}
-		
-		// Inspections count
-		if permissions.CanViewInspections {
-			v["inspectionsCount"] = len(vehicle.Inspections)
-		}
-		
-		filtered[i] = v
+	for i := range vehicles {
+		filtered[i] = client.Permissions.VehicleView(&vehicles[i], sign)
 	}
-	
 	return filtered
 }
 
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+)
+
+// DownloadClientVehiclePhoto streams a processed rendition of a vehicle
+// photo through the API, checking access on every request instead of
+// handing out a link. ?size= selects display (default), medium or thumbnail;
+// ?annotated=true returns inspection photos with their annotations drawn.
+func (h *Handlers) DownloadClientVehiclePhoto(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+	vehicleID := c.Param("id")
+	photoID := c.Param("photoId")
+
+	if !client.Permissions.CanViewPhotos {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view photos"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	if !client.CanAccessVehicle(&vehicle) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this vehicle"})
+		return
+	}
+
+	var photo models.VehiclePhoto
+	if err := h.db.First(&photo, "id = ? AND vehicle_id = ?", photoID, vehicle.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	// Same rules as the portal listings: only processed renditions, and
+	// inspection photos need the inspection permission
+	if !photo.ClientVisible() || (photo.InspectionID != nil && !client.Permissions.CanViewInspections) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+
+	var (
+		url     string
+		maxSide int
+	)
+	switch c.DefaultQuery("size", "display") {
+	case "display":
+		url, maxSide = photo.Display, services.AnnotatedPhotoMaxSide
+	case "medium":
+		url, maxSide = photo.Medium, 1024
+	case "thumbnail":
+		url, maxSide = photo.Thumbnail, 320
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size, expected display, medium or thumbnail"})
+		return
+	}
+
+	h.logClientAccess(c, client, "download_photo", "photo", photoID, http.StatusOK)
+
+	if c.Query("annotated") == "true" && photo.InspectionID != nil {
+		data, err := h.inspectionService.AnnotatedPhoto(c.Request.Context(), *photo.InspectionID, photo.ID, maxSide)
+		if err != nil {
+			h.logger.Errorf("Failed to render annotations of photo %s: %v", photo.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", photo.ID.String()+".jpg"))
+		c.Header("Cache-Control", "private, no-store")
+		c.Data(http.StatusOK, "image/jpeg", data)
+		return
+	}
+
+	h.streamMedia(c, url, "inline", photo.ID.String())
+}
+
+// DownloadClientInspectionPDF streams the PDF report of an inspection. The
+// report embeds the inspection photos, so it needs the photo permission too.
+func (h *Handlers) DownloadClientInspectionPDF(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+	vehicleID := c.Param("vehicleId")
+	inspectionID := c.Param("inspectionId")
+
+	if !client.Permissions.CanViewInspections || !client.Permissions.CanViewPhotos {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to download inspection reports"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+	if !client.CanAccessVehicle(&vehicle) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this vehicle"})
+		return
+	}
+
+	var inspection models.Inspection
+	if err := h.db.Select("id", "pdf_url").First(&inspection, "id = ? AND vehicle_id = ?", inspectionID, vehicle.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+
+	h.logClientAccess(c, client, "download_inspection_pdf", "inspection", inspectionID, http.StatusOK)
+
+	h.streamMedia(c, inspection.PDFUrl, "attachment", fmt.Sprintf("inspeccion_%s.pdf", inspection.ID))
+}
+
+// streamMedia copies a stored object to the response without buffering it
+func (h *Handlers) streamMedia(c *gin.Context, url, disposition, filename string) {
+	reader, info, err := h.inspectionService.MediaLinks().Open(c.Request.Context(), url)
+	if errors.Is(err, services.ErrMediaNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not available"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to open %s: %v", url, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file"})
+		return
+	}
+	defer reader.Close()
+
+	// Large PDFs outlast the server's write timeout; extend it per chunk
+	progress := &progressReader{reader: reader, progress: extendWriteDeadline(c, downloadWriteWait)}
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, progress, map[string]string{
+		"Content-Disposition": fmt.Sprintf("%s; filename=%q", disposition, filename),
+		"Cache-Control":       "private, no-store",
+	})
+}
+
+// progressReader calls progress before every read
+type progressReader struct {
+	reader   io.Reader
+	progress func()
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	r.progress()
+	return r.reader.Read(p)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/macal/inventory/internal/models"
+)
+
+// GetClientNotifications returns the client's notification inbox
+func (h *Handlers) GetClientNotifications(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := h.db.Where("organization_id = ?", client.ID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read = ?", false)
+	}
+
+	var notifications []models.ClientNotification
+	if err := query.Order("sent_at DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	var unread int64
+	h.db.Model(&models.ClientNotification{}).
+		Where("organization_id = ? AND read = ?", client.ID, false).
+		Count(&unread)
+
+	h.logClientAccess(c, client, "view_notifications", "notification", "list", http.StatusOK)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"count":         len(notifications),
+		"unread":        unread,
+	})
+}
+
+// MarkClientNotificationRead marks a single notification as read
+func (h *Handlers) MarkClientNotificationRead(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+	notificationID := c.Param("id")
+
+	var notification models.ClientNotification
+	if err := h.db.First(&notification, "id = ? AND organization_id = ?", notificationID, client.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if !notification.Read {
+		now := time.Now()
+		notification.Read = true
+		notification.ReadAt = &now
+		if err := h.db.Model(&notification).Updates(map[string]interface{}{"read": true, "read_at": now}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllClientNotificationsRead marks every unread notification as read
+func (h *Handlers) MarkAllClientNotificationsRead(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+
+	result := h.db.Model(&models.ClientNotification{}).
+		Where("organization_id = ? AND read = ?", client.ID, false).
+		Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// notificationInbox serves the client portal notification routes for one
+// organization over an in-memory database
+type notificationInbox struct {
+	db     *gorm.DB
+	router *gin.Engine
+	org    *models.ClientOrganization
+	other  *models.ClientOrganization
+}
+
+func newNotificationInbox(t *testing.T) *notificationInbox {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// One connection, so the access log goroutines share the database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.ClientNotification{}, &models.ClientAccessLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	inbox := &notificationInbox{
+		db:    db,
+		org:   &models.ClientOrganization{ID: uuid.New(), Name: "Banco Uno"},
+		other: &models.ClientOrganization{ID: uuid.New(), Name: "Seguros Dos"},
+	}
+	h := &Handlers{db: db, logger: zap.NewNop().Sugar()}
+
+	gin.SetMode(gin.TestMode)
+	inbox.router = gin.New()
+	portal := inbox.router.Group("/client", func(c *gin.Context) {
+		c.Set("client", inbox.org)
+	})
+	portal.GET("/notifications", h.GetClientNotifications)
+	portal.POST("/notifications/read-all", h.MarkAllClientNotificationsRead)
+	portal.POST("/notifications/:id/read", h.MarkClientNotificationRead)
+	return inbox
+}
+
+func (in *notificationInbox) add(t *testing.T, org *models.ClientOrganization, title string, sentAt time.Time, read bool) *models.ClientNotification {
+	t.Helper()
+	n := &models.ClientNotification{
+		OrganizationID: org.ID,
+		Type:           models.NotificationVehicleAdded,
+		Title:          title,
+		SentAt:         sentAt,
+		Read:           read,
+	}
+	if err := in.db.Create(n).Error; err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+	return n
+}
+
+func (in *notificationInbox) do(t *testing.T, method, path string, out interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	in.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+	return w.Code
+}
+
+type inboxResponse struct {
+	Notifications []models.ClientNotification `json:"notifications"`
+	Count         int                         `json:"count"`
+	Unread        int64                       `json:"unread"`
+}
+
+func TestGetClientNotifications(t *testing.T) {
+	in := newNotificationInbox(t)
+	now := time.Now().Truncate(time.Second)
+	in.add(t, in.org, "oldest", now.Add(-2*time.Hour), true)
+	in.add(t, in.org, "newest", now, false)
+	in.add(t, in.org, "middle", now.Add(-time.Hour), false)
+	in.add(t, in.other, "someone else's", now, false)
+
+	tests := []struct {
+		name   string
+		query  string
+		titles []string
+	}{
+		{name: "newest first", query: "", titles: []string{"newest", "middle", "oldest"}},
+		{name: "unread only", query: "?unread=true", titles: []string{"newest", "middle"}},
+		{name: "paged", query: "?limit=1&offset=1", titles: []string{"middle"}},
+		{name: "limit out of range", query: "?limit=500", titles: []string{"newest", "middle", "oldest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp inboxResponse
+			if code := in.do(t, http.MethodGet, "/client/notifications"+tt.query, &resp); code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", code)
+			}
+			var titles []string
+			for _, n := range resp.Notifications {
+				titles = append(titles, n.Title)
+			}
+			if len(titles) != len(tt.titles) {
+				t.Fatalf("titles = %v, want %v", titles, tt.titles)
+			}
+			for i := range titles {
+				if titles[i] != tt.titles[i] {
+					t.Fatalf("titles = %v, want %v", titles, tt.titles)
+				}
+			}
+			if resp.Count != len(tt.titles) {
+				t.Errorf("count = %d, want %d", resp.Count, len(tt.titles))
+			}
+			if resp.Unread != 2 {
+				t.Errorf("unread = %d, want 2 regardless of paging", resp.Unread)
+			}
+		})
+	}
+}
+
+func TestMarkClientNotificationRead(t *testing.T) {
+	in := newNotificationInbox(t)
+	mine := in.add(t, in.org, "mine", time.Now(), false)
+	theirs := in.add(t, in.other, "theirs", time.Now(), false)
+
+	var marked models.ClientNotification
+	if code := in.do(t, http.MethodPost, "/client/notifications/"+mine.ID.String()+"/read", &marked); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if !marked.Read || marked.ReadAt == nil {
+		t.Errorf("response read=%v read_at=%v, want read with a time", marked.Read, marked.ReadAt)
+	}
+
+	// Marking again keeps the first read time
+	var again models.ClientNotification
+	in.do(t, http.MethodPost, "/client/notifications/"+mine.ID.String()+"/read", &again)
+	if again.ReadAt == nil || !again.ReadAt.Equal(*marked.ReadAt) {
+		t.Errorf("read_at changed from %v to %v", marked.ReadAt, again.ReadAt)
+	}
+
+	if code := in.do(t, http.MethodPost, "/client/notifications/"+theirs.ID.String()+"/read", nil); code != http.StatusNotFound {
+		t.Errorf("marking another organization's notification: status = %d, want 404", code)
+	}
+	var stored models.ClientNotification
+	in.db.First(&stored, "id = ?", theirs.ID)
+	if stored.Read {
+		t.Error("another organization's notification was marked read")
+	}
+}
+
+func TestMarkAllClientNotificationsRead(t *testing.T) {
+	in := newNotificationInbox(t)
+	in.add(t, in.org, "read", time.Now(), true)
+	in.add(t, in.org, "unread 1", time.Now(), false)
+	in.add(t, in.org, "unread 2", time.Now(), false)
+	in.add(t, in.other, "theirs", time.Now(), false)
+
+	var resp struct {
+		Updated int64 `json:"updated"`
+	}
+	if code := in.do(t, http.MethodPost, "/client/notifications/read-all", &resp); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if resp.Updated != 2 {
+		t.Errorf("updated = %d, want 2", resp.Updated)
+	}
+
+	var unread int64
+	in.db.Model(&models.ClientNotification{}).Where("read = ?", false).Count(&unread)
+	if unread != 1 {
+		t.Errorf("%d notifications left unread, want only the other organization's", unread)
+	}
+}
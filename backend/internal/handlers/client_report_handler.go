@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/reports"
+)
+
+// Admin handlers for scheduled client reports
+
+// ListClientReports returns the reports configured for a client
+func (h *Handlers) ListClientReports(c *gin.Context) {
+	clientID := c.Param("id")
+
+	var clientReports []models.ClientReport
+	if err := h.db.Where("organization_id = ?", clientID).Order("created_at DESC").Find(&clientReports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": clientReports,
+		"count":   len(clientReports),
+	})
+}
+
+// CreateClientReport configures a new report for a client
+func (h *Handlers) CreateClientReport(c *gin.Context) {
+	clientID := c.Param("id")
+
+	var client models.ClientOrganization
+	if err := h.db.First(&client, "id = ?", clientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var input struct {
+		Name     string       `json:"name" binding:"required"`
+		Type     string       `json:"type" binding:"required"`
+		Config   models.JSONB `json:"config"`
+		Schedule string       `json:"schedule" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateClientReport(input.Type, input.Schedule, input.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report configuration", "details": err.Error()})
+		return
+	}
+
+	report := models.ClientReport{
+		OrganizationID: client.ID,
+		Name:           input.Name,
+		Type:           input.Type,
+		Config:         input.Config,
+		Schedule:       input.Schedule,
+		Active:         true,
+	}
+
+	if err := h.db.Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// UpdateClientReport updates a client report
+func (h *Handlers) UpdateClientReport(c *gin.Context) {
+	clientID := c.Param("id")
+	reportID := c.Param("reportId")
+
+	var report models.ClientReport
+	if err := h.db.First(&report, "id = ? AND organization_id = ?", reportID, clientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	var input struct {
+		Name     *string      `json:"name"`
+		Type     *string      `json:"type"`
+		Config   models.JSONB `json:"config"`
+		Schedule *string      `json:"schedule"`
+		Active   *bool        `json:"active"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		report.Name = *input.Name
+	}
+	if input.Type != nil {
+		report.Type = *input.Type
+	}
+	if input.Config != nil {
+		report.Config = input.Config
+	}
+	if input.Schedule != nil {
+		report.Schedule = *input.Schedule
+	}
+	if input.Active != nil {
+		report.Active = *input.Active
+	}
+
+	if err := validateClientReport(report.Type, report.Schedule, report.Config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report configuration", "details": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteClientReport removes a client report
+func (h *Handlers) DeleteClientReport(c *gin.Context) {
+	clientID := c.Param("id")
+	reportID := c.Param("reportId")
+
+	result := h.db.Where("id = ? AND organization_id = ?", reportID, clientID).Delete(&models.ClientReport{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report deleted successfully"})
+}
+
+func validateClientReport(reportType, schedule string, config models.JSONB) error {
+	if !reports.ValidType(reportType) {
+		return reports.ErrUnknownType
+	}
+
+	switch schedule {
+	case models.ReportScheduleDaily, models.ReportScheduleWeekly, models.ReportScheduleMonthly, models.ReportScheduleOnDemand:
+	default:
+		return errors.New("schedule must be one of daily, weekly, monthly or on_demand")
+	}
+
+	_, err := reports.ParseConfig(config)
+	return err
+}
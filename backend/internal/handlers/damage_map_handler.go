@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+	"gorm.io/gorm"
+)
+
+// GetDamageCatalogue returns the body zones, damage types and severities a
+// damage may be recorded with
+func (h *Handlers) GetDamageCatalogue(c *gin.Context) {
+	c.JSON(http.StatusOK, models.GetDamageCatalogue())
+}
+
+// ListInspectionDamages returns the damage recorded during an inspection
+func (h *Handlers) ListInspectionDamages(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	if err := h.db.Select("id").First(&models.Inspection{}, "id = ?", inspectionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+
+	damages, err := h.inspectionService.InspectionDamages(c.Request.Context(), inspectionID)
+	if err == nil {
+		err = h.inspectionService.MediaLinks().SignDamages(c.Request.Context(), damages, nil)
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to load damage of inspection %s: %v", inspectionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load damage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"inspection_id": inspectionID,
+		"damages":       damages,
+	})
+}
+
+// CreateInspectionDamage records a damage on a body zone, optionally linked
+// to photos and annotations of the inspection
+func (h *Handlers) CreateInspectionDamage(c *gin.Context) {
+	inspectionID, userID, ok := damageContext(c)
+	if !ok {
+		return
+	}
+
+	var input models.DamageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	damage, err := h.inspectionService.RecordDamage(c.Request.Context(), inspectionID, userID, &input)
+	if err != nil {
+		h.respondDamageError(c, err)
+		return
+	}
+
+	h.respondDamage(c, http.StatusCreated, damage)
+}
+
+// UpdateInspectionDamage replaces a damage record
+func (h *Handlers) UpdateInspectionDamage(c *gin.Context) {
+	inspectionID, userID, ok := damageContext(c)
+	if !ok {
+		return
+	}
+	damageID, err := uuid.Parse(c.Param("damageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid damage ID"})
+		return
+	}
+
+	var input models.DamageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	damage, err := h.inspectionService.UpdateDamage(c.Request.Context(), inspectionID, damageID, userID, &input)
+	if err != nil {
+		h.respondDamageError(c, err)
+		return
+	}
+
+	h.respondDamage(c, http.StatusOK, damage)
+}
+
+// DeleteInspectionDamage removes a damage record
+func (h *Handlers) DeleteInspectionDamage(c *gin.Context) {
+	inspectionID, userID, ok := damageContext(c)
+	if !ok {
+		return
+	}
+	damageID, err := uuid.Parse(c.Param("damageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid damage ID"})
+		return
+	}
+
+	if err := h.inspectionService.RemoveDamage(c.Request.Context(), inspectionID, damageID, userID); err != nil {
+		h.respondDamageError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetVehicleDamageMap returns the damage of a vehicle zone by zone, flagging
+// what is new at exit compared with entry
+func (h *Handlers) GetVehicleDamageMap(c *gin.Context) {
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	h.respondDamageMap(c, &vehicle, nil)
+}
+
+// GetClientVehicleDamageMap returns the damage map of a vehicle. Damage is
+// found by inspections, so it needs the inspection permission; photos are
+// only linked with the photo permission.
+func (h *Handlers) GetClientVehicleDamageMap(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+	vehicleID := c.Param("id")
+
+	if !client.Permissions.CanViewVehicles || !client.Permissions.CanViewInspections {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view inspections"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	if !client.CanAccessVehicle(&vehicle) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this vehicle"})
+		return
+	}
+
+	// Log access
+	h.logClientAccess(c, client, "view_vehicle_damage_map", "vehicle", vehicleID, http.StatusOK)
+
+	h.respondDamageMap(c, &vehicle, client)
+}
+
+func (h *Handlers) respondDamageMap(c *gin.Context, vehicle *models.Vehicle, client *models.ClientOrganization) {
+	damageMap, err := h.inspectionService.VehicleDamageMap(c.Request.Context(), vehicle.ID)
+	if err == nil {
+		err = h.inspectionService.MediaLinks().SignDamageMap(c.Request.Context(), damageMap, client)
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to build damage map of vehicle %s: %v", vehicle.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load damage map"})
+		return
+	}
+
+	c.JSON(http.StatusOK, damageMap)
+}
+
+func (h *Handlers) respondDamage(c *gin.Context, status int, damage *models.InspectionDamage) {
+	damages := []models.InspectionDamage{*damage}
+	if err := h.inspectionService.MediaLinks().SignDamages(c.Request.Context(), damages, nil); err != nil {
+		// The damage is saved; only its photo links are missing
+		h.logger.Errorf("Failed to sign photos of damage %s: %v", damage.ID, err)
+	}
+	c.JSON(status, damages[0])
+}
+
+func damageContext(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return inspectionID, userID, true
+}
+
+func (h *Handlers) respondDamageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+	case errors.Is(err, services.ErrDamageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidDamage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInspectionNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Errorf("Damage map update failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update damage map"})
+	}
+}
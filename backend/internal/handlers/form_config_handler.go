@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -67,7 +71,7 @@ func (h *Handlers) CreateFormTemplate(c *gin.Context) {
 	template := models.FormTemplate{
 		Name:      input.Name,
 		Type:      input.Type,
-		Config:    models.JSONB(input.Config),
+		Config:    input.Config.ToJSONB(),
 		CreatedBy: uuid.MustParse(userID),
 		Active:    true,
 		Version:   1,
@@ -98,7 +102,7 @@ func (h *Handlers) UpdateFormTemplate(c *gin.Context) {
 	var input struct {
 		Name   string             `json:"name"`
 		Config models.FormConfig  `json:"config"`
-		Active bool               `json:"active"`
+		Active *bool              `json:"active"`
 	}
 	
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -107,42 +111,38 @@ func (h *Handlers) UpdateFormTemplate(c *gin.Context) {
 	}
 	
 	// Validate configuration if provided
+	config := template.Config
 	if input.Config.Sections != nil {
 		if err := validateFormConfig(input.Config); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form configuration", "details": err.Error()})
 			return
 		}
+		config = input.Config.ToJSONB()
+	}
+	
+	name := input.Name
+	if name == "" {
+		name = template.Name
+	}
+	active := true
+	if input.Active != nil {
+		active = *input.Active
 	}
 	
 	// Create new version instead of updating (versioning)
 	newTemplate := models.FormTemplate{
-		Name:      input.Name,
+		Name:      name,
 		Type:      template.Type,
-		Config:    models.JSONB(input.Config),
+		Config:    config,
 		CreatedBy: uuid.MustParse(userID),
-		Active:    input.Active,
-		Version:   template.Version + 1,
+		Active:    active,
 	}
 	
-	// Begin transaction
-	tx := h.db.Begin()
-	
-	// Deactivate old version
-	if err := tx.Model(&template).Update("active", false).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
-		return
-	}
-	
-	// Create new version
-	if err := tx.Create(&newTemplate).Error; err != nil {
-		tx.Rollback()
+	if err := createTemplateVersion(h.db, &template, &newTemplate); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create new version"})
 		return
 	}
 	
-	tx.Commit()
-	
 	// Update cache
 	h.cacheFormTemplate(&newTemplate)
 	
@@ -248,7 +248,7 @@ func (h *Handlers) ImportFormTemplate(c *gin.Context) {
 	template := models.FormTemplate{
 		Name:      input.Name + " (Imported)",
 		Type:      input.Type,
-		Config:    models.JSONB(input.Config),
+		Config:    input.Config.ToJSONB(),
 		CreatedBy: uuid.MustParse(userID),
 		Active:    true,
 		Version:   1,
@@ -310,5 +310,6 @@ func validateFormConfig(config models.FormConfig) error {
 		}
 	}
 	
-	return nil
+	// Validate section and field conditions: operators, references and cycles
+	return config.ValidateConditions()
 }
\ No newline at end of file
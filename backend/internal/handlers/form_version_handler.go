@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Form template version history
+
+// ListFormTemplateVersions returns every version of a template's family,
+// newest first
+func (h *Handlers) ListFormTemplateVersions(c *gin.Context) {
+	id := c.Param("id")
+
+	var template models.FormTemplate
+	if err := h.db.First(&template, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	var versions []models.FormTemplate
+	err := familyQuery(h.db, template.Family()).
+		Select("id", "family_id", "name", "type", "version", "active", "created_by", "created_at").
+		Order("version DESC").
+		Find(&versions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"family_id": template.Family(),
+		"versions":  versions,
+		"count":     len(versions),
+	})
+}
+
+// DiffFormTemplateVersions compares two versions of a template family. By
+// default the given version is compared with the one before it; the "from"
+// and "to" query parameters select version numbers explicitly.
+func (h *Handlers) DiffFormTemplateVersions(c *gin.Context) {
+	id := c.Param("id")
+
+	var template models.FormTemplate
+	if err := h.db.First(&template, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	toVersion := template.Version
+	if v := c.Query("to"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to version"})
+			return
+		}
+		toVersion = parsed
+	}
+	fromVersion := toVersion - 1
+	if v := c.Query("from"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from version"})
+			return
+		}
+		fromVersion = parsed
+	}
+
+	var from, to models.FormTemplate
+	if err := familyQuery(h.db, template.Family()).First(&from, "version = ?", fromVersion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version " + strconv.Itoa(fromVersion) + " not found"})
+		return
+	}
+	if err := familyQuery(h.db, template.Family()).First(&to, "version = ?", toVersion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version " + strconv.Itoa(toVersion) + " not found"})
+		return
+	}
+
+	fromConfig, err := from.ParseConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form configuration in version " + strconv.Itoa(fromVersion)})
+		return
+	}
+	toConfig, err := to.ParseConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form configuration in version " + strconv.Itoa(toVersion)})
+		return
+	}
+
+	diff := models.DiffFormConfigs(fromConfig, toConfig)
+	c.JSON(http.StatusOK, gin.H{
+		"family_id": template.Family(),
+		"from":      gin.H{"id": from.ID, "version": from.Version, "name": from.Name},
+		"to":        gin.H{"id": to.ID, "version": to.Version, "name": to.Name},
+		"identical": diff.Empty() && from.Name == to.Name,
+		"diff":      diff,
+	})
+}
+
+// RollbackFormTemplate restores a previous version by copying it into a new
+// active head, so the history stays append-only
+func (h *Handlers) RollbackFormTemplate(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.GetString("userID")
+
+	var target models.FormTemplate
+	if err := h.db.First(&target, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	head := models.FormTemplate{
+		Name:      target.Name,
+		Type:      target.Type,
+		Config:    target.Config,
+		CreatedBy: uuid.MustParse(userID),
+		Active:    true,
+	}
+
+	if err := createTemplateVersion(h.db, &target, &head); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back template"})
+		return
+	}
+
+	h.cacheFormTemplate(&head)
+
+	c.JSON(http.StatusOK, gin.H{
+		"template":      head,
+		"restored_from": target.Version,
+	})
+}
+
+// createTemplateVersion appends head to base's family as the next version.
+// Family rows are locked so concurrent edits get distinct version numbers;
+// an active head deactivates every other version.
+func createTemplateVersion(db *gorm.DB, base *models.FormTemplate, head *models.FormTemplate) error {
+	family := base.Family()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Versions saved before families existed get their family recorded
+		if base.FamilyID == uuid.Nil {
+			if err := tx.Model(base).Update("family_id", family).Error; err != nil {
+				return err
+			}
+		}
+
+		var versions []models.FormTemplate
+		err := familyQuery(tx, family).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "version").
+			Find(&versions).Error
+		if err != nil {
+			return err
+		}
+
+		latest := 0
+		for _, v := range versions {
+			if v.Version > latest {
+				latest = v.Version
+			}
+		}
+
+		if head.Active {
+			if err := familyQuery(tx, family).Model(&models.FormTemplate{}).Update("active", false).Error; err != nil {
+				return err
+			}
+		}
+
+		head.FamilyID = family
+		head.Version = latest + 1
+		return tx.Create(head).Error
+	})
+}
+
+func familyQuery(db *gorm.DB, family uuid.UUID) *gorm.DB {
+	return db.Where("family_id = ? OR (family_id IS NULL AND id = ?)", family, family)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+)
+
+// CompleteInspection validates an inspection and marks it completed
+func (h *Handlers) CompleteInspection(c *gin.Context) {
+	h.transitionInspection(c, models.InspectionActionComplete)
+}
+
+// GetInspectionForm returns an inspection side by side with the template
+// version it was filled against
+func (h *Handlers) GetInspectionForm(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	inspection, err := h.inspectionService.GetInspection(ctx, inspectionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+
+	template, err := h.inspectionService.InspectionTemplate(ctx, inspection)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load form template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection was not filled against a form template"})
+		return
+	}
+
+	config, err := template.ParseConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid form template configuration"})
+		return
+	}
+
+	// Photo links expire instead of pointing at the bucket for good
+	if err := h.inspectionService.MediaLinks().SignInspection(ctx, inspection, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign inspection links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"inspection": gin.H{
+			"id":           inspection.ID,
+			"vehicle_id":   inspection.VehicleID,
+			"type":         inspection.Type,
+			"status":       inspection.Status,
+			"version":      inspection.Version,
+			"started_at":   inspection.StartedAt,
+			"completed_at": inspection.CompletedAt,
+			"signed":       inspection.Signature != "",
+		},
+		"template": gin.H{
+			"id":       template.ID,
+			"name":     template.Name,
+			"type":     template.Type,
+			"version":  template.Version,
+			"active":   template.Active,
+			"settings": config.Settings,
+		},
+		"sections": config.SideBySide(inspection),
+	})
+}
+
+// GetPersistenceStats reports the backlog of inspection edits waiting to be
+// written from Redis to the database
+func (h *Handlers) GetPersistenceStats(c *gin.Context) {
+	stats, err := h.inspectionService.Persister().Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read persistence stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+	"gorm.io/gorm"
+)
+
+// StartInspection moves a draft inspection to in progress
+func (h *Handlers) StartInspection(c *gin.Context) {
+	h.transitionInspection(c, models.InspectionActionStart)
+}
+
+// ApproveInspection records a supervisor's approval of a completed inspection
+func (h *Handlers) ApproveInspection(c *gin.Context) {
+	h.transitionInspection(c, models.InspectionActionApprove)
+}
+
+// RejectInspection sends a completed inspection back to its inspector
+func (h *Handlers) RejectInspection(c *gin.Context) {
+	h.transitionInspection(c, models.InspectionActionReject)
+}
+
+// ReopenInspection makes a completed or approved inspection editable again
+func (h *Handlers) ReopenInspection(c *gin.Context) {
+	h.transitionInspection(c, models.InspectionActionReopen)
+}
+
+// GetInspectionHistory returns the lifecycle audit trail of an inspection
+func (h *Handlers) GetInspectionHistory(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	transitions, err := h.inspectionService.InspectionHistory(c.Request.Context(), inspectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load inspection history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transitions": transitions})
+}
+
+// transitionInspection applies a lifecycle action for the current user. The
+// body may carry {"reason": "..."}, which rejecting and reopening require.
+func (h *Handlers) transitionInspection(c *gin.Context, action string) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	inspection, err := h.inspectionService.TransitionInspection(c.Request.Context(), inspectionID, userID, action, input.Reason)
+
+	var incomplete models.FormValidationErrors
+	switch {
+	case errors.As(err, &incomplete):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Inspection does not satisfy its form template", "fields": incomplete})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	case errors.Is(err, services.ErrTransitionForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, redis.TxFailedErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Errorf("Failed to %s inspection %s: %v", action, inspectionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " inspection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, inspection)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSyncRequestSize = 256 << 20 // a day of photos queued offline, spooled to disk
+	maxSyncPhotoSize   = 20 << 20
+
+	// The server's 15s timeouts cannot fit a day of photos over a weak
+	// mobile link, nor applying a full batch one operation at a time
+	syncReadTimeout  = 10 * time.Minute
+	syncApplyTimeout = 5 * time.Minute
+)
+
+// SyncInspection applies the operations a tablet queued while offline. The
+// body is JSON {"operations": [...]} or, with photos, multipart with the
+// operations as JSON in the "operations" field and each photo in the field
+// its operation names.
+func (h *Handlers) SyncInspection(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	rc := http.NewResponseController(c.Writer)
+	rc.SetReadDeadline(time.Now().Add(syncReadTimeout))
+	rc.SetWriteDeadline(time.Now().Add(syncReadTimeout + syncApplyTimeout))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSyncRequestSize)
+
+	var ops []models.SyncOperation
+	files := make(map[string]*multipart.FileHeader)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart body"})
+			return
+		}
+		raw := form.Value["operations"]
+		if len(raw) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "operations field is required"})
+			return
+		}
+		if err := json.Unmarshal([]byte(raw[0]), &ops); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operations: " + err.Error()})
+			return
+		}
+
+		// Photos are read one at a time as their operations are applied
+		for _, op := range ops {
+			if op.Type != models.SyncOpPhoto || op.File == "" || files[op.File] != nil {
+				continue
+			}
+			headers := form.File[op.File]
+			if len(headers) == 0 {
+				continue
+			}
+			if headers[0].Size > maxSyncPhotoSize {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Photo " + op.File + " is too large"})
+				return
+			}
+			files[op.File] = headers[0]
+		}
+	} else {
+		var input struct {
+			Operations []models.SyncOperation `json:"operations" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ops = input.Operations
+	}
+
+	if len(ops) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No operations to sync"})
+		return
+	}
+	if len(ops) > models.MaxSyncOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many operations in one batch"})
+		return
+	}
+
+	// The body is read; the client waits for the per-operation results
+	rc.SetWriteDeadline(time.Now().Add(syncApplyTimeout))
+
+	result, err := h.inspectionService.SyncInspection(c.Request.Context(), inspectionID, userID, ops, files)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("Failed to sync inspection %s: %v", inspectionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync inspection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+	"github.com/macal/inventory/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// CreatePhotoUpload issues presigned URLs so a photo for an inspection item
+// is uploaded straight to storage instead of through the API
+func (h *Handlers) CreatePhotoUpload(c *gin.Context) {
+	inspectionID, userID, ok := uploadContext(c)
+	if !ok {
+		return
+	}
+
+	var input models.PhotoUploadRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticket, err := h.inspectionService.CreatePhotoUpload(c.Request.Context(), inspectionID, userID, &input)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ticket)
+}
+
+// ConfirmPhotoUpload adds an uploaded photo to its item once storage holds
+// it. Multipart uploads send {"parts": [{"number": 1, "etag": "..."}]}.
+func (h *Handlers) ConfirmPhotoUpload(c *gin.Context) {
+	inspectionID, userID, ok := uploadContext(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Parts []storage.CompletedPart `json:"parts"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	upload, err := h.inspectionService.ConfirmPhotoUpload(c.Request.Context(), inspectionID, userID, c.Param("uploadId"), input.Parts)
+	if err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, upload)
+}
+
+// AbortPhotoUpload discards an upload that will not be confirmed
+func (h *Handlers) AbortPhotoUpload(c *gin.Context) {
+	inspectionID, _, ok := uploadContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.inspectionService.AbortPhotoUpload(c.Request.Context(), inspectionID, c.Param("uploadId")); err != nil {
+		h.respondUploadError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func uploadContext(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return inspectionID, userID, true
+}
+
+func (h *Handlers) respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+	case errors.Is(err, services.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInspectionNotEditable),
+		errors.Is(err, services.ErrUploadIncomplete),
+		errors.Is(err, services.ErrUploadInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadInvalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDirectUploadUnsupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		h.logger.Errorf("Photo upload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process photo upload"})
+	}
+}
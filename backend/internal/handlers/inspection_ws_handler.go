@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	sseKeepAlive  = 15 * time.Second
+	sseWriteWait  = 10 * time.Second
+	sseRetryDelay = 3 * time.Second
+)
+
+// InspectionWebSocket attaches a WebSocket to an inspection for live edits,
+// presence and field locks. Reconnecting clients pass ?last_event_id= to
+// receive the updates they missed.
+func (h *Handlers) InspectionWebSocket(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := h.inspectionService.GetInspection(c.Request.Context(), inspectionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+
+	// The upgrader answers failed handshakes itself
+	err = h.inspectionService.Hub().Serve(c.Writer, c.Request, inspectionID, userID, c.Query("last_event_id"))
+	if err != nil {
+		h.logger.Debugf("WebSocket upgrade for inspection %s failed: %v", inspectionID, err)
+	}
+}
+
+// InspectionEvents streams inspection updates as Server-Sent Events for
+// clients that cannot use WebSockets. The browser resumes with the
+// Last-Event-ID header after a drop.
+func (h *Handlers) InspectionEvents(c *gin.Context) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.inspectionService.GetInspection(ctx, inspectionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inspection not found"})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	sub, err := h.inspectionService.SubscribeToUpdates(ctx, inspectionID, lastEventID)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to subscribe to updates"})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// The server's write timeout would end the stream; extend it per write
+	rc := http.NewResponseController(c.Writer)
+	write := func(format string, args ...interface{}) bool {
+		rc.SetWriteDeadline(time.Now().Add(sseWriteWait))
+		if _, err := fmt.Fprintf(c.Writer, format, args...); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	if !write("retry: %d\n\n", sseRetryDelay.Milliseconds()) {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if !write(": keep-alive\n\n") {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// Untyped so EventSource.onmessage sees every update; the
+			// type is inside the JSON
+			if !write("id: %s\ndata: %s\n\n", event.ID, event.Data) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+	"gorm.io/gorm"
+)
+
+// ListPhotoAnnotations returns the annotations drawn on an inspection photo
+func (h *Handlers) ListPhotoAnnotations(c *gin.Context) {
+	inspectionID, photoID, _, ok := annotationContext(c)
+	if !ok {
+		return
+	}
+
+	photo, annotations, err := h.inspectionService.PhotoAnnotations(c.Request.Context(), inspectionID, photoID)
+	if err != nil {
+		h.respondAnnotationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"photo_id":    photo.ID,
+		"section_id":  photo.SectionID,
+		"item_id":     photo.ItemID,
+		"annotations": annotations,
+	})
+}
+
+// CreatePhotoAnnotation draws an arrow, circle, text or damage area on a
+// photo. Coordinates are fractions of the upright photo's size.
+func (h *Handlers) CreatePhotoAnnotation(c *gin.Context) {
+	inspectionID, photoID, userID, ok := annotationContext(c)
+	if !ok {
+		return
+	}
+
+	var input models.AnnotationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, err := h.inspectionService.CreateAnnotation(c.Request.Context(), inspectionID, photoID, userID, &input)
+	if err != nil {
+		h.respondAnnotationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, annotation)
+}
+
+// UpdatePhotoAnnotation replaces an annotation
+func (h *Handlers) UpdatePhotoAnnotation(c *gin.Context) {
+	inspectionID, photoID, userID, ok := annotationContext(c)
+	if !ok {
+		return
+	}
+
+	var input models.AnnotationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	annotation, err := h.inspectionService.UpdateAnnotation(c.Request.Context(), inspectionID, photoID, c.Param("annotationId"), userID, &input)
+	if err != nil {
+		h.respondAnnotationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
+
+// DeletePhotoAnnotation removes an annotation. ?version= is the inspection
+// version the author last saw, as for other edits.
+func (h *Handlers) DeletePhotoAnnotation(c *gin.Context) {
+	inspectionID, photoID, userID, ok := annotationContext(c)
+	if !ok {
+		return
+	}
+
+	version := 0
+	if v := c.Query("version"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		version = parsed
+	}
+
+	if err := h.inspectionService.DeleteAnnotation(c.Request.Context(), inspectionID, photoID, c.Param("annotationId"), userID, version); err != nil {
+		h.respondAnnotationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetAnnotatedPhoto returns a JPEG copy of the photo with its annotations
+// burned in
+func (h *Handlers) GetAnnotatedPhoto(c *gin.Context) {
+	inspectionID, photoID, _, ok := annotationContext(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.inspectionService.AnnotatedPhoto(c.Request.Context(), inspectionID, photoID, services.AnnotatedPhotoMaxSide)
+	if err != nil {
+		h.respondAnnotationError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "image/jpeg", data)
+}
+
+func annotationContext(c *gin.Context) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	inspectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inspection ID"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	photoID, err := uuid.Parse(c.Param("photoId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return inspectionID, photoID, userID, true
+}
+
+func (h *Handlers) respondAnnotationError(c *gin.Context, err error) {
+	var conflict *services.UpdateConflictError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found in this inspection"})
+	case errors.Is(err, services.ErrAnnotationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidAnnotation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &conflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "winner": conflict.Winner})
+	case errors.Is(err, services.ErrInspectionNotEditable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.Errorf("Photo annotation failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process photo annotation"})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+)
+
+// Predefined (system) templates
+
+// ListPredefinedTemplates returns the catalogue of system templates without
+// their configs; GetPredefinedTemplate returns one in full
+func (h *Handlers) ListPredefinedTemplates(c *gin.Context) {
+	query := h.db.Model(&models.PredefinedTemplate{})
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var templates []models.PredefinedTemplate
+	if err := query.Order(`"order" ASC, id ASC`).Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch predefined templates"})
+		return
+	}
+
+	var categories []models.TemplateCategory
+	if err := h.db.Order(`"order" ASC`).Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	catalogue := make([]gin.H, 0, len(templates))
+	for _, template := range templates {
+		fields := 0
+		for _, section := range template.Config.Sections {
+			fields += len(section.Fields)
+		}
+		catalogue = append(catalogue, gin.H{
+			"id":          template.ID,
+			"name":        template.Name,
+			"description": template.Description,
+			"category":    template.Category,
+			"icon":        template.Icon,
+			"tags":        template.Tags,
+			"sections":    len(template.Config.Sections),
+			"fields":      fields,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates":  catalogue,
+		"categories": categories,
+		"count":      len(catalogue),
+	})
+}
+
+// GetPredefinedTemplate returns a system template with its config
+func (h *Handlers) GetPredefinedTemplate(c *gin.Context) {
+	var template models.PredefinedTemplate
+	if err := h.db.First(&template, "id = ?", c.Param("key")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Predefined template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// InstallPredefinedTemplate creates an active FormTemplate from a system
+// template. Installing twice creates two independent templates.
+func (h *Handlers) InstallPredefinedTemplate(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var predefined models.PredefinedTemplate
+	if err := h.db.First(&predefined, "id = ?", c.Param("key")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Predefined template not found"})
+		return
+	}
+
+	if err := validateFormConfig(predefined.Config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Predefined template is invalid", "details": err.Error()})
+		return
+	}
+
+	name := input.Name
+	if name == "" {
+		name = predefined.Name
+	}
+	template := models.FormTemplate{
+		Name:      name,
+		Type:      predefined.Category,
+		Config:    predefined.Config.ToJSONB(),
+		CreatedBy: uuid.MustParse(userID),
+		Active:    true,
+		Version:   1,
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to install template"})
+		return
+	}
+
+	h.cacheFormTemplate(&template)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"template":      template,
+		"predefined_id": predefined.ID,
+	})
+}
@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Template marketplace
+
+var marketplaceSorts = map[string]string{
+	"downloads": "downloads DESC, rating DESC",
+	"rating":    "rating DESC, rating_count DESC",
+	"newest":    "published_at DESC",
+	"name":      "name ASC",
+}
+
+// ListMarketplaceTemplates searches published templates. Filters: category,
+// tags (comma separated, all must match), q (name and description),
+// official and featured; sort is downloads, rating, newest or name.
+func (h *Handlers) ListMarketplaceTemplates(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	order, ok := marketplaceSorts[c.DefaultQuery("sort", "downloads")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, use downloads, rating, newest or name"})
+		return
+	}
+
+	query := h.db.Model(&models.TemplateMarketplace{})
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if tags := splitTags(c.Query("tags")); len(tags) > 0 {
+		query = query.Where("tags @> ?", pq.StringArray(tags))
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ?", pattern, pattern)
+	}
+	if c.Query("official") == "true" {
+		query = query.Where("is_official = ?", true)
+	}
+	if c.Query("featured") == "true" {
+		query = query.Where("is_featured = ?", true)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search marketplace"})
+		return
+	}
+
+	var listings []models.TemplateMarketplace
+	if err := query.Order(order).Limit(limit).Offset(offset).Find(&listings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search marketplace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates":  listings,
+		"count":      len(listings),
+		"total":      total,
+		"categories": models.GetTemplateCategories(),
+	})
+}
+
+// GetMarketplaceTemplate returns a listing with the published template
+func (h *Handlers) GetMarketplaceTemplate(c *gin.Context) {
+	var listing models.TemplateMarketplace
+	err := h.db.Preload("Template", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		First(&listing, "id = ?", c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// PublishMarketplaceTemplate publishes one of our form templates. The listing
+// points at that exact version, so later edits are not shared until they are
+// published again.
+func (h *Handlers) PublishMarketplaceTemplate(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var input struct {
+		TemplateID  uuid.UUID `json:"template_id" binding:"required"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Category    string    `json:"category"`
+		Tags        []string  `json:"tags"`
+		Icon        string    `json:"icon"`
+		Screenshots []string  `json:"screenshots"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var template models.FormTemplate
+	if err := h.db.First(&template, "id = ?", input.TemplateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	config, err := template.ParseConfig()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form configuration", "details": err.Error()})
+		return
+	}
+	if err := validateFormConfig(config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form configuration", "details": err.Error()})
+		return
+	}
+
+	if input.Category == "" {
+		input.Category = "custom"
+	}
+	if !models.ValidTemplateCategory(input.Category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown category: " + input.Category})
+		return
+	}
+	if input.Name == "" {
+		input.Name = template.Name
+	}
+
+	// IsOfficial and IsFeatured are only set through the admin endpoint
+	listing := models.TemplateMarketplace{
+		TemplateID:  template.ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Category:    input.Category,
+		Tags:        pq.StringArray(normalizeTags(input.Tags)),
+		Icon:        input.Icon,
+		Screenshots: pq.StringArray(input.Screenshots),
+		PublishedBy: uuid.MustParse(userID),
+	}
+
+	if err := h.db.Create(&listing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, listing)
+}
+
+// UnpublishMarketplaceTemplate removes a listing; only its publisher may do so
+func (h *Handlers) UnpublishMarketplaceTemplate(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	result := h.db.Where("id = ? AND published_by = ?", c.Param("id"), userID).
+		Delete(&models.TemplateMarketplace{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpublish template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template unpublished"})
+}
+
+// InstallMarketplaceTemplate clones a published template into our workspace
+// as a new template family and counts the download
+func (h *Handlers) InstallMarketplaceTemplate(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var listing models.TemplateMarketplace
+	if err := h.db.First(&listing, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace template not found"})
+		return
+	}
+
+	// The published version may since have been superseded or deleted
+	var source models.FormTemplate
+	if err := h.db.Unscoped().First(&source, "id = ?", listing.TemplateID).Error; err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Published template is no longer available"})
+		return
+	}
+
+	name := input.Name
+	if name == "" {
+		name = listing.Name
+	}
+	installed := models.FormTemplate{
+		Name:      name,
+		Type:      source.Type,
+		Config:    source.Config,
+		CreatedBy: uuid.MustParse(userID),
+		Active:    true,
+		Version:   1,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&installed).Error; err != nil {
+			return err
+		}
+		return tx.Model(&listing).UpdateColumn("downloads", gorm.Expr("downloads + 1")).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to install template"})
+		return
+	}
+
+	h.cacheFormTemplate(&installed)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"template":       installed,
+		"marketplace_id": listing.ID,
+	})
+}
+
+// RateMarketplaceTemplate records the caller's rating. Rating again replaces
+// the previous one; the listing average and count are recomputed in the
+// same transaction.
+func (h *Handlers) RateMarketplaceTemplate(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var input struct {
+		Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var listing models.TemplateMarketplace
+	if err := h.db.First(&listing, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace template not found"})
+		return
+	}
+
+	user := uuid.MustParse(userID)
+	if listing.PublishedBy == user {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Publishers cannot rate their own templates"})
+		return
+	}
+
+	rating := models.TemplateRating{
+		TemplateID: listing.ID,
+		UserID:     user,
+		Rating:     input.Rating,
+		Comment:    input.Comment,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Raters of a listing queue on its row, so each recount sees every
+		// rating committed before it and the totals cannot go stale
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&listing, "id = ?", listing.ID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "template_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at"}),
+		}).Create(&rating).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`UPDATE template_marketplaces SET
+			rating = COALESCE((SELECT AVG(rating) FROM template_ratings WHERE template_id = ?), 0),
+			rating_count = (SELECT COUNT(*) FROM template_ratings WHERE template_id = ?)
+			WHERE id = ?`, listing.ID, listing.ID, listing.ID).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&listing, "id = ?", listing.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rating"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rating":       rating,
+		"average":      listing.Rating,
+		"rating_count": listing.RatingCount,
+	})
+}
+
+// ListMarketplaceRatings returns the ratings of a listing, newest first
+func (h *Handlers) ListMarketplaceRatings(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var ratings []models.TemplateRating
+	err := h.db.Where("template_id = ?", c.Param("id")).
+		Order("updated_at DESC").
+		Limit(limit).
+		Find(&ratings).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ratings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ratings": ratings,
+		"count":   len(ratings),
+	})
+}
+
+// UpdateMarketplaceFlags sets IsOfficial and IsFeatured (admin only)
+func (h *Handlers) UpdateMarketplaceFlags(c *gin.Context) {
+	var input struct {
+		IsOfficial *bool `json:"is_official"`
+		IsFeatured *bool `json:"is_featured"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.IsOfficial != nil {
+		updates["is_official"] = *input.IsOfficial
+	}
+	if input.IsFeatured != nil {
+		updates["is_featured"] = *input.IsFeatured
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	var listing models.TemplateMarketplace
+	if err := h.db.First(&listing, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace template not found"})
+		return
+	}
+
+	if err := h.db.Model(&listing).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+func splitTags(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	return normalizeTags(strings.Split(raw, ","))
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+	"gorm.io/gorm"
+)
+
+// UpdateVehicleStatus moves a vehicle to another status by hand. Only the
+// transitions the workflow allows are accepted.
+func (h *Handlers) UpdateVehicleStatus(c *gin.Context) {
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Status models.VehicleStatus `json:"status" binding:"required"`
+		Reason string               `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.Status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown vehicle status"})
+		return
+	}
+
+	vehicle, err := h.inspectionService.Vehicles().Transition(c.Request.Context(), vehicleID, services.VehicleStatusChange{
+		To:          input.Status,
+		Trigger:     models.VehicleTriggerManual,
+		Reason:      strings.TrimSpace(input.Reason),
+		ChangedByID: &userID,
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	case errors.Is(err, services.ErrInvalidVehicleTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Errorf("Failed to change status of vehicle %s: %v", vehicleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change vehicle status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, vehicle)
+}
+
+// GetVehicleStatusHistory returns the status changes of a vehicle, newest
+// first
+func (h *Handlers) GetVehicleStatusHistory(c *gin.Context) {
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return
+	}
+
+	history, err := h.inspectionService.Vehicles().History(c.Request.Context(), vehicleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load vehicle status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/services"
+)
+
+// GetVehicleTimeline returns the history of a vehicle, newest first: status
+// changes, inspections, photo uploads and client access. Older pages are
+// requested with ?before=<next_before>.
+func (h *Handlers) GetVehicleTimeline(c *gin.Context) {
+	vehicleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vehicle ID"})
+		return
+	}
+
+	query, ok := timelineQuery(c)
+	if !ok {
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	h.respondTimeline(c, &vehicle, query)
+}
+
+// GetClientVehicleTimeline returns the timeline of a vehicle filtered by the
+// client's permissions
+func (h *Handlers) GetClientVehicleTimeline(c *gin.Context) {
+	client := c.MustGet("client").(*models.ClientOrganization)
+	vehicleID := c.Param("id")
+
+	if !client.Permissions.CanViewVehicles {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view vehicles"})
+		return
+	}
+
+	query, ok := timelineQuery(c)
+	if !ok {
+		return
+	}
+	query.Client = client
+
+	var vehicle models.Vehicle
+	if err := h.db.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vehicle not found"})
+		return
+	}
+
+	if !client.CanAccessVehicle(&vehicle) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this vehicle"})
+		return
+	}
+
+	// Log access
+	h.logClientAccess(c, client, "view_vehicle_timeline", "vehicle", vehicleID, http.StatusOK)
+
+	h.respondTimeline(c, &vehicle, query)
+}
+
+func (h *Handlers) respondTimeline(c *gin.Context, vehicle *models.Vehicle, query services.TimelineQuery) {
+	events, err := services.NewVehicleTimeline(h.db).Events(c.Request.Context(), vehicle, query)
+	if err != nil {
+		h.logger.Errorf("Failed to build timeline of vehicle %s: %v", vehicle.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load vehicle timeline"})
+		return
+	}
+
+	expiry := services.StaffLinkExpiry
+	if query.Client != nil {
+		expiry = query.Client.LinkExpiry(services.ClientLinkExpiry)
+	}
+	h.inspectionService.MediaLinks().SignEvents(c.Request.Context(), events, expiry)
+
+	response := gin.H{
+		"vehicle_id": vehicle.ID,
+		"events":     events,
+	}
+	if query.Limit > 0 && len(events) == query.Limit {
+		response["next_before"] = events[len(events)-1].OccurredAt
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// timelineQuery parses ?limit= and ?before= (RFC 3339)
+func timelineQuery(c *gin.Context) (services.TimelineQuery, bool) {
+	query := services.TimelineQuery{Limit: services.DefaultTimelineLimit}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return query, false
+		}
+		if limit > services.MaxTimelineLimit {
+			limit = services.MaxTimelineLimit
+		}
+		query.Limit = limit
+	}
+
+	if b := c.Query("before"); b != "" {
+		before, err := time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before, expected RFC 3339"})
+			return query, false
+		}
+		query.Before = before
+	}
+
+	return query, true
+}
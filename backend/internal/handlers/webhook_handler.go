@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/webhooks"
+)
+
+// Admin handlers for client webhook subscriptions
+
+// ListClientWebhooks returns the webhook subscriptions of a client
+func (h *Handlers) ListClientWebhooks(c *gin.Context) {
+	clientID := c.Param("id")
+
+	var subscriptions []models.WebhookSubscription
+	if err := h.db.Where("organization_id = ?", clientID).Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": subscriptions,
+		"count":    len(subscriptions),
+	})
+}
+
+// CreateClientWebhook registers a webhook endpoint for a client. A secret is
+// generated when none is given.
+func (h *Handlers) CreateClientWebhook(c *gin.Context) {
+	clientID := c.Param("id")
+
+	var client models.ClientOrganization
+	if err := h.db.First(&client, "id = ?", clientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var input struct {
+		URL        string   `json:"url" binding:"required"`
+		Secret     string   `json:"secret"`
+		EventTypes []string `json:"event_types"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateWebhook(input.URL, input.EventTypes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook configuration", "details": err.Error()})
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		OrganizationID: client.ID,
+		URL:            input.URL,
+		Secret:         input.Secret,
+		EventTypes:     input.EventTypes,
+		Active:         true,
+	}
+
+	if err := h.db.Create(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UpdateClientWebhook updates a webhook subscription
+func (h *Handlers) UpdateClientWebhook(c *gin.Context) {
+	clientID := c.Param("id")
+	webhookID := c.Param("webhookId")
+
+	var subscription models.WebhookSubscription
+	if err := h.db.First(&subscription, "id = ? AND organization_id = ?", webhookID, clientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var input struct {
+		URL          *string  `json:"url"`
+		EventTypes   []string `json:"event_types"`
+		Active       *bool    `json:"active"`
+		RotateSecret bool     `json:"rotate_secret"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.URL != nil {
+		subscription.URL = *input.URL
+	}
+	if input.EventTypes != nil {
+		subscription.EventTypes = input.EventTypes
+	}
+	if input.Active != nil {
+		subscription.Active = *input.Active
+	}
+	if input.RotateSecret {
+		subscription.Secret = models.GenerateWebhookSecret()
+	}
+
+	if err := validateWebhook(subscription.URL, subscription.EventTypes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook configuration", "details": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteClientWebhook removes a webhook subscription. Pending deliveries are
+// cancelled when the dispatcher picks them up.
+func (h *Handlers) DeleteClientWebhook(c *gin.Context) {
+	clientID := c.Param("id")
+	webhookID := c.Param("webhookId")
+
+	result := h.db.Where("id = ? AND organization_id = ?", webhookID, clientID).Delete(&models.WebhookSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetClientWebhookDeliveries lists recent deliveries, optionally by status
+func (h *Handlers) GetClientWebhookDeliveries(c *gin.Context) {
+	clientID := c.Param("id")
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	query := h.db.Where("organization_id = ?", clientID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if webhookID := c.Query("webhookId"); webhookID != "" {
+		query = query.Where("subscription_id = ?", webhookID)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// GetClientWebhookDeadLetters lists deliveries that exhausted their retries
+func (h *Handlers) GetClientWebhookDeadLetters(c *gin.Context) {
+	clientID := c.Param("id")
+
+	query := h.db.Where("organization_id = ?", clientID)
+	if c.Query("replayed") != "true" {
+		query = query.Where("replayed_at IS NULL")
+	}
+
+	var deadLetters []models.WebhookDeadLetter
+	if err := query.Order("failed_at DESC").Limit(500).Find(&deadLetters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead letters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": deadLetters,
+		"count":        len(deadLetters),
+	})
+}
+
+// ReplayClientWebhookDelivery queues a delivery again with its original
+// event ID and payload
+func (h *Handlers) ReplayClientWebhookDelivery(c *gin.Context) {
+	clientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	replay, err := webhooks.NewDispatcher(h.db).Replay(c.Request.Context(), clientID, deliveryID)
+	switch {
+	case errors.Is(err, webhooks.ErrDeliveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	case errors.Is(err, webhooks.ErrSubscriptionInactive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, replay)
+}
+
+func validateWebhook(rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+
+	for _, eventType := range eventTypes {
+		if !models.ValidWebhookEvent(eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
@@ -25,7 +25,10 @@ type ClientOrganization struct {
 	IPWhitelist pq.StringArray `gorm:"type:text[]" json:"ip_whitelist"`
 	
 	// Permissions
-	Permissions ClientPermissions `gorm:"type:jsonb" json:"permissions"`
+	Permissions ClientPermissions `gorm:"type:jsonb;serializer:json" json:"permissions"`
+	
+	// Notification delivery
+	NotificationSettings NotificationSettings `gorm:"type:jsonb;serializer:json" json:"notification_settings"`
 	
 	// Tracking
 	LastAccess  *time.Time     `json:"last_access"`
@@ -63,6 +66,14 @@ type VehicleFilters struct {
 	DateTo        *time.Time  `json:"date_to"`        // Vehicles before this date
 }
 
+// NotificationSettings controls email delivery of notifications. The in-app
+// inbox always receives them and webhooks use WebhookSubscriptions.
+type NotificationSettings struct {
+	Email      bool     `json:"email"`
+	Recipients []string `json:"recipients"` // defaults to the organization email
+	Types      []string `json:"types"`      // notification types to email, empty means all
+}
+
 // ClientAccessLog tracks all client access for audit
 type ClientAccessLog struct {
 	ID               uuid.UUID            `gorm:"type:uuid;primary_key" json:"id"`
@@ -92,6 +103,22 @@ type ClientReport struct {
 	UpdatedAt      time.Time           `json:"updated_at"`
 }
 
+// Report schedules
+const (
+	ReportScheduleDaily    = "daily"
+	ReportScheduleWeekly   = "weekly"
+	ReportScheduleMonthly  = "monthly"
+	ReportScheduleOnDemand = "on_demand"
+)
+
+// Notification types
+const (
+	NotificationVehicleAdded         = "vehicle_added"
+	NotificationVehicleStatusChanged = "vehicle_status_changed"
+	NotificationInspectionCompleted  = "inspection_completed"
+	NotificationReportReady          = "report_ready"
+)
+
 // ClientNotification for sending updates to clients
 type ClientNotification struct {
 	ID             uuid.UUID           `gorm:"type:uuid;primary_key" json:"id"`
@@ -152,6 +179,15 @@ func (c *ClientOrganization) IsValid() bool {
 	return true
 }
 
+// LinkExpiry caps the lifetime of a link handed to the organization so it
+// never outlives its access
+func (c *ClientOrganization) LinkExpiry(max time.Duration) time.Duration {
+	if c.ValidUntil != nil && time.Until(*c.ValidUntil) < max {
+		return time.Until(*c.ValidUntil)
+	}
+	return max
+}
+
 func (c *ClientOrganization) CanAccessVehicle(vehicle *Vehicle) bool {
 	if !c.Permissions.CanViewVehicles {
 		return false
@@ -226,6 +262,153 @@ func (c *ClientOrganization) CanAccessVehicle(vehicle *Vehicle) bool {
 	return true
 }
 
+// NextRun returns when a scheduled report is due. Reports that never ran are
+// due immediately; on_demand reports are never scheduled.
+func (r *ClientReport) NextRun() (time.Time, bool) {
+	if !r.Active {
+		return time.Time{}, false
+	}
+	if r.LastGenerated == nil {
+		switch r.Schedule {
+		case ReportScheduleDaily, ReportScheduleWeekly, ReportScheduleMonthly:
+			return r.CreatedAt, true
+		}
+		return time.Time{}, false
+	}
+
+	last := *r.LastGenerated
+	switch r.Schedule {
+	case ReportScheduleDaily:
+		return last.AddDate(0, 0, 1), true
+	case ReportScheduleWeekly:
+		return last.AddDate(0, 0, 7), true
+	case ReportScheduleMonthly:
+		return last.AddDate(0, 1, 0), true
+	}
+	return time.Time{}, false
+}
+
+// Wants reports whether notifications of the given type should be delivered
+// through the external channels
+func (n NotificationSettings) Wants(notificationType string) bool {
+	if len(n.Types) == 0 {
+		return true
+	}
+	for _, t := range n.Types {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// HidesField reports whether a vehicle field is listed in HiddenFields
+func (p ClientPermissions) HidesField(field string) bool {
+	for _, hidden := range p.HiddenFields {
+		if hidden == field {
+			return true
+		}
+	}
+	return false
+}
+
+// URLSigner turns a stored object URL into a time limited link
+type URLSigner func(url string) string
+
+// VehicleView returns the fields of vehicle the client is allowed to see.
+// Photo links go through sign when given; links it returns empty are left
+// out.
+func (p ClientPermissions) VehicleView(vehicle *Vehicle, sign URLSigner) map[string]interface{} {
+	if sign == nil {
+		sign = func(url string) string { return url }
+	}
+	v := make(map[string]interface{})
+
+	// Always include basic fields
+	v["id"] = vehicle.ID
+	v["licensePlate"] = vehicle.LicensePlate
+	v["make"] = vehicle.Make
+	v["model"] = vehicle.Model
+	v["year"] = vehicle.Year
+	v["status"] = vehicle.Status
+	v["checkInDate"] = vehicle.CheckInDate
+
+	// Conditionally include fields based on permissions
+	if !p.HidesField("vin") {
+		v["vin"] = vehicle.VIN
+	}
+	if !p.HidesField("mileage") {
+		v["mileage"] = vehicle.Mileage
+	}
+	if !p.HidesField("color") {
+		v["color"] = vehicle.Color
+	}
+	if vehicle.CheckOutDate != nil && !p.HidesField("checkOutDate") {
+		v["checkOutDate"] = vehicle.CheckOutDate
+	}
+
+	// Owner info
+	if p.CanViewOwnerInfo && vehicle.Owner != nil {
+		v["owner"] = map[string]interface{}{
+			"id":          vehicle.Owner.ID,
+			"name":        vehicle.Owner.Name,
+			"companyName": vehicle.Owner.CompanyName,
+		}
+	}
+
+	// Photos, as processed renditions: originals may carry GPS
+	if p.CanViewPhotos && len(vehicle.Photos) > 0 {
+		photos := make([]map[string]interface{}, 0, len(vehicle.Photos))
+		for j := range vehicle.Photos {
+			photo := &vehicle.Photos[j]
+			if !photo.ClientVisible() {
+				continue
+			}
+			entry := map[string]interface{}{
+				"id":       photo.ID,
+				"category": photo.Category,
+			}
+			for field, url := range map[string]string{"url": photo.Display, "medium": photo.Medium, "thumbnail": photo.Thumbnail} {
+				if link := sign(url); link != "" {
+					entry[field] = link
+				}
+			}
+			photos = append(photos, entry)
+		}
+		v["photos"] = photos
+	}
+
+	// Inspections count
+	if p.CanViewInspections {
+		v["inspectionsCount"] = len(vehicle.Inspections)
+	}
+
+	return v
+}
+
+// Apply restricts a vehicle query to the vehicles matched by the filters
+func (f VehicleFilters) Apply(query *gorm.DB) *gorm.DB {
+	if len(f.VehicleIDs) > 0 {
+		query = query.Where("id IN ?", f.VehicleIDs)
+	}
+	if len(f.OwnerIDs) > 0 {
+		query = query.Where("owner_id IN ?", f.OwnerIDs)
+	}
+	if len(f.LicensePlates) > 0 {
+		query = query.Where("license_plate IN ?", f.LicensePlates)
+	}
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if f.DateFrom != nil {
+		query = query.Where("check_in_date >= ?", f.DateFrom)
+	}
+	if f.DateTo != nil {
+		query = query.Where("check_in_date <= ?", f.DateTo)
+	}
+	return query
+}
+
 func generateSecureToken() string {
 	// Generate a secure random token
 	return uuid.New().String() + "-" + uuid.New().String()
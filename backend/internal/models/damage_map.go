@@ -0,0 +1,530 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDamage is returned for damage records outside the catalogue
+var ErrInvalidDamage = errors.New("invalid damage")
+
+// DamageArea groups body zones by the side of the vehicle they are on. The
+// labels match the damage_location options of the damage report template.
+type DamageArea string
+
+const (
+	DamageAreaFront DamageArea = "front"
+	DamageAreaRear  DamageArea = "rear"
+	DamageAreaLeft  DamageArea = "left"
+	DamageAreaRight DamageArea = "right"
+	DamageAreaTop   DamageArea = "top"
+)
+
+// DamageZone is a standardized body panel or part
+type DamageZone string
+
+const (
+	DamageZoneFrontBumper      DamageZone = "front_bumper"
+	DamageZoneGrille           DamageZone = "grille"
+	DamageZoneHood             DamageZone = "hood"
+	DamageZoneWindshield       DamageZone = "windshield"
+	DamageZoneLeftHeadlight    DamageZone = "left_headlight"
+	DamageZoneRightHeadlight   DamageZone = "right_headlight"
+	DamageZoneRoof             DamageZone = "roof"
+	DamageZoneRearWindow       DamageZone = "rear_window"
+	DamageZoneTrunk            DamageZone = "trunk"
+	DamageZoneRearBumper       DamageZone = "rear_bumper"
+	DamageZoneLeftTaillight    DamageZone = "left_taillight"
+	DamageZoneRightTaillight   DamageZone = "right_taillight"
+	DamageZoneLeftFrontFender  DamageZone = "left_front_fender"
+	DamageZoneLeftFrontDoor    DamageZone = "left_front_door"
+	DamageZoneLeftRearDoor     DamageZone = "left_rear_door"
+	DamageZoneLeftRearFender   DamageZone = "left_rear_fender"
+	DamageZoneLeftMirror       DamageZone = "left_mirror"
+	DamageZoneLeftRocker       DamageZone = "left_rocker"
+	DamageZoneLeftWindows      DamageZone = "left_windows"
+	DamageZoneLeftFrontWheel   DamageZone = "left_front_wheel"
+	DamageZoneLeftRearWheel    DamageZone = "left_rear_wheel"
+	DamageZoneRightFrontFender DamageZone = "right_front_fender"
+	DamageZoneRightFrontDoor   DamageZone = "right_front_door"
+	DamageZoneRightRearDoor    DamageZone = "right_rear_door"
+	DamageZoneRightRearFender  DamageZone = "right_rear_fender"
+	DamageZoneRightMirror      DamageZone = "right_mirror"
+	DamageZoneRightRocker      DamageZone = "right_rocker"
+	DamageZoneRightWindows     DamageZone = "right_windows"
+	DamageZoneRightFrontWheel  DamageZone = "right_front_wheel"
+	DamageZoneRightRearWheel   DamageZone = "right_rear_wheel"
+)
+
+// DamageType is the kind of damage found on a zone
+type DamageType string
+
+const (
+	DamageTypeScratch DamageType = "scratch"
+	DamageTypeDent    DamageType = "dent"
+	DamageTypeCrack   DamageType = "crack"
+	DamageTypeBroken  DamageType = "broken"
+	DamageTypePaint   DamageType = "paint"
+	DamageTypeRust    DamageType = "rust"
+	DamageTypeMissing DamageType = "missing"
+	DamageTypeOther   DamageType = "other"
+)
+
+// DamageSeverity grades a damage, from minor to severe
+type DamageSeverity string
+
+const (
+	DamageSeverityMinor    DamageSeverity = "minor"
+	DamageSeverityModerate DamageSeverity = "moderate"
+	DamageSeveritySevere   DamageSeverity = "severe"
+)
+
+// DamageChange tells how a damage compares between the entry and exit
+// inspections of a vehicle
+type DamageChange string
+
+const (
+	DamageChangeRecorded    DamageChange = "recorded"     // only one of the inspections exists yet
+	DamageChangePreExisting DamageChange = "pre_existing" // found at entry, no worse at exit
+	DamageChangeNew         DamageChange = "new"          // found at exit only
+	DamageChangeWorsened    DamageChange = "worsened"     // more severe at exit than at entry
+	DamageChangeRepaired    DamageChange = "repaired"     // found at entry, gone at exit
+)
+
+// CatalogueEntry is an identifier with its Spanish display name
+type CatalogueEntry struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// DamageZoneInfo describes a zone of the catalogue
+type DamageZoneInfo struct {
+	ID    DamageZone `json:"id"`
+	Label string     `json:"label"`
+	Area  DamageArea `json:"area"`
+}
+
+// DamageCatalogue lists the zones, types and severities a damage may have,
+// in display order
+type DamageCatalogue struct {
+	Areas      []CatalogueEntry `json:"areas"`
+	Zones      []DamageZoneInfo `json:"zones"`
+	Types      []CatalogueEntry `json:"types"`
+	Severities []CatalogueEntry `json:"severities"`
+}
+
+var damageAreas = []CatalogueEntry{
+	{ID: string(DamageAreaFront), Label: "Frontal"},
+	{ID: string(DamageAreaRear), Label: "Trasero"},
+	{ID: string(DamageAreaLeft), Label: "Lateral Izquierdo"},
+	{ID: string(DamageAreaRight), Label: "Lateral Derecho"},
+	{ID: string(DamageAreaTop), Label: "Techo"},
+}
+
+var damageZones = []DamageZoneInfo{
+	{DamageZoneFrontBumper, "Parachoques delantero", DamageAreaFront},
+	{DamageZoneGrille, "Parrilla", DamageAreaFront},
+	{DamageZoneHood, "Capó", DamageAreaFront},
+	{DamageZoneWindshield, "Parabrisas", DamageAreaFront},
+	{DamageZoneLeftHeadlight, "Foco delantero izquierdo", DamageAreaFront},
+	{DamageZoneRightHeadlight, "Foco delantero derecho", DamageAreaFront},
+	{DamageZoneRearBumper, "Parachoques trasero", DamageAreaRear},
+	{DamageZoneTrunk, "Maletero", DamageAreaRear},
+	{DamageZoneRearWindow, "Luneta", DamageAreaRear},
+	{DamageZoneLeftTaillight, "Foco trasero izquierdo", DamageAreaRear},
+	{DamageZoneRightTaillight, "Foco trasero derecho", DamageAreaRear},
+	{DamageZoneLeftFrontFender, "Tapabarro delantero izquierdo", DamageAreaLeft},
+	{DamageZoneLeftFrontDoor, "Puerta delantera izquierda", DamageAreaLeft},
+	{DamageZoneLeftRearDoor, "Puerta trasera izquierda", DamageAreaLeft},
+	{DamageZoneLeftRearFender, "Tapabarro trasero izquierdo", DamageAreaLeft},
+	{DamageZoneLeftMirror, "Espejo izquierdo", DamageAreaLeft},
+	{DamageZoneLeftRocker, "Zócalo izquierdo", DamageAreaLeft},
+	{DamageZoneLeftWindows, "Vidrios laterales izquierdos", DamageAreaLeft},
+	{DamageZoneLeftFrontWheel, "Rueda delantera izquierda", DamageAreaLeft},
+	{DamageZoneLeftRearWheel, "Rueda trasera izquierda", DamageAreaLeft},
+	{DamageZoneRightFrontFender, "Tapabarro delantero derecho", DamageAreaRight},
+	{DamageZoneRightFrontDoor, "Puerta delantera derecha", DamageAreaRight},
+	{DamageZoneRightRearDoor, "Puerta trasera derecha", DamageAreaRight},
+	{DamageZoneRightRearFender, "Tapabarro trasero derecho", DamageAreaRight},
+	{DamageZoneRightMirror, "Espejo derecho", DamageAreaRight},
+	{DamageZoneRightRocker, "Zócalo derecho", DamageAreaRight},
+	{DamageZoneRightWindows, "Vidrios laterales derechos", DamageAreaRight},
+	{DamageZoneRightFrontWheel, "Rueda delantera derecha", DamageAreaRight},
+	{DamageZoneRightRearWheel, "Rueda trasera derecha", DamageAreaRight},
+	{DamageZoneRoof, "Techo", DamageAreaTop},
+}
+
+var damageTypes = []CatalogueEntry{
+	{ID: string(DamageTypeScratch), Label: "Rayón"},
+	{ID: string(DamageTypeDent), Label: "Abolladura"},
+	{ID: string(DamageTypeCrack), Label: "Trizadura"},
+	{ID: string(DamageTypeBroken), Label: "Rotura"},
+	{ID: string(DamageTypePaint), Label: "Daño de pintura"},
+	{ID: string(DamageTypeRust), Label: "Óxido"},
+	{ID: string(DamageTypeMissing), Label: "Pieza faltante"},
+	{ID: string(DamageTypeOther), Label: "Otro"},
+}
+
+// Severities from least to most severe; the position is the rank
+var damageSeverities = []CatalogueEntry{
+	{ID: string(DamageSeverityMinor), Label: "Leve"},
+	{ID: string(DamageSeverityModerate), Label: "Moderado"},
+	{ID: string(DamageSeveritySevere), Label: "Grave"},
+}
+
+var damageChangeLabels = map[DamageChange]string{
+	DamageChangeRecorded:    "Registrado",
+	DamageChangePreExisting: "Preexistente",
+	DamageChangeNew:         "Nuevo",
+	DamageChangeWorsened:    "Agravado",
+	DamageChangeRepaired:    "Reparado",
+}
+
+const maxDamageNotes = 1000
+
+// GetDamageCatalogue returns the damage catalogue
+func GetDamageCatalogue() DamageCatalogue {
+	return DamageCatalogue{
+		Areas:      append([]CatalogueEntry(nil), damageAreas...),
+		Zones:      append([]DamageZoneInfo(nil), damageZones...),
+		Types:      append([]CatalogueEntry(nil), damageTypes...),
+		Severities: append([]CatalogueEntry(nil), damageSeverities...),
+	}
+}
+
+// catalogueIndex returns the position of id in entries, -1 when missing
+func catalogueIndex(entries []CatalogueEntry, id string) int {
+	for i, entry := range entries {
+		if entry.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (z DamageZone) index() int {
+	for i, zone := range damageZones {
+		if zone.ID == z {
+			return i
+		}
+	}
+	return -1
+}
+
+// Valid reports whether z is in the catalogue
+func (z DamageZone) Valid() bool {
+	return z.index() >= 0
+}
+
+// Info returns the catalogue entry of the zone
+func (z DamageZone) Info() DamageZoneInfo {
+	if i := z.index(); i >= 0 {
+		return damageZones[i]
+	}
+	return DamageZoneInfo{ID: z, Label: string(z)}
+}
+
+// Label returns the Spanish display name of the zone
+func (z DamageZone) Label() string {
+	return z.Info().Label
+}
+
+// Valid reports whether t is in the catalogue
+func (t DamageType) Valid() bool {
+	return catalogueIndex(damageTypes, string(t)) >= 0
+}
+
+// Label returns the Spanish display name of the type
+func (t DamageType) Label() string {
+	if i := catalogueIndex(damageTypes, string(t)); i >= 0 {
+		return damageTypes[i].Label
+	}
+	return string(t)
+}
+
+// Valid reports whether s is in the catalogue
+func (s DamageSeverity) Valid() bool {
+	return s.Rank() >= 0
+}
+
+// Rank orders severities, higher is more severe and -1 is unknown
+func (s DamageSeverity) Rank() int {
+	return catalogueIndex(damageSeverities, string(s))
+}
+
+// Label returns the Spanish display name of the severity
+func (s DamageSeverity) Label() string {
+	if i := s.Rank(); i >= 0 {
+		return damageSeverities[i].Label
+	}
+	return string(s)
+}
+
+// Label returns the Spanish display name of the change
+func (c DamageChange) Label() string {
+	if label, ok := damageChangeLabels[c]; ok {
+		return label
+	}
+	return string(c)
+}
+
+// InspectionDamage is a damage found on one zone of the vehicle during an
+// inspection, with the photos and annotations that show it
+type InspectionDamage struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	InspectionID  uuid.UUID      `gorm:"type:uuid;not null;index" json:"inspection_id"`
+	VehicleID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"vehicle_id"`
+	Zone          DamageZone     `gorm:"not null" json:"zone"`
+	Type          DamageType     `gorm:"not null" json:"type"`
+	Severity      DamageSeverity `gorm:"not null" json:"severity"`
+	Notes         string         `json:"notes,omitempty"`
+	PhotoIDs      []uuid.UUID    `gorm:"type:jsonb;serializer:json" json:"photo_ids"`
+	AnnotationIDs []string       `gorm:"type:jsonb;serializer:json" json:"annotation_ids"` // InspectionAnnotation IDs on those photos
+	Photos        []DamagePhoto  `gorm:"-" json:"photos,omitempty"`                        // signed links, filled for responses
+	RecordedByID  *uuid.UUID     `gorm:"type:uuid" json:"recorded_by_id,omitempty"`        // left out for clients
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// DamagePhoto links to a photo of a damage
+type DamagePhoto struct {
+	ID        uuid.UUID `json:"id"`
+	Thumbnail string    `json:"thumbnail,omitempty"`
+	Display   string    `json:"display,omitempty"`
+}
+
+// BeforeCreate hook
+func (d *InspectionDamage) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// DamageInput creates or replaces a damage record
+type DamageInput struct {
+	Zone          DamageZone     `json:"zone"`
+	Type          DamageType     `json:"type"`
+	Severity      DamageSeverity `json:"severity"`
+	Notes         string         `json:"notes"`
+	PhotoIDs      []uuid.UUID    `json:"photo_ids"`
+	AnnotationIDs []string       `json:"annotation_ids"`
+}
+
+// Validate checks the zone, type and severity against the catalogue
+func (in *DamageInput) Validate() error {
+	if !in.Zone.Valid() {
+		return fmt.Errorf("%w: unknown zone %q", ErrInvalidDamage, in.Zone)
+	}
+	if !in.Type.Valid() {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidDamage, in.Type)
+	}
+	if !in.Severity.Valid() {
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidDamage, in.Severity)
+	}
+	if utf8.RuneCountInString(in.Notes) > maxDamageNotes {
+		return fmt.Errorf("%w: notes are longer than %d characters", ErrInvalidDamage, maxDamageNotes)
+	}
+	return nil
+}
+
+// ApplyTo copies the input into a damage record
+func (in *DamageInput) ApplyTo(d *InspectionDamage) {
+	d.Zone = in.Zone
+	d.Type = in.Type
+	d.Severity = in.Severity
+	d.Notes = strings.TrimSpace(in.Notes)
+	d.PhotoIDs = uniqueUUIDs(in.PhotoIDs)
+	d.AnnotationIDs = uniqueStrings(in.AnnotationIDs)
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if id != uuid.Nil && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// DamageFinding is one type of damage on one zone, with the records of the
+// entry and exit inspections that found it
+type DamageFinding struct {
+	Zone          DamageZone         `json:"zone"`
+	Type          DamageType         `json:"type"`
+	Severity      DamageSeverity     `json:"severity"`                 // the latest: at exit, or at entry when repaired or not compared
+	EntrySeverity DamageSeverity     `json:"entry_severity,omitempty"` // the most severe at entry, when compared
+	Change        DamageChange       `json:"change"`
+	Entry         []InspectionDamage `json:"entry,omitempty"`
+	Exit          []InspectionDamage `json:"exit,omitempty"`
+}
+
+// IsNew reports whether the damage appeared or got worse during the stay
+func (f *DamageFinding) IsNew() bool {
+	return f.Change == DamageChangeNew || f.Change == DamageChangeWorsened
+}
+
+type damageKey struct {
+	zone DamageZone
+	kind DamageType
+}
+
+// CompareDamages matches the damage recorded at exit against the damage
+// recorded at entry by zone and type. Several records of the same zone and
+// type are compared by their most severe.
+func CompareDamages(entry, exit []InspectionDamage) []DamageFinding {
+	findings := make(map[damageKey]*DamageFinding)
+	finding := func(d *InspectionDamage) *DamageFinding {
+		key := damageKey{d.Zone, d.Type}
+		if findings[key] == nil {
+			findings[key] = &DamageFinding{Zone: d.Zone, Type: d.Type}
+		}
+		return findings[key]
+	}
+	for i := range entry {
+		f := finding(&entry[i])
+		f.Entry = append(f.Entry, entry[i])
+	}
+	for i := range exit {
+		f := finding(&exit[i])
+		f.Exit = append(f.Exit, exit[i])
+	}
+
+	for _, f := range findings {
+		before, after := worstSeverity(f.Entry), worstSeverity(f.Exit)
+		f.EntrySeverity = before
+		switch {
+		case len(f.Exit) == 0:
+			f.Change, f.Severity = DamageChangeRepaired, before
+		case len(f.Entry) == 0:
+			f.Change, f.Severity = DamageChangeNew, after
+		case after.Rank() > before.Rank():
+			f.Change, f.Severity = DamageChangeWorsened, after
+		default:
+			f.Change, f.Severity = DamageChangePreExisting, after
+		}
+	}
+	return sortedFindings(findings)
+}
+
+// RecordedDamages lists the damage of a single inspection, for when there
+// is no other inspection to compare it with
+func RecordedDamages(damages []InspectionDamage) []DamageFinding {
+	findings := make(map[damageKey]*DamageFinding)
+	for i := range damages {
+		key := damageKey{damages[i].Zone, damages[i].Type}
+		if findings[key] == nil {
+			findings[key] = &DamageFinding{Zone: damages[i].Zone, Type: damages[i].Type, Change: DamageChangeRecorded}
+		}
+		findings[key].Entry = append(findings[key].Entry, damages[i])
+	}
+	for _, f := range findings {
+		f.Severity = worstSeverity(f.Entry)
+	}
+	return sortedFindings(findings)
+}
+
+func worstSeverity(damages []InspectionDamage) DamageSeverity {
+	var worst DamageSeverity
+	for _, d := range damages {
+		if worst == "" || d.Severity.Rank() > worst.Rank() {
+			worst = d.Severity
+		}
+	}
+	return worst
+}
+
+// sortedFindings orders findings as the catalogue: by zone, then type
+func sortedFindings(findings map[damageKey]*DamageFinding) []DamageFinding {
+	sorted := make([]DamageFinding, 0, len(findings))
+	for _, f := range findings {
+		sorted = append(sorted, *f)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		if za, zb := sorted[a].Zone.index(), sorted[b].Zone.index(); za != zb {
+			return za < zb
+		}
+		return catalogueIndex(damageTypes, string(sorted[a].Type)) < catalogueIndex(damageTypes, string(sorted[b].Type))
+	})
+	return sorted
+}
+
+// DamageMapInspection identifies an inspection a damage map is built from
+type DamageMapInspection struct {
+	ID          uuid.UUID        `json:"id"`
+	Type        InspectionType   `json:"type"`
+	Status      InspectionStatus `json:"status"`
+	StartedAt   time.Time        `json:"started_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// NewDamageMapInspection summarizes an inspection for a damage map
+func NewDamageMapInspection(i *Inspection) *DamageMapInspection {
+	return &DamageMapInspection{
+		ID:          i.ID,
+		Type:        i.Type,
+		Status:      i.Status,
+		StartedAt:   i.StartedAt,
+		CompletedAt: i.CompletedAt,
+	}
+}
+
+// DamageMapZone is a zone of the catalogue with the damage found on it
+type DamageMapZone struct {
+	DamageZoneInfo
+	Findings []DamageFinding `json:"findings"`
+}
+
+// VehicleDamageMap is the damage of a vehicle zone by zone, comparing its
+// latest entry inspection with the exit inspection that followed it
+type VehicleDamageMap struct {
+	VehicleID uuid.UUID            `json:"vehicle_id"`
+	Entry     *DamageMapInspection `json:"entry,omitempty"`
+	Exit      *DamageMapInspection `json:"exit,omitempty"`
+	Zones     []DamageMapZone      `json:"zones"`      // every zone of the catalogue, in order
+	NewDamage int                  `json:"new_damage"` // new or worsened findings
+}
+
+// NewVehicleDamageMap lays findings out over the whole zone catalogue
+func NewVehicleDamageMap(vehicleID uuid.UUID, entry, exit *DamageMapInspection, findings []DamageFinding) *VehicleDamageMap {
+	m := &VehicleDamageMap{
+		VehicleID: vehicleID,
+		Entry:     entry,
+		Exit:      exit,
+		Zones:     make([]DamageMapZone, len(damageZones)),
+	}
+	for i, zone := range damageZones {
+		m.Zones[i] = DamageMapZone{DamageZoneInfo: zone, Findings: []DamageFinding{}}
+	}
+	for _, f := range findings {
+		if i := f.Zone.index(); i >= 0 {
+			m.Zones[i].Findings = append(m.Zones[i].Findings, f)
+		}
+		if f.IsNew() {
+			m.NewDamage++
+		}
+	}
+	return m
+}
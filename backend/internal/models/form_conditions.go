@@ -0,0 +1,438 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Condition operators
+const (
+	ConditionEquals     = "equals"
+	ConditionNotEquals  = "not_equals"
+	ConditionContains   = "contains"
+	ConditionIn         = "in"
+	ConditionGreater    = "gt"
+	ConditionGreaterEq  = "gte"
+	ConditionLess       = "lt"
+	ConditionLessEq     = "lte"
+	ConditionBetween    = "between"
+	ConditionIsEmpty    = "is_empty"
+	ConditionIsNotEmpty = "is_not_empty"
+)
+
+var conditionOperators = map[string]bool{
+	ConditionEquals:     true,
+	ConditionNotEquals:  true,
+	ConditionContains:   true,
+	ConditionIn:         true,
+	ConditionGreater:    true,
+	ConditionGreaterEq:  true,
+	ConditionLess:       true,
+	ConditionLessEq:     true,
+	ConditionBetween:    true,
+	ConditionIsEmpty:    true,
+	ConditionIsNotEmpty: true,
+}
+
+// FieldRef identifies a field across sections
+type FieldRef struct {
+	SectionID string
+	FieldID   string
+}
+
+func (r FieldRef) String() string {
+	return r.SectionID + "." + r.FieldID
+}
+
+// ConditionValues supplies field values while evaluating conditions
+type ConditionValues interface {
+	Value(ref FieldRef) interface{}
+}
+
+// Evaluate reports whether the condition holds. Field references are
+// resolved with resolve, relative to the section the condition belongs to.
+func (c *FieldCondition) Evaluate(values ConditionValues, resolve func(ref string) (FieldRef, bool)) bool {
+	switch {
+	case len(c.All) > 0:
+		for i := range c.All {
+			if !c.All[i].Evaluate(values, resolve) {
+				return false
+			}
+		}
+		return true
+	case len(c.Any) > 0:
+		for i := range c.Any {
+			if c.Any[i].Evaluate(values, resolve) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ref, ok := resolve(c.Field)
+	if !ok {
+		return false
+	}
+	value := values.Value(ref)
+
+	switch c.Operator {
+	case ConditionEquals:
+		return valuesEqual(value, c.Value)
+	case ConditionNotEquals:
+		return !valuesEqual(value, c.Value)
+	case ConditionContains:
+		switch v := value.(type) {
+		case string:
+			return strings.Contains(v, fmt.Sprint(c.Value))
+		case []interface{}:
+			for _, element := range v {
+				if valuesEqual(element, c.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	case ConditionIn:
+		for _, candidate := range toList(c.Value) {
+			if valuesEqual(value, candidate) {
+				return true
+			}
+		}
+		return false
+	case ConditionGreater, ConditionGreaterEq, ConditionLess, ConditionLessEq:
+		number, ok := toFloat(value)
+		if !ok {
+			return false
+		}
+		bound, ok := toFloat(c.Value)
+		if !ok {
+			return false
+		}
+		switch c.Operator {
+		case ConditionGreater:
+			return number > bound
+		case ConditionGreaterEq:
+			return number >= bound
+		case ConditionLess:
+			return number < bound
+		default:
+			return number <= bound
+		}
+	case ConditionBetween:
+		number, ok := toFloat(value)
+		if !ok {
+			return false
+		}
+		low, high, ok := betweenBounds(c.Value)
+		return ok && number >= low && number <= high
+	case ConditionIsEmpty:
+		return isEmptyValue(value)
+	case ConditionIsNotEmpty:
+		return !isEmptyValue(value)
+	}
+	return false
+}
+
+// Validate checks the shape of the condition tree: groups hold only
+// sub-conditions, leaves name a field and a known operator with a usable
+// value
+func (c *FieldCondition) Validate() error {
+	if len(c.All) > 0 || len(c.Any) > 0 {
+		if len(c.All) > 0 && len(c.Any) > 0 {
+			return fmt.Errorf("condition cannot combine all and any")
+		}
+		if c.Field != "" || c.Operator != "" {
+			return fmt.Errorf("group condition cannot also reference field %q", c.Field)
+		}
+		for _, group := range [][]FieldCondition{c.All, c.Any} {
+			for i := range group {
+				if err := group[i].Validate(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if c.Field == "" {
+		return fmt.Errorf("condition must reference a field")
+	}
+	if !conditionOperators[c.Operator] {
+		return fmt.Errorf("unknown condition operator %q on %s", c.Operator, c.Field)
+	}
+
+	switch c.Operator {
+	case ConditionGreater, ConditionGreaterEq, ConditionLess, ConditionLessEq:
+		if _, ok := toFloat(c.Value); !ok {
+			return fmt.Errorf("operator %s on %s requires a numeric value", c.Operator, c.Field)
+		}
+	case ConditionBetween:
+		if _, _, ok := betweenBounds(c.Value); !ok {
+			return fmt.Errorf("operator between on %s requires [min, max]", c.Field)
+		}
+	case ConditionIn:
+		if len(toList(c.Value)) == 0 {
+			return fmt.Errorf("operator in on %s requires a list of values", c.Field)
+		}
+	}
+	return nil
+}
+
+// References returns the raw field references of the condition tree
+func (c *FieldCondition) References() []string {
+	if len(c.All) == 0 && len(c.Any) == 0 {
+		return []string{c.Field}
+	}
+	var refs []string
+	for _, group := range [][]FieldCondition{c.All, c.Any} {
+		for i := range group {
+			refs = append(refs, group[i].References()...)
+		}
+	}
+	return refs
+}
+
+// ResolveRef resolves a condition reference made from sectionID. "field"
+// means a field of the same section, or the only field with that ID in the
+// form; "section.field" names the section explicitly.
+func (c *FormConfig) ResolveRef(sectionID, ref string) (FieldRef, bool) {
+	if i := strings.IndexByte(ref, '.'); i >= 0 {
+		target := FieldRef{SectionID: ref[:i], FieldID: ref[i+1:]}
+		_, ok := c.field(target)
+		return target, ok
+	}
+
+	local := FieldRef{SectionID: sectionID, FieldID: ref}
+	if _, ok := c.field(local); ok {
+		return local, true
+	}
+
+	var found []FieldRef
+	for _, section := range c.Sections {
+		for _, field := range section.Fields {
+			if field.ID == ref {
+				found = append(found, FieldRef{SectionID: section.ID, FieldID: field.ID})
+			}
+		}
+	}
+	if len(found) != 1 {
+		return FieldRef{}, false
+	}
+	return found[0], true
+}
+
+func (c *FormConfig) field(ref FieldRef) (*FormField, bool) {
+	for i := range c.Sections {
+		if c.Sections[i].ID != ref.SectionID {
+			continue
+		}
+		for j := range c.Sections[i].Fields {
+			if c.Sections[i].Fields[j].ID == ref.FieldID {
+				return &c.Sections[i].Fields[j], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ValidateConditions checks every section and field condition: operators
+// and values must be valid, references must name existing fields, and no
+// field or section may depend on itself through other conditions
+func (c *FormConfig) ValidateConditions() error {
+	// A field depends on its section and on the fields its condition
+	// references; a section depends on the fields its condition references
+	graph := make(map[string][]string)
+
+	addDeps := func(node, sectionID string, condition *FieldCondition) error {
+		if err := condition.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", node, err)
+		}
+		for _, raw := range condition.References() {
+			ref, ok := c.ResolveRef(sectionID, raw)
+			if !ok {
+				return fmt.Errorf("%s: condition references unknown or ambiguous field %q", node, raw)
+			}
+			graph[node] = append(graph[node], "field "+ref.String())
+		}
+		return nil
+	}
+
+	for _, section := range c.Sections {
+		sectionNode := "section " + section.ID
+		if section.Conditional != nil {
+			if err := addDeps(sectionNode, section.ID, section.Conditional); err != nil {
+				return err
+			}
+		}
+		for _, field := range section.Fields {
+			fieldNode := "field " + FieldRef{SectionID: section.ID, FieldID: field.ID}.String()
+			graph[fieldNode] = append(graph[fieldNode], sectionNode)
+			if field.Conditional != nil {
+				if err := addDeps(fieldNode, section.ID, field.Conditional); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if cycle := findCycle(graph); cycle != nil {
+		return fmt.Errorf("conditional cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// findCycle returns one dependency cycle of graph, or nil
+func findCycle(graph map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycle []string
+
+	var visit func(node string) bool
+	visit = func(node string) bool {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range graph[node] {
+			switch state[next] {
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						cycle = append(append([]string(nil), stack[i:]...), next)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = done
+		return false
+	}
+
+	// Deterministic order so the reported cycle is stable
+	nodes := make([]string, 0, len(graph))
+	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if state[node] == unvisited && visit(node) {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// formActivity decides which sections and fields of a filled form are
+// active. Values of inactive fields read as empty, so a field that depends
+// on a hidden field sees no answer.
+type formActivity struct {
+	config   *FormConfig
+	form     *filledForm
+	memo     map[string]bool
+	visiting map[string]bool
+}
+
+func newFormActivity(config *FormConfig, form *filledForm) *formActivity {
+	return &formActivity{
+		config:   config,
+		form:     form,
+		memo:     make(map[string]bool),
+		visiting: make(map[string]bool),
+	}
+}
+
+// Value implements ConditionValues
+func (a *formActivity) Value(ref FieldRef) interface{} {
+	if !a.fieldActive(ref.SectionID, ref.FieldID) {
+		return nil
+	}
+	return a.form.value(ref.SectionID, ref.FieldID)
+}
+
+func (a *formActivity) sectionActive(sectionID string) bool {
+	key := "section " + sectionID
+	return a.memoize(key, func() bool {
+		for i := range a.config.Sections {
+			section := &a.config.Sections[i]
+			if section.ID == sectionID {
+				return a.holds(section.Conditional, section.ID)
+			}
+		}
+		return false
+	})
+}
+
+func (a *formActivity) fieldActive(sectionID, fieldID string) bool {
+	key := "field " + sectionID + "." + fieldID
+	return a.memoize(key, func() bool {
+		if !a.sectionActive(sectionID) {
+			return false
+		}
+		field, ok := a.config.field(FieldRef{SectionID: sectionID, FieldID: fieldID})
+		return ok && a.holds(field.Conditional, sectionID)
+	})
+}
+
+func (a *formActivity) holds(condition *FieldCondition, sectionID string) bool {
+	if condition == nil {
+		return true
+	}
+	return condition.Evaluate(a, func(ref string) (FieldRef, bool) {
+		return a.config.ResolveRef(sectionID, ref)
+	})
+}
+
+// memoize caches compute; templates saved before cycle checks existed are
+// treated as hidden instead of recursing forever
+func (a *formActivity) memoize(key string, compute func() bool) bool {
+	if active, ok := a.memo[key]; ok {
+		return active
+	}
+	if a.visiting[key] {
+		return false
+	}
+	a.visiting[key] = true
+	active := compute()
+	delete(a.visiting, key)
+	a.memo[key] = active
+	return active
+}
+
+func toList(value interface{}) []interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+func betweenBounds(value interface{}) (float64, float64, bool) {
+	bounds := toList(value)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+	low, ok := toFloat(bounds[0])
+	if !ok {
+		return 0, 0, false
+	}
+	high, ok := toFloat(bounds[1])
+	if !ok {
+		return 0, 0, false
+	}
+	return low, high, low <= high
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// conditionValues serves field values keyed by "section.field"
+type conditionValues map[string]interface{}
+
+func (v conditionValues) Value(ref FieldRef) interface{} {
+	return v[ref.String()]
+}
+
+// conditionForm has an "exterior" and an "interior" section that both hold
+// a "notes" field, so bare "notes" references are ambiguous outside them
+var conditionForm = FormConfig{Sections: []FormSection{
+	{ID: "exterior", Fields: []FormField{{ID: "damage"}, {ID: "count"}, {ID: "notes"}}},
+	{ID: "interior", Fields: []FormField{{ID: "smell"}, {ID: "notes"}}},
+	{ID: "engine", Fields: []FormField{{ID: "km"}}},
+}}
+
+func TestFieldConditionEvaluate(t *testing.T) {
+	values := conditionValues{
+		"exterior.damage": "yes",
+		"exterior.count":  3.0,
+		"exterior.notes":  "dent on the left door",
+		"interior.smell":  []interface{}{"smoke", "pets"},
+		"engine.km":       "42000",
+	}
+
+	tests := []struct {
+		name      string
+		condition FieldCondition
+		want      bool
+	}{
+		{"equals", FieldCondition{Field: "damage", Operator: ConditionEquals, Value: "yes"}, true},
+		{"equals compares numbers by value", FieldCondition{Field: "count", Operator: ConditionEquals, Value: "3"}, true},
+		{"not equals", FieldCondition{Field: "damage", Operator: ConditionNotEquals, Value: "yes"}, false},
+		{"contains substring", FieldCondition{Field: "exterior.notes", Operator: ConditionContains, Value: "door"}, true},
+		{"contains list element", FieldCondition{Field: "smell", Operator: ConditionContains, Value: "pets"}, true},
+		{"contains missing element", FieldCondition{Field: "smell", Operator: ConditionContains, Value: "mould"}, false},
+		{"in", FieldCondition{Field: "count", Operator: ConditionIn, Value: []interface{}{1.0, 2.0, 3.0}}, true},
+		{"not in", FieldCondition{Field: "damage", Operator: ConditionIn, Value: []string{"no", "unknown"}}, false},
+		{"gt", FieldCondition{Field: "count", Operator: ConditionGreater, Value: 3}, false},
+		{"gte", FieldCondition{Field: "count", Operator: ConditionGreaterEq, Value: 3}, true},
+		{"lt on a numeric string", FieldCondition{Field: "km", Operator: ConditionLess, Value: 50000}, true},
+		{"lte", FieldCondition{Field: "km", Operator: ConditionLessEq, Value: 40000}, false},
+		{"comparing text is false", FieldCondition{Field: "damage", Operator: ConditionGreater, Value: 0}, false},
+		{"between includes its bounds", FieldCondition{Field: "count", Operator: ConditionBetween, Value: []interface{}{1, 3}}, true},
+		{"between outside", FieldCondition{Field: "km", Operator: ConditionBetween, Value: []interface{}{0, 10000}}, false},
+		{"between with reversed bounds", FieldCondition{Field: "count", Operator: ConditionBetween, Value: []interface{}{5, 1}}, false},
+		{"is empty", FieldCondition{Field: "interior.notes", Operator: ConditionIsEmpty}, true},
+		{"is not empty", FieldCondition{Field: "smell", Operator: ConditionIsNotEmpty}, true},
+		{"unknown field", FieldCondition{Field: "wheels", Operator: ConditionIsEmpty}, false},
+		{"ambiguous field", FieldCondition{Field: "notes", Operator: ConditionIsEmpty}, false},
+		{"unknown operator", FieldCondition{Field: "damage", Operator: "like", Value: "y"}, false},
+		{
+			name: "all holds when every condition holds",
+			condition: FieldCondition{All: []FieldCondition{
+				{Field: "damage", Operator: ConditionEquals, Value: "yes"},
+				{Field: "count", Operator: ConditionGreater, Value: 2},
+			}},
+			want: true,
+		},
+		{
+			name: "all fails on one condition",
+			condition: FieldCondition{All: []FieldCondition{
+				{Field: "damage", Operator: ConditionEquals, Value: "yes"},
+				{Field: "count", Operator: ConditionGreater, Value: 5},
+			}},
+			want: false,
+		},
+		{
+			name: "any holds on one condition",
+			condition: FieldCondition{Any: []FieldCondition{
+				{Field: "damage", Operator: ConditionEquals, Value: "no"},
+				{Field: "smell", Operator: ConditionContains, Value: "smoke"},
+			}},
+			want: true,
+		},
+		{
+			name: "any fails when none holds",
+			condition: FieldCondition{Any: []FieldCondition{
+				{Field: "damage", Operator: ConditionEquals, Value: "no"},
+				{Field: "engine.km", Operator: ConditionGreater, Value: 100000},
+			}},
+			want: false,
+		},
+		{
+			name: "nested groups",
+			condition: FieldCondition{All: []FieldCondition{
+				{Field: "damage", Operator: ConditionEquals, Value: "yes"},
+				{Any: []FieldCondition{
+					{Field: "count", Operator: ConditionGreater, Value: 10},
+					{Field: "exterior.notes", Operator: ConditionContains, Value: "dent"},
+				}},
+			}},
+			want: true,
+		},
+	}
+
+	resolve := func(ref string) (FieldRef, bool) {
+		return conditionForm.ResolveRef("exterior", ref)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.Evaluate(values, resolve); got != tt.want {
+				t.Fatalf("Evaluate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormConfigResolveRef(t *testing.T) {
+	tests := []struct {
+		section, ref string
+		want         FieldRef
+		ok           bool
+	}{
+		{"exterior", "damage", FieldRef{"exterior", "damage"}, true},
+		{"exterior", "notes", FieldRef{"exterior", "notes"}, true},
+		{"interior", "notes", FieldRef{"interior", "notes"}, true},
+		{"engine", "notes", FieldRef{}, false},
+		{"engine", "smell", FieldRef{"interior", "smell"}, true},
+		{"engine", "exterior.notes", FieldRef{"exterior", "notes"}, true},
+		{"engine", "exterior.smell", FieldRef{"exterior", "smell"}, false},
+		{"engine", "wheels", FieldRef{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := conditionForm.ResolveRef(tt.section, tt.ref)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("ResolveRef(%q, %q) = %v, %v; want %v, %v", tt.section, tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFieldConditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition FieldCondition
+		err       string
+	}{
+		{"comparison", FieldCondition{Field: "count", Operator: ConditionGreater, Value: "2"}, ""},
+		{"group", FieldCondition{Any: []FieldCondition{{Field: "count", Operator: ConditionIsEmpty}}}, ""},
+		{"all and any together", FieldCondition{
+			All: []FieldCondition{{Field: "count", Operator: ConditionIsEmpty}},
+			Any: []FieldCondition{{Field: "count", Operator: ConditionIsEmpty}},
+		}, "cannot combine all and any"},
+		{"group with a field", FieldCondition{Field: "count", All: []FieldCondition{{Field: "count", Operator: ConditionIsEmpty}}}, "cannot also reference"},
+		{"invalid nested condition", FieldCondition{All: []FieldCondition{{Field: "count", Operator: "like"}}}, "unknown condition operator"},
+		{"missing field", FieldCondition{Operator: ConditionIsEmpty}, "must reference a field"},
+		{"unknown operator", FieldCondition{Field: "count", Operator: "like"}, "unknown condition operator"},
+		{"non-numeric bound", FieldCondition{Field: "count", Operator: ConditionLess, Value: "many"}, "requires a numeric value"},
+		{"between without two bounds", FieldCondition{Field: "count", Operator: ConditionBetween, Value: []interface{}{1}}, "requires [min, max]"},
+		{"in without a list", FieldCondition{Field: "count", Operator: ConditionIn, Value: "1"}, "requires a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.condition.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("Validate = %v, want nil", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("Validate = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFormConfigValidateConditions(t *testing.T) {
+	when := func(field string) *FieldCondition {
+		return &FieldCondition{Field: field, Operator: ConditionIsNotEmpty}
+	}
+
+	tests := []struct {
+		name     string
+		sections []FormSection
+		err      string
+	}{
+		{
+			name: "chain across sections",
+			sections: []FormSection{
+				{ID: "exterior", Fields: []FormField{{ID: "damage"}, {ID: "photos", Conditional: when("damage")}}},
+				{ID: "repair", Conditional: when("exterior.damage"), Fields: []FormField{{ID: "quote", Conditional: when("exterior.photos")}}},
+			},
+		},
+		{
+			name: "unknown reference",
+			sections: []FormSection{
+				{ID: "exterior", Fields: []FormField{{ID: "photos", Conditional: when("damage")}}},
+			},
+			err: `field exterior.photos: condition references unknown or ambiguous field "damage"`,
+		},
+		{
+			name: "invalid condition",
+			sections: []FormSection{
+				{ID: "exterior", Fields: []FormField{{ID: "damage"}, {ID: "photos", Conditional: &FieldCondition{Field: "damage", Operator: "like"}}}},
+			},
+			err: "field exterior.photos: unknown condition operator",
+		},
+		{
+			name: "field depending on itself",
+			sections: []FormSection{
+				{ID: "exterior", Fields: []FormField{{ID: "damage", Conditional: when("damage")}}},
+			},
+			err: "conditional cycle: field exterior.damage -> field exterior.damage",
+		},
+		{
+			name: "fields depending on each other",
+			sections: []FormSection{
+				{ID: "exterior", Fields: []FormField{{ID: "a", Conditional: when("b")}, {ID: "b", Conditional: when("a")}}},
+			},
+			err: "conditional cycle: field exterior.a -> field exterior.b -> field exterior.a",
+		},
+		{
+			name: "section depending on its own field",
+			sections: []FormSection{
+				{ID: "exterior", Conditional: when("damage"), Fields: []FormField{{ID: "damage"}}},
+			},
+			err: "conditional cycle: field exterior.damage -> section exterior -> field exterior.damage",
+		},
+		{
+			name: "cycle through a group across sections",
+			sections: []FormSection{
+				{ID: "exterior", Conditional: &FieldCondition{Any: []FieldCondition{
+					{Field: "interior.smell", Operator: ConditionIsEmpty},
+				}}, Fields: []FormField{{ID: "damage"}}},
+				{ID: "interior", Fields: []FormField{{ID: "smell", Conditional: when("exterior.damage")}}},
+			},
+			err: "conditional cycle:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := FormConfig{Sections: tt.sections}
+			err := config.ValidateConditions()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("ValidateConditions = %v, want nil", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("ValidateConditions = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// Hidden sections and fields are not validated, and conditions on a hidden
+// field see no value
+func TestValidateInspectionHiddenFields(t *testing.T) {
+	damaged := &FieldCondition{Field: "damage", Operator: ConditionEquals, Value: "yes"}
+	config := FormConfig{Sections: []FormSection{
+		{ID: "exterior", Name: "Exterior", Fields: []FormField{
+			{ID: "damage", Type: "select", Label: "Damage", Options: []string{"yes", "no"}},
+			{ID: "photos", Type: "photo", Label: "Damage photos", Required: true, Conditional: damaged},
+			{ID: "details", Type: "text", Label: "Details", Required: true, Conditional: damaged},
+		}},
+		{ID: "repair", Name: "Repair", Required: true,
+			Conditional: &FieldCondition{Field: "exterior.details", Operator: ConditionIsNotEmpty},
+			Fields:      []FormField{{ID: "quote", Type: "number", Label: "Quote", Required: true}},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		items []InspectionItem
+		rules []string
+	}{
+		{
+			name:  "no damage hides the photos, details and repair",
+			items: []InspectionItem{{ID: "damage", Value: "no"}},
+		},
+		{
+			name: "a hidden field's value does not show the repair",
+			items: []InspectionItem{
+				{ID: "damage", Value: "no"},
+				{ID: "details", Value: "dent on the left door"},
+			},
+		},
+		{
+			name:  "damage requires photos and details",
+			items: []InspectionItem{{ID: "damage", Value: "yes"}},
+			rules: []string{"required", "required"},
+		},
+		{
+			name: "details require the repair section",
+			items: []InspectionItem{
+				{ID: "damage", Value: "yes"},
+				{ID: "photos", Photos: []string{"a.jpg"}},
+				{ID: "details", Value: "dent on the left door"},
+			},
+			rules: []string{"section"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := config.ValidateInspection(validationInspection(tt.items...))
+			if got := validationRules(errs); !reflect.DeepEqual(got, tt.rules) {
+				t.Fatalf("rules = %v, want %v (%v)", got, tt.rules, errs)
+			}
+		})
+	}
+}
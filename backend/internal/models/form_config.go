@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +11,7 @@ import (
 // FormTemplate represents a customizable form template
 type FormTemplate struct {
 	ID          uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	FamilyID    uuid.UUID      `gorm:"type:uuid;index" json:"family_id"` // shared by every version of a template
 	Name        string         `gorm:"not null" json:"name"`
 	Type        string         `json:"type"` // inspection, checklist, etc
 	Version     int            `json:"version"`
@@ -28,12 +30,13 @@ type FormConfig struct {
 }
 
 type FormSection struct {
-	ID       string      `json:"id"`
-	Name     string      `json:"name"`
-	Icon     string      `json:"icon,omitempty"`
-	Fields   []FormField `json:"fields"`
-	Required bool        `json:"required"`
-	Order    int         `json:"order"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Icon        string          `json:"icon,omitempty"`
+	Fields      []FormField     `json:"fields"`
+	Required    bool            `json:"required"`
+	Order       int             `json:"order"`
+	Conditional *FieldCondition `json:"conditional,omitempty"` // hides the whole section
 }
 
 type FormField struct {
@@ -49,10 +52,15 @@ type FormField struct {
 	Order        int                    `json:"order"`
 }
 
+// FieldCondition is either a comparison on one field or an AND (All) / OR
+// (Any) group of conditions. Field is a field ID, or "section.field" to
+// reference another section.
 type FieldCondition struct {
-	Field    string      `json:"field"`    // field ID to check
-	Operator string      `json:"operator"` // equals, not_equals, contains
-	Value    interface{} `json:"value"`
+	Field    string           `json:"field,omitempty"`    // field ID to check
+	Operator string           `json:"operator,omitempty"` // equals, not_equals, contains, in, gt, gte, lt, lte, between, is_empty, is_not_empty
+	Value    interface{}      `json:"value,omitempty"`    // [min, max] for between, a list for in
+	All      []FieldCondition `json:"all,omitempty"`
+	Any      []FieldCondition `json:"any,omitempty"`
 }
 
 type FormSettings struct {
@@ -65,17 +73,37 @@ type FormSettings struct {
 	CompletionRequires []string `json:"completionRequires"`
 }
 
+// ToJSONB converts the config for storage in FormTemplate.Config
+func (c FormConfig) ToJSONB() JSONB {
+	data, _ := json.Marshal(c)
+	var out JSONB
+	json.Unmarshal(data, &out)
+	return out
+}
+
 // BeforeCreate hook
 func (f *FormTemplate) BeforeCreate(tx *gorm.DB) error {
 	if f.ID == uuid.Nil {
 		f.ID = uuid.New()
 	}
+	if f.FamilyID == uuid.Nil {
+		f.FamilyID = f.ID
+	}
 	if f.Version == 0 {
 		f.Version = 1
 	}
 	return nil
 }
 
+// Family returns the template family ID. Versions saved before families
+// existed are their own family.
+func (f *FormTemplate) Family() uuid.UUID {
+	if f.FamilyID == uuid.Nil {
+		return f.ID
+	}
+	return f.FamilyID
+}
+
 // Example of a default inspection form configuration
 func DefaultInspectionFormConfig() FormConfig {
 	return FormConfig{
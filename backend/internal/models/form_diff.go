@@ -0,0 +1,227 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Change kinds reported by DiffFormConfigs
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// ValueChange is one property that differs between two versions
+type ValueChange struct {
+	Property string      `json:"property"`
+	From     interface{} `json:"from"`
+	To       interface{} `json:"to"`
+}
+
+// FieldDiff describes how a field differs between two versions
+type FieldDiff struct {
+	ID             string        `json:"id"`
+	Label          string        `json:"label"`
+	Change         string        `json:"change"`
+	Changes        []ValueChange `json:"changes,omitempty"`
+	OptionsAdded   []string      `json:"options_added,omitempty"`
+	OptionsRemoved []string      `json:"options_removed,omitempty"`
+	Validation     []ValueChange `json:"validation,omitempty"`
+}
+
+// SectionDiff describes how a section and its fields differ
+type SectionDiff struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Change  string        `json:"change"`
+	Changes []ValueChange `json:"changes,omitempty"`
+	Fields  []FieldDiff   `json:"fields,omitempty"`
+}
+
+// FormDiff is the structural difference between two form configurations
+type FormDiff struct {
+	Sections []SectionDiff `json:"sections"`
+	Settings []ValueChange `json:"settings,omitempty"`
+}
+
+// Empty reports whether both configurations are structurally equal
+func (d FormDiff) Empty() bool {
+	return len(d.Sections) == 0 && len(d.Settings) == 0
+}
+
+// DiffFormConfigs compares two form configurations. Sections and fields are
+// matched by ID, so a renamed field shows up as a label change.
+func DiffFormConfigs(from, to FormConfig) FormDiff {
+	diff := FormDiff{Sections: []SectionDiff{}}
+
+	fromSections := make(map[string]FormSection, len(from.Sections))
+	for _, section := range from.Sections {
+		fromSections[section.ID] = section
+	}
+	toSections := make(map[string]bool, len(to.Sections))
+
+	for _, section := range to.Sections {
+		toSections[section.ID] = true
+		old, ok := fromSections[section.ID]
+		if !ok {
+			added := SectionDiff{ID: section.ID, Name: section.Name, Change: DiffAdded}
+			for _, field := range section.Fields {
+				added.Fields = append(added.Fields, FieldDiff{ID: field.ID, Label: field.Label, Change: DiffAdded})
+			}
+			diff.Sections = append(diff.Sections, added)
+			continue
+		}
+		if sectionDiff, changed := diffSection(old, section); changed {
+			diff.Sections = append(diff.Sections, sectionDiff)
+		}
+	}
+
+	for _, section := range from.Sections {
+		if toSections[section.ID] {
+			continue
+		}
+		removed := SectionDiff{ID: section.ID, Name: section.Name, Change: DiffRemoved}
+		for _, field := range section.Fields {
+			removed.Fields = append(removed.Fields, FieldDiff{ID: field.ID, Label: field.Label, Change: DiffRemoved})
+		}
+		diff.Sections = append(diff.Sections, removed)
+	}
+
+	diff.Settings = diffProperties([]property{
+		{"requireSignature", from.Settings.RequireSignature, to.Settings.RequireSignature},
+		{"requirePhotos", from.Settings.RequirePhotos, to.Settings.RequirePhotos},
+		{"minPhotosPerItem", from.Settings.MinPhotosPerItem, to.Settings.MinPhotosPerItem},
+		{"allowVoiceNotes", from.Settings.AllowVoiceNotes, to.Settings.AllowVoiceNotes},
+		{"allowSkipSections", from.Settings.AllowSkipSections, to.Settings.AllowSkipSections},
+		{"autoSaveInterval", from.Settings.AutoSaveInterval, to.Settings.AutoSaveInterval},
+		{"completionRequires", from.Settings.CompletionRequires, to.Settings.CompletionRequires},
+	})
+
+	return diff
+}
+
+func diffSection(from, to FormSection) (SectionDiff, bool) {
+	diff := SectionDiff{ID: to.ID, Name: to.Name, Change: DiffChanged}
+	diff.Changes = diffProperties([]property{
+		{"name", from.Name, to.Name},
+		{"icon", from.Icon, to.Icon},
+		{"required", from.Required, to.Required},
+		{"order", from.Order, to.Order},
+		{"conditional", from.Conditional, to.Conditional},
+	})
+
+	fromFields := make(map[string]FormField, len(from.Fields))
+	for _, field := range from.Fields {
+		fromFields[field.ID] = field
+	}
+	toFields := make(map[string]bool, len(to.Fields))
+
+	for _, field := range to.Fields {
+		toFields[field.ID] = true
+		old, ok := fromFields[field.ID]
+		if !ok {
+			diff.Fields = append(diff.Fields, FieldDiff{ID: field.ID, Label: field.Label, Change: DiffAdded})
+			continue
+		}
+		if fieldDiff, changed := diffField(old, field); changed {
+			diff.Fields = append(diff.Fields, fieldDiff)
+		}
+	}
+	for _, field := range from.Fields {
+		if !toFields[field.ID] {
+			diff.Fields = append(diff.Fields, FieldDiff{ID: field.ID, Label: field.Label, Change: DiffRemoved})
+		}
+	}
+
+	return diff, len(diff.Changes) > 0 || len(diff.Fields) > 0
+}
+
+func diffField(from, to FormField) (FieldDiff, bool) {
+	diff := FieldDiff{ID: to.ID, Label: to.Label, Change: DiffChanged}
+	diff.Changes = diffProperties([]property{
+		{"type", from.Type, to.Type},
+		{"label", from.Label, to.Label},
+		{"placeholder", from.Placeholder, to.Placeholder},
+		{"required", from.Required, to.Required},
+		{"defaultValue", from.DefaultValue, to.DefaultValue},
+		{"conditional", from.Conditional, to.Conditional},
+		{"order", from.Order, to.Order},
+	})
+	diff.OptionsAdded, diff.OptionsRemoved = diffOptions(from.Options, to.Options)
+
+	keys := make(map[string]bool)
+	for key := range from.Validation {
+		keys[key] = true
+	}
+	for key := range to.Validation {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	validation := make([]property, 0, len(sorted))
+	for _, key := range sorted {
+		validation = append(validation, property{key, from.Validation[key], to.Validation[key]})
+	}
+	diff.Validation = diffProperties(validation)
+
+	changed := len(diff.Changes) > 0 || len(diff.OptionsAdded) > 0 || len(diff.OptionsRemoved) > 0 || len(diff.Validation) > 0
+	return diff, changed
+}
+
+func diffOptions(from, to []string) (added, removed []string) {
+	old := make(map[string]bool, len(from))
+	for _, option := range from {
+		old[option] = true
+	}
+	current := make(map[string]bool, len(to))
+	for _, option := range to {
+		current[option] = true
+		if !old[option] {
+			added = append(added, option)
+		}
+	}
+	for _, option := range from {
+		if !current[option] {
+			removed = append(removed, option)
+		}
+	}
+	return added, removed
+}
+
+type property struct {
+	name     string
+	from, to interface{}
+}
+
+func diffProperties(properties []property) []ValueChange {
+	var changes []ValueChange
+	for _, p := range properties {
+		if !sameValue(p.from, p.to) {
+			changes = append(changes, ValueChange{Property: p.name, From: p.from, To: p.to})
+		}
+	}
+	return changes
+}
+
+// sameValue compares values through their JSON form, so 4 and 4.0 or a nil
+// and an empty list read as equal the way they do once stored
+func sameValue(a, b interface{}) bool {
+	if isEmptyValue(a) && isEmptyValue(b) {
+		return true
+	}
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	var va, vb interface{}
+	json.Unmarshal(ja, &va)
+	json.Unmarshal(jb, &vb)
+	return reflect.DeepEqual(va, vb)
+}
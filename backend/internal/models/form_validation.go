@@ -0,0 +1,330 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Completion requirements understood in FormSettings.CompletionRequires
+const (
+	CompletionAllRequiredFields = "all_required_fields"
+	CompletionSignature         = "signature"
+	CompletionMinPhotos         = "min_photos"
+	CompletionAllSections       = "all_sections"
+)
+
+// FieldError describes why one field of a filled inspection does not satisfy
+// its template. FieldID is empty for section and form level errors.
+type FieldError struct {
+	SectionID string `json:"section_id,omitempty"`
+	FieldID   string `json:"field_id,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Rule      string `json:"rule"` // required, min, max, pattern, options, minPhotos, maxPhotos, signature, section
+	Message   string `json:"message"`
+}
+
+// FormValidationErrors is the structured result of validating an inspection
+// against its FormTemplate
+type FormValidationErrors []FieldError
+
+func (e FormValidationErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "form is valid"
+	case 1:
+		return e[0].Message
+	}
+	return fmt.Sprintf("%s (and %d more)", e[0].Message, len(e)-1)
+}
+
+// ParseConfig decodes the template's JSONB config
+func (f *FormTemplate) ParseConfig() (FormConfig, error) {
+	var config FormConfig
+	data, err := json.Marshal(f.Config)
+	if err != nil {
+		return config, err
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
+
+// filledForm indexes the items of an inspection by form section and field ID
+type filledForm struct {
+	sections map[string]*InspectionSection
+	items    map[string]map[string]*InspectionItem
+}
+
+func newFilledForm(config *FormConfig, inspection *Inspection) *filledForm {
+	form := &filledForm{
+		sections: make(map[string]*InspectionSection),
+		items:    make(map[string]map[string]*InspectionItem),
+	}
+
+	for _, formSection := range config.Sections {
+		section, ok := inspection.GetSection(formSection.ID)
+		if !ok {
+			// Older clients keyed sections by display name
+			section, ok = inspection.GetSection(formSection.Name)
+		}
+		if !ok {
+			continue
+		}
+
+		form.sections[formSection.ID] = section
+		items := make(map[string]*InspectionItem, len(section.Items))
+		for i := range section.Items {
+			items[section.Items[i].ID] = &section.Items[i]
+		}
+		form.items[formSection.ID] = items
+	}
+	return form
+}
+
+func (f *filledForm) item(sectionID, fieldID string) *InspectionItem {
+	return f.items[sectionID][fieldID]
+}
+
+func (f *filledForm) value(sectionID, fieldID string) interface{} {
+	if item := f.item(sectionID, fieldID); item != nil {
+		return item.Value
+	}
+	return nil
+}
+
+// ValidateInspection checks a filled inspection against the template: the
+// Required flags and Validation rules of every visible field, select
+// options, photo counts, signatures and FormSettings.CompletionRequires.
+// Sections and fields hidden by their conditions are skipped. It returns nil
+// when the inspection is complete.
+func (c *FormConfig) ValidateInspection(inspection *Inspection) FormValidationErrors {
+	var errs FormValidationErrors
+	form := newFilledForm(c, inspection)
+	activity := newFormActivity(c, form)
+	settings := c.Settings
+
+	requires := make(map[string]bool)
+	for _, requirement := range settings.CompletionRequires {
+		requires[requirement] = true
+	}
+	// Without explicit requirements the Required flags still apply
+	enforceRequired := len(settings.CompletionRequires) == 0 || requires[CompletionAllRequiredFields]
+
+	for _, section := range c.Sections {
+		if !activity.sectionActive(section.ID) {
+			continue
+		}
+
+		filled, present := form.sections[section.ID]
+		if section.Required && !settings.AllowSkipSections && !present {
+			errs = append(errs, FieldError{
+				SectionID: section.ID,
+				Label:     section.Name,
+				Rule:      "section",
+				Message:   fmt.Sprintf("Section %q is required", section.Name),
+			})
+			continue
+		}
+		if requires[CompletionAllSections] && present && filled.CompletedAt == nil {
+			errs = append(errs, FieldError{
+				SectionID: section.ID,
+				Label:     section.Name,
+				Rule:      "section",
+				Message:   fmt.Sprintf("Section %q is not completed", section.Name),
+			})
+		}
+
+		for _, field := range section.Fields {
+			if !activity.fieldActive(section.ID, field.ID) {
+				continue
+			}
+
+			item := form.item(section.ID, field.ID)
+			errs = append(errs, validateField(section, field, item, settings, enforceRequired, requires[CompletionMinPhotos])...)
+		}
+	}
+
+	if (settings.RequireSignature || requires[CompletionSignature]) && strings.TrimSpace(inspection.Signature) == "" {
+		errs = append(errs, FieldError{
+			Rule:    "signature",
+			Message: "Inspection must be signed",
+		})
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateField(section FormSection, field FormField, item *InspectionItem, settings FormSettings, enforceRequired, enforceMinPhotos bool) []FieldError {
+	var errs []FieldError
+	fail := func(rule, format string, args ...interface{}) {
+		errs = append(errs, FieldError{
+			SectionID: section.ID,
+			FieldID:   field.ID,
+			Label:     field.Label,
+			Rule:      rule,
+			Message:   fmt.Sprintf("%s: ", field.Label) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	if field.Type == "photo" {
+		var photos int
+		if item != nil {
+			photos = len(item.Photos)
+		}
+
+		minPhotos, hasMin := validationNumber(field.Validation, "minPhotos")
+		if (enforceMinPhotos || settings.RequirePhotos) && float64(settings.MinPhotosPerItem) > minPhotos {
+			minPhotos, hasMin = float64(settings.MinPhotosPerItem), true
+		}
+		mustHavePhotos := (field.Required && enforceRequired) || enforceMinPhotos || settings.RequirePhotos
+
+		switch {
+		case photos == 0 && mustHavePhotos:
+			fail("required", "at least %d photo(s) required", maxInt(1, int(minPhotos)))
+		case photos > 0 && hasMin && float64(photos) < minPhotos:
+			fail("minPhotos", "at least %d photo(s) required, got %d", int(minPhotos), photos)
+		}
+		if maxPhotos, ok := validationNumber(field.Validation, "maxPhotos"); ok && float64(photos) > maxPhotos {
+			fail("maxPhotos", "at most %d photo(s) allowed, got %d", int(maxPhotos), photos)
+		}
+		return errs
+	}
+
+	var value interface{}
+	if item != nil {
+		value = item.Value
+	}
+	if isEmptyValue(value) {
+		if field.Required && enforceRequired {
+			fail("required", "is required")
+		}
+		return errs
+	}
+
+	switch field.Type {
+	case "number":
+		number, ok := toFloat(value)
+		if !ok {
+			fail("type", "must be a number")
+			return errs
+		}
+		if min, ok := validationNumber(field.Validation, "min"); ok && number < min {
+			fail("min", "must be at least %v", min)
+		}
+		if max, ok := validationNumber(field.Validation, "max"); ok && number > max {
+			fail("max", "must be at most %v", max)
+		}
+
+	case "select", "radio":
+		if len(field.Options) > 0 && !containsOption(field.Options, fmt.Sprint(value)) {
+			fail("options", "%q is not one of the allowed options", fmt.Sprint(value))
+		}
+
+	case "checkbox":
+		// A checkbox with options is a multi-select
+		if list, ok := value.([]interface{}); ok && len(field.Options) > 0 {
+			for _, v := range list {
+				if !containsOption(field.Options, fmt.Sprint(v)) {
+					fail("options", "%q is not one of the allowed options", fmt.Sprint(v))
+				}
+			}
+		}
+
+	default:
+		text, ok := value.(string)
+		if !ok {
+			text = fmt.Sprint(value)
+		}
+		length := float64(len([]rune(text)))
+		if min, ok := validationNumber(field.Validation, "min"); ok && length < min {
+			fail("min", "must have at least %v characters", min)
+		}
+		if max, ok := validationNumber(field.Validation, "max"); ok && length > max {
+			fail("max", "must have at most %v characters", max)
+		}
+		if pattern, ok := field.Validation["pattern"].(string); ok && pattern != "" {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("pattern", "template pattern is invalid")
+			} else if !re.MatchString(text) {
+				fail("pattern", "has an invalid format")
+			}
+		}
+	}
+
+	return errs
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	}
+	return false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			return af == bf
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func validationNumber(validation map[string]interface{}, key string) (float64, bool) {
+	raw, ok := validation[key]
+	if !ok {
+		return 0, false
+	}
+	return toFloat(raw)
+}
+
+func containsOption(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
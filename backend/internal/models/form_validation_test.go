@@ -0,0 +1,232 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// validationInspection fills section "exterior" with items, or leaves it out
+// when items is nil
+func validationInspection(items ...InspectionItem) *Inspection {
+	inspection := &Inspection{Sections: JSONB{}}
+	if items != nil {
+		inspection.Sections["exterior"] = InspectionSection{Name: "Exterior", Items: items}
+	}
+	return inspection
+}
+
+func validationRules(errs FormValidationErrors) []string {
+	var rules []string
+	for _, e := range errs {
+		rules = append(rules, e.Rule)
+	}
+	return rules
+}
+
+func TestValidateFieldRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    FormField
+		item     *InspectionItem
+		settings FormSettings
+		rules    []string
+	}{
+		{
+			name:  "required field missing",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Required: true},
+			rules: []string{"required"},
+		},
+		{
+			name:  "required field blank",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Required: true},
+			item:  &InspectionItem{ID: "plate", Value: "   "},
+			rules: []string{"required"},
+		},
+		{
+			name:  "required field filled",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Required: true},
+			item:  &InspectionItem{ID: "plate", Value: "ABC123"},
+		},
+		{
+			name:  "optional field empty",
+			field: FormField{ID: "notes", Type: "text", Label: "Notes", Validation: map[string]interface{}{"min": 10}},
+		},
+		{
+			name:     "required not enforced when completion names other requirements",
+			field:    FormField{ID: "plate", Type: "text", Label: "Plate", Required: true},
+			settings: FormSettings{CompletionRequires: []string{CompletionSignature}},
+			rules:    []string{"signature"},
+		},
+		{
+			name:  "number below min",
+			field: FormField{ID: "km", Type: "number", Label: "Mileage", Validation: map[string]interface{}{"min": 0.0, "max": 999999.0}},
+			item:  &InspectionItem{ID: "km", Value: -1.0},
+			rules: []string{"min"},
+		},
+		{
+			name:  "number above max",
+			field: FormField{ID: "km", Type: "number", Label: "Mileage", Validation: map[string]interface{}{"min": 0.0, "max": 999999.0}},
+			item:  &InspectionItem{ID: "km", Value: "1000000"},
+			rules: []string{"max"},
+		},
+		{
+			name:  "number within bounds",
+			field: FormField{ID: "km", Type: "number", Label: "Mileage", Validation: map[string]interface{}{"min": 0.0, "max": 999999.0}},
+			item:  &InspectionItem{ID: "km", Value: 42000.0},
+		},
+		{
+			name:  "number that is not a number",
+			field: FormField{ID: "km", Type: "number", Label: "Mileage"},
+			item:  &InspectionItem{ID: "km", Value: "many"},
+			rules: []string{"type"},
+		},
+		{
+			name:  "text shorter than min counts runes",
+			field: FormField{ID: "notes", Type: "text", Label: "Notes", Validation: map[string]interface{}{"min": 4.0}},
+			item:  &InspectionItem{ID: "notes", Value: "añó"},
+			rules: []string{"min"},
+		},
+		{
+			name:  "text longer than max",
+			field: FormField{ID: "notes", Type: "text", Label: "Notes", Validation: map[string]interface{}{"max": 5.0}},
+			item:  &InspectionItem{ID: "notes", Value: "scratched"},
+			rules: []string{"max"},
+		},
+		{
+			name:  "pattern matches",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Validation: map[string]interface{}{"pattern": `^[A-Z]{3}[0-9]{3}$`}},
+			item:  &InspectionItem{ID: "plate", Value: "ABC123"},
+		},
+		{
+			name:  "pattern does not match",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Validation: map[string]interface{}{"pattern": `^[A-Z]{3}[0-9]{3}$`}},
+			item:  &InspectionItem{ID: "plate", Value: "abc-123"},
+			rules: []string{"pattern"},
+		},
+		{
+			name:  "invalid template pattern",
+			field: FormField{ID: "plate", Type: "text", Label: "Plate", Validation: map[string]interface{}{"pattern": `[`}},
+			item:  &InspectionItem{ID: "plate", Value: "ABC123"},
+			rules: []string{"pattern"},
+		},
+		{
+			name:  "select value outside options",
+			field: FormField{ID: "fuel", Type: "select", Label: "Fuel", Options: []string{"full", "half", "empty"}},
+			item:  &InspectionItem{ID: "fuel", Value: "quarter"},
+			rules: []string{"options"},
+		},
+		{
+			name:  "multi-select checkbox checks every value",
+			field: FormField{ID: "extras", Type: "checkbox", Label: "Extras", Options: []string{"jack", "spare"}},
+			item:  &InspectionItem{ID: "extras", Value: []interface{}{"jack", "radio", "manual"}},
+			rules: []string{"options", "options"},
+		},
+		{
+			name:  "required photo missing",
+			field: FormField{ID: "front", Type: "photo", Label: "Front", Required: true},
+			rules: []string{"required"},
+		},
+		{
+			name:  "fewer photos than minPhotos",
+			field: FormField{ID: "front", Type: "photo", Label: "Front", Validation: map[string]interface{}{"minPhotos": 2.0}},
+			item:  &InspectionItem{ID: "front", Photos: []string{"a.jpg"}},
+			rules: []string{"minPhotos"},
+		},
+		{
+			name:  "more photos than maxPhotos",
+			field: FormField{ID: "front", Type: "photo", Label: "Front", Validation: map[string]interface{}{"maxPhotos": 1.0}},
+			item:  &InspectionItem{ID: "front", Photos: []string{"a.jpg", "b.jpg"}},
+			rules: []string{"maxPhotos"},
+		},
+		{
+			name:     "form minimum raises the field minimum",
+			field:    FormField{ID: "front", Type: "photo", Label: "Front", Validation: map[string]interface{}{"minPhotos": 1.0}},
+			item:     &InspectionItem{ID: "front", Photos: []string{"a.jpg", "b.jpg"}},
+			settings: FormSettings{RequirePhotos: true, MinPhotosPerItem: 3},
+			rules:    []string{"minPhotos"},
+		},
+		{
+			name:     "photos required by the form",
+			field:    FormField{ID: "front", Type: "photo", Label: "Front"},
+			settings: FormSettings{CompletionRequires: []string{CompletionMinPhotos}},
+			rules:    []string{"required"},
+		},
+		{
+			name:     "signature required",
+			field:    FormField{ID: "plate", Type: "text", Label: "Plate"},
+			settings: FormSettings{RequireSignature: true},
+			rules:    []string{"signature"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := FormConfig{
+				Sections: []FormSection{{ID: "exterior", Name: "Exterior", Fields: []FormField{tt.field}}},
+				Settings: tt.settings,
+			}
+			items := []InspectionItem{}
+			if tt.item != nil {
+				items = append(items, *tt.item)
+			}
+
+			errs := config.ValidateInspection(validationInspection(items...))
+			if got := validationRules(errs); !reflect.DeepEqual(got, tt.rules) {
+				t.Fatalf("rules = %v, want %v (%v)", got, tt.rules, errs)
+			}
+			for _, e := range errs {
+				if e.Rule != "signature" && (e.SectionID != "exterior" || e.FieldID != tt.field.ID) {
+					t.Errorf("error %q is on %s.%s, want exterior.%s", e.Message, e.SectionID, e.FieldID, tt.field.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateInspectionSections(t *testing.T) {
+	config := FormConfig{Sections: []FormSection{
+		{ID: "exterior", Name: "Exterior", Required: true, Fields: []FormField{
+			{ID: "plate", Type: "text", Label: "Plate", Required: true},
+		}},
+	}}
+
+	tests := []struct {
+		name       string
+		settings   FormSettings
+		inspection *Inspection
+		rules      []string
+	}{
+		{
+			name:       "required section missing",
+			inspection: validationInspection(),
+			rules:      []string{"section"},
+		},
+		{
+			name:       "skippable sections may be missing",
+			settings:   FormSettings{AllowSkipSections: true},
+			inspection: validationInspection(),
+			rules:      []string{"required"},
+		},
+		{
+			name:       "uncompleted section when all sections are required",
+			settings:   FormSettings{CompletionRequires: []string{CompletionAllSections, CompletionAllRequiredFields}},
+			inspection: validationInspection(InspectionItem{ID: "plate", Value: "ABC123"}),
+			rules:      []string{"section"},
+		},
+		{
+			name:       "section keyed by its display name",
+			inspection: &Inspection{Sections: JSONB{"Exterior": InspectionSection{Items: []InspectionItem{{ID: "plate", Value: "ABC123"}}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config
+			config.Settings = tt.settings
+			errs := config.ValidateInspection(tt.inspection)
+			if got := validationRules(errs); !reflect.DeepEqual(got, tt.rules) {
+				t.Fatalf("rules = %v, want %v (%v)", got, tt.rules, errs)
+			}
+		})
+	}
+}
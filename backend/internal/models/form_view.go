@@ -0,0 +1,126 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// FormFieldView pairs a template field with the answer recorded for it
+type FormFieldView struct {
+	FormField
+	Visible  bool                 `json:"visible"` // false when the field or its section is hidden
+	Answered bool                 `json:"answered"`
+	Value    interface{}          `json:"value,omitempty"`
+	Status   InspectionItemStatus `json:"status,omitempty"`
+	Notes    string               `json:"notes,omitempty"`
+	Photos   []string             `json:"photos,omitempty"`
+}
+
+// FormSectionView is one template section with the answers of an inspection.
+// Unmatched holds recorded items the template has no field for.
+type FormSectionView struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Icon        string           `json:"icon,omitempty"`
+	Required    bool             `json:"required"`
+	InTemplate  bool             `json:"in_template"`
+	Visible     bool             `json:"visible"` // false when hidden by its condition
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Notes       string           `json:"notes,omitempty"`
+	Photos      []string         `json:"photos,omitempty"`
+	Fields      []FormFieldView  `json:"fields"`
+	Unmatched   []InspectionItem `json:"unmatched,omitempty"`
+}
+
+// SideBySide lays the inspection's answers next to the template's sections
+// and fields, in template order and with the template's labels. Sections and
+// items the template does not know are appended so nothing is lost.
+func (c *FormConfig) SideBySide(inspection *Inspection) []FormSectionView {
+	form := newFilledForm(c, inspection)
+	activity := newFormActivity(c, form)
+
+	sections := append([]FormSection(nil), c.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Order < sections[j].Order })
+
+	matchedSections := make(map[string]bool)
+	views := make([]FormSectionView, 0, len(sections))
+	for _, section := range sections {
+		view := FormSectionView{
+			ID:         section.ID,
+			Name:       section.Name,
+			Icon:       section.Icon,
+			Required:   section.Required,
+			InTemplate: true,
+			Visible:    activity.sectionActive(section.ID),
+		}
+
+		filled := form.sections[section.ID]
+		if filled != nil {
+			matchedSections[section.ID] = true
+			matchedSections[section.Name] = true
+			view.CompletedAt = filled.CompletedAt
+			view.Notes = filled.Notes
+			view.Photos = filled.Photos
+		}
+
+		fields := append([]FormField(nil), section.Fields...)
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Order < fields[j].Order })
+
+		known := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			known[field.ID] = true
+			fieldView := FormFieldView{
+				FormField: field,
+				Visible:   activity.fieldActive(section.ID, field.ID),
+			}
+			if item := form.items[section.ID][field.ID]; item != nil {
+				fieldView.Answered = !isEmptyValue(item.Value) || len(item.Photos) > 0
+				fieldView.Value = item.Value
+				fieldView.Status = item.Status
+				fieldView.Notes = item.Notes
+				fieldView.Photos = item.Photos
+			}
+			view.Fields = append(view.Fields, fieldView)
+		}
+
+		if filled != nil {
+			for _, item := range filled.Items {
+				if !known[item.ID] {
+					view.Unmatched = append(view.Unmatched, item)
+				}
+			}
+		}
+		views = append(views, view)
+	}
+
+	// Sections recorded outside the template, in name order
+	var extra []string
+	for key := range inspection.Sections {
+		if !matchedSections[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		section, ok := inspection.GetSection(key)
+		if !ok {
+			continue
+		}
+		name := section.Name
+		if name == "" {
+			name = key
+		}
+		views = append(views, FormSectionView{
+			ID:          key,
+			Name:        name,
+			Visible:     true,
+			CompletedAt: section.CompletedAt,
+			Notes:       section.Notes,
+			Photos:      section.Photos,
+			Fields:      []FormFieldView{},
+			Unmatched:   section.Items,
+		})
+	}
+
+	return views
+}
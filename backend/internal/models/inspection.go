@@ -10,22 +10,27 @@ import (
 )
 
 type Inspection struct {
-	ID          uuid.UUID        `gorm:"type:uuid;primary_key" json:"id"`
-	VehicleID   uuid.UUID        `gorm:"type:uuid;not null" json:"vehicle_id"`
-	Vehicle     *Vehicle         `json:"vehicle,omitempty"`
-	InspectorID uuid.UUID        `gorm:"type:uuid;not null" json:"inspector_id"`
-	Inspector   *User            `json:"inspector,omitempty"`
-	Type        InspectionType   `json:"type"`
-	Status      InspectionStatus `json:"status"`
-	Sections    JSONB            `gorm:"type:jsonb" json:"sections"`
-	Summary     string           `json:"summary"`
-	StartedAt   time.Time        `json:"started_at"`
-	CompletedAt *time.Time       `json:"completed_at,omitempty"`
-	Version     int              `json:"version"`
-	PDFUrl      string           `json:"pdf_url,omitempty"`
-	Signature   string           `json:"signature,omitempty"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
+	ID                  uuid.UUID        `gorm:"type:uuid;primary_key" json:"id"`
+	VehicleID           uuid.UUID        `gorm:"type:uuid;not null" json:"vehicle_id"`
+	Vehicle             *Vehicle         `json:"vehicle,omitempty"`
+	InspectorID         uuid.UUID        `gorm:"type:uuid;not null" json:"inspector_id"`
+	Inspector           *User            `json:"inspector,omitempty"`
+	Type                InspectionType   `json:"type"`
+	FormTemplateID      *uuid.UUID       `gorm:"type:uuid" json:"form_template_id,omitempty"` // nil for free-form inspections
+	FormTemplateVersion int              `json:"form_template_version,omitempty"`
+	Status              InspectionStatus `json:"status"`
+	Sections            JSONB            `gorm:"type:jsonb" json:"sections"`
+	Summary             string           `json:"summary"`
+	StartedAt           time.Time        `json:"started_at"`
+	CompletedAt         *time.Time       `json:"completed_at,omitempty"`
+	ReviewedByID        *uuid.UUID       `gorm:"type:uuid" json:"reviewed_by_id,omitempty"` // supervisor who approved or rejected it
+	ReviewedAt          *time.Time       `json:"reviewed_at,omitempty"`
+	StatusReason        string           `json:"status_reason,omitempty"` // why it was rejected or reopened
+	Version             int              `json:"version"`
+	PDFUrl              string           `json:"pdf_url,omitempty"`
+	Signature           string           `json:"signature,omitempty"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
 }
 
 type InspectionType string
@@ -44,6 +49,7 @@ const (
 	InspectionStatusInProgress InspectionStatus = "in_progress"
 	InspectionStatusCompleted  InspectionStatus = "completed"
 	InspectionStatusApproved   InspectionStatus = "approved"
+	InspectionStatusRejected   InspectionStatus = "rejected" // sent back by a supervisor; editable again
 )
 
 // JSONB type for PostgreSQL jsonb columns
@@ -93,14 +99,19 @@ const (
 	ItemStatusPending InspectionItemStatus = "pending"
 )
 
-// InspectionAnnotation for photo annotations
+// InspectionAnnotation is a shape drawn on one of the item's photos.
+// Coordinates are normalized to the upright photo, see AnnotationInput.
 type InspectionAnnotation struct {
+	ID          string                 `json:"id"`
+	PhotoID     uuid.UUID              `json:"photo_id"`
 	Type        string                 `json:"type"` // arrow, circle, text, damage
 	Coordinates map[string]interface{} `json:"coordinates"`
 	Text        string                 `json:"text,omitempty"`
 	Color       string                 `json:"color,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
 	CreatedBy   uuid.UUID              `json:"created_by"`
+	UpdatedAt   *time.Time             `json:"updated_at,omitempty"`
+	UpdatedBy   *uuid.UUID             `json:"updated_by,omitempty"`
 }
 
 // Real-time update structure
@@ -111,8 +122,9 @@ type InspectionUpdate struct {
 	UpdatedBy    uuid.UUID              `json:"updated_by"`
 	Version      int                    `json:"version"`
 	Timestamp    time.Time              `json:"timestamp"`
-	Type         string                 `json:"type"` // field_update, photo_added, section_completed
+	Type         string                 `json:"type"` // field_update, photo_added, section_completed, update_conflict
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	EventID      string                 `json:"event_id,omitempty"` // position in the inspection's event stream
 }
 
 // BeforeCreate hook
@@ -139,7 +151,24 @@ func (i *Inspection) IsCompleted() bool {
 }
 
 func (i *Inspection) CanEdit() bool {
-	return i.Status == InspectionStatusDraft || i.Status == InspectionStatusInProgress
+	return i.Status == InspectionStatusDraft || i.Status == InspectionStatusInProgress ||
+		i.Status == InspectionStatusRejected
+}
+
+// HasFailedItems reports whether any item of any section failed
+func (i *Inspection) HasFailedItems() bool {
+	for name := range i.Sections {
+		section, ok := i.GetSection(name)
+		if !ok {
+			continue
+		}
+		for _, item := range section.Items {
+			if item.Status == ItemStatusFail {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (i *Inspection) GetSection(name string) (*InspectionSection, bool) {
@@ -158,4 +187,19 @@ func (i *Inspection) GetSection(name string) (*InspectionSection, bool) {
 func (i *Inspection) UpdateSection(name string, section *InspectionSection) {
 	i.Sections[name] = section
 	i.Version++
-}
\ No newline at end of file
+}
+
+// ItemStatusCounts counts the items of every section by status
+func (i *Inspection) ItemStatusCounts() map[InspectionItemStatus]int {
+	counts := make(map[InspectionItemStatus]int)
+	for name := range i.Sections {
+		section, ok := i.GetSection(name)
+		if !ok {
+			continue
+		}
+		for _, item := range section.Items {
+			counts[item.Status]++
+		}
+	}
+	return counts
+}
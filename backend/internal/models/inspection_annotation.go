@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidAnnotation is returned for annotations that cannot be drawn
+var ErrInvalidAnnotation = errors.New("invalid annotation")
+
+// Annotation types
+const (
+	AnnotationArrow  = "arrow"
+	AnnotationCircle = "circle"
+	AnnotationText   = "text"
+	AnnotationDamage = "damage"
+)
+
+// DefaultAnnotationColor is used for annotations without a colour
+const DefaultAnnotationColor = "#dc2626"
+
+const maxAnnotationText = 200
+
+// annotationCoordinates are the coordinates each type needs. All are
+// normalized to the upright photo so annotations survive resizing: x and
+// width are fractions of its width, y and height of its height, and radius
+// of its shorter side.
+var annotationCoordinates = map[string][]string{
+	AnnotationArrow:  {"x1", "y1", "x2", "y2"}, // from the tail to the head
+	AnnotationCircle: {"x", "y", "radius"},
+	AnnotationText:   {"x", "y"}, // top left corner of the label
+	AnnotationDamage: {"x", "y", "width", "height"},
+}
+
+var annotationColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// AnnotationInput creates or replaces an annotation on a photo
+type AnnotationInput struct {
+	Type        string             `json:"type"`
+	Coordinates map[string]float64 `json:"coordinates"`
+	Text        string             `json:"text"`
+	Color       string             `json:"color"`   // #rrggbb, DefaultAnnotationColor when empty
+	Version     int                `json:"version"` // inspection version the author last saw
+}
+
+// Validate checks the type, that exactly its coordinates are given and
+// that the shape lies inside the photo
+func (in *AnnotationInput) Validate() error {
+	names, ok := annotationCoordinates[in.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAnnotation, in.Type)
+	}
+	if len(in.Coordinates) != len(names) {
+		return fmt.Errorf("%w: %s needs coordinates %s", ErrInvalidAnnotation, in.Type, strings.Join(names, ", "))
+	}
+	for _, name := range names {
+		value, ok := in.Coordinates[name]
+		if !ok {
+			return fmt.Errorf("%w: %s needs coordinates %s", ErrInvalidAnnotation, in.Type, strings.Join(names, ", "))
+		}
+		if math.IsNaN(value) || value < 0 || value > 1 {
+			return fmt.Errorf("%w: %s must be between 0 and 1", ErrInvalidAnnotation, name)
+		}
+	}
+
+	c := in.Coordinates
+	switch in.Type {
+	case AnnotationArrow:
+		if c["x1"] == c["x2"] && c["y1"] == c["y2"] {
+			return fmt.Errorf("%w: arrow has no length", ErrInvalidAnnotation)
+		}
+	case AnnotationCircle:
+		if c["radius"] <= 0 || c["radius"] > 0.5 {
+			return fmt.Errorf("%w: radius must be above 0 and at most 0.5", ErrInvalidAnnotation)
+		}
+	case AnnotationText:
+		if strings.TrimSpace(in.Text) == "" {
+			return fmt.Errorf("%w: text annotations need text", ErrInvalidAnnotation)
+		}
+	case AnnotationDamage:
+		if c["width"] <= 0 || c["height"] <= 0 || c["x"]+c["width"] > 1 || c["y"]+c["height"] > 1 {
+			return fmt.Errorf("%w: damage area must lie inside the photo", ErrInvalidAnnotation)
+		}
+	}
+
+	if utf8.RuneCountInString(in.Text) > maxAnnotationText {
+		return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidAnnotation, maxAnnotationText)
+	}
+	if in.Color != "" && !annotationColorPattern.MatchString(in.Color) {
+		return fmt.Errorf("%w: color must be #rrggbb", ErrInvalidAnnotation)
+	}
+	return nil
+}
+
+// ApplyTo copies the input into an annotation
+func (in *AnnotationInput) ApplyTo(a *InspectionAnnotation) {
+	a.Type = in.Type
+	a.Coordinates = make(map[string]interface{}, len(in.Coordinates))
+	for name, value := range in.Coordinates {
+		a.Coordinates[name] = value
+	}
+	a.Text = strings.TrimSpace(in.Text)
+	a.Color = strings.ToLower(in.Color)
+	if a.Color == "" {
+		a.Color = DefaultAnnotationColor
+	}
+}
+
+// Coordinate returns a coordinate of the annotation, 0 when missing
+func (a *InspectionAnnotation) Coordinate(name string) float64 {
+	value, _ := a.Coordinates[name].(float64)
+	return value
+}
+
+// RGB returns the annotation colour, DefaultAnnotationColor when unset or
+// malformed
+func (a *InspectionAnnotation) RGB() (r, g, b uint8) {
+	color := a.Color
+	if !annotationColorPattern.MatchString(color) {
+		color = DefaultAnnotationColor
+	}
+	fmt.Sscanf(color, "#%02x%02x%02x", &r, &g, &b)
+	return r, g, b
+}
+
+// AnnotationsByPhoto collects the annotations of every item by the photo
+// they are drawn on. Annotations from before they were tied to a photo are
+// left out.
+func (i *Inspection) AnnotationsByPhoto() map[uuid.UUID][]InspectionAnnotation {
+	annotations := make(map[uuid.UUID][]InspectionAnnotation)
+	for name := range i.Sections {
+		section, ok := i.GetSection(name)
+		if !ok {
+			continue
+		}
+		for _, item := range section.Items {
+			for _, annotation := range item.Annotations {
+				if annotation.PhotoID != uuid.Nil {
+					annotations[annotation.PhotoID] = append(annotations[annotation.PhotoID], annotation)
+				}
+			}
+		}
+	}
+	// Drawn in creation order, later annotations on top
+	for _, list := range annotations {
+		sort.Slice(list, func(a, b int) bool {
+			if !list[a].CreatedAt.Equal(list[b].CreatedAt) {
+				return list[a].CreatedAt.Before(list[b].CreatedAt)
+			}
+			return list[a].ID < list[b].ID
+		})
+	}
+	return annotations
+}
+
+// Touch records who last edited the annotation
+func (a *InspectionAnnotation) Touch(userID uuid.UUID, at time.Time) {
+	a.UpdatedAt = &at
+	a.UpdatedBy = &userID
+}
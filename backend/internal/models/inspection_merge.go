@@ -0,0 +1,136 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of merging a real-time update with concurrent writes
+const (
+	MergeApply     = "apply"     // nobody else wrote an overlapping path
+	MergeAppend    = "append"    // list path; the value is appended
+	MergeOverwrite = "overwrite" // concurrent writes lost to this update
+	MergeReject    = "reject"    // a concurrent write is newer and wins
+)
+
+// listFields are path segments holding lists that concurrent updates append
+// to instead of overwriting
+var listFields = map[string]bool{
+	"photos": true,
+}
+
+// PathWrite records the last accepted write to one inspection path
+type PathWrite struct {
+	Path      string    `json:"path"`
+	Version   int       `json:"version"` // inspection version the write produced
+	UpdatedBy uuid.UUID `json:"updated_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MergeDecision is the result of ResolveInspectionUpdate. Conflicts lists
+// the concurrent writes to overlapping paths, oldest first; Winner is the
+// write that beat the update when it is rejected.
+type MergeDecision struct {
+	Outcome   string
+	Conflicts []PathWrite
+	Winner    *PathWrite
+}
+
+// ResolveInspectionUpdate decides how an update merges with the writes
+// accepted since the version its author last saw (update.Version - 1).
+// Updates to paths nobody else touched in the meantime merge as they are;
+// overlapping updates are resolved last-writer-wins on Timestamp, with ties
+// broken by author ID so every replica decides the same way; list paths
+// such as photos always append. An update without a version has no base to
+// compare with and applies as the latest write, without conflicts.
+func ResolveInspectionUpdate(update *InspectionUpdate, writes []PathWrite) MergeDecision {
+	if update.Version <= 0 {
+		if IsListPath(update.Path) {
+			return MergeDecision{Outcome: MergeAppend}
+		}
+		return MergeDecision{Outcome: MergeApply}
+	}
+	base := update.Version - 1
+
+	var concurrent []PathWrite
+	for _, write := range writes {
+		if write.Version > base && PathsOverlap(write.Path, update.Path) {
+			concurrent = append(concurrent, write)
+		}
+	}
+	sort.Slice(concurrent, func(i, j int) bool { return concurrent[j].newerThan(concurrent[i]) })
+
+	if IsListPath(update.Path) {
+		return MergeDecision{Outcome: MergeAppend, Conflicts: concurrent}
+	}
+	if len(concurrent) == 0 {
+		return MergeDecision{Outcome: MergeApply}
+	}
+
+	incoming := PathWrite{Path: update.Path, UpdatedBy: update.UpdatedBy, Timestamp: update.Timestamp}
+	latest := concurrent[len(concurrent)-1]
+	if latest.newerThan(incoming) {
+		return MergeDecision{Outcome: MergeReject, Conflicts: concurrent, Winner: &latest}
+	}
+	return MergeDecision{Outcome: MergeOverwrite, Conflicts: concurrent}
+}
+
+// IsListPath reports whether path addresses a list that updates append to
+func IsListPath(path string) bool {
+	segments := strings.Split(path, ".")
+	return listFields[segments[len(segments)-1]]
+}
+
+// PathsOverlap reports whether two dotted paths address the same data:
+// they are equal or one contains the other
+func PathsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return strings.HasPrefix(b, a+".")
+}
+
+// AppendListValue appends value to a list read from storage. A list value
+// appends each element; values already present are skipped so a retried
+// update does not duplicate photos.
+func AppendListValue(current []interface{}, value interface{}) []interface{} {
+	additions := toList(value)
+	if additions == nil {
+		additions = []interface{}{value}
+	}
+
+	merged := append([]interface{}(nil), current...)
+	for _, addition := range additions {
+		if isEmptyValue(addition) {
+			continue
+		}
+		duplicate := false
+		for _, existing := range merged {
+			if valuesEqual(existing, addition) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			merged = append(merged, addition)
+		}
+	}
+	return merged
+}
+
+// newerThan orders writes by Timestamp, then author ID, then version
+func (w PathWrite) newerThan(other PathWrite) bool {
+	if !w.Timestamp.Equal(other.Timestamp) {
+		return w.Timestamp.After(other.Timestamp)
+	}
+	if w.UpdatedBy != other.UpdatedBy {
+		return w.UpdatedBy.String() > other.UpdatedBy.String()
+	}
+	return w.Version > other.Version
+}
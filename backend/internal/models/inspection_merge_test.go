@@ -0,0 +1,252 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	mergeAlice = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	mergeBob   = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	mergeT0    = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+)
+
+func mergeAt(seconds int) time.Time {
+	return mergeT0.Add(time.Duration(seconds) * time.Second)
+}
+
+func TestResolveInspectionUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		update    InspectionUpdate
+		writes    []PathWrite
+		outcome   string
+		conflicts int
+		winner    uuid.UUID
+	}{
+		{
+			name:    "no writes applies",
+			update:  InspectionUpdate{Path: "sections.exterior.notes", Version: 1, UpdatedBy: mergeAlice, Timestamp: mergeAt(0)},
+			outcome: MergeApply,
+		},
+		{
+			name:   "interleaved writes on disjoint paths apply",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(1)},
+			writes: []PathWrite{
+				{Path: "sections.interior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(2)},
+				{Path: "sections.engine.items.oil.status", Version: 3, UpdatedBy: mergeBob, Timestamp: mergeAt(3)},
+			},
+			outcome: MergeApply,
+		},
+		{
+			name:   "writes the author had seen are not concurrent",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 4, UpdatedBy: mergeAlice, Timestamp: mergeAt(0)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.notes", Version: 3, UpdatedBy: mergeBob, Timestamp: mergeAt(5)},
+			},
+			outcome: MergeApply,
+		},
+		{
+			name:   "newer update overwrites an overlapping write",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(5)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(4)},
+			},
+			outcome:   MergeOverwrite,
+			conflicts: 1,
+		},
+		{
+			name:   "older update is rejected by an overlapping write",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(3)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(4)},
+			},
+			outcome:   MergeReject,
+			conflicts: 1,
+			winner:    mergeBob,
+		},
+		{
+			name:   "interleaved overlapping writes are judged by the newest",
+			update: InspectionUpdate{Path: "sections.exterior.items.paint.status", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(4)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.items.paint.status", Version: 3, UpdatedBy: mergeBob, Timestamp: mergeAt(6)},
+				{Path: "sections.exterior.items.paint.status", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(2)},
+				{Path: "sections.interior.notes", Version: 4, UpdatedBy: mergeBob, Timestamp: mergeAt(8)},
+			},
+			outcome:   MergeReject,
+			conflicts: 2,
+			winner:    mergeBob,
+		},
+		{
+			name:   "parent write overlaps a child path",
+			update: InspectionUpdate{Path: "sections.exterior.items.paint", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(1)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.items.paint.status", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(2)},
+			},
+			outcome:   MergeReject,
+			conflicts: 1,
+			winner:    mergeBob,
+		},
+		{
+			name:   "equal timestamps are broken by author ID",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(4)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(4)},
+			},
+			outcome:   MergeOverwrite,
+			conflicts: 1,
+		},
+		{
+			name:   "equal timestamps lose to a higher author ID",
+			update: InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(4)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(4)},
+			},
+			outcome:   MergeReject,
+			conflicts: 1,
+			winner:    mergeBob,
+		},
+		{
+			name:   "photo lists append",
+			update: InspectionUpdate{Path: "sections.exterior.items.paint.photos", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(1)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.items.paint.photos", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(2)},
+			},
+			outcome:   MergeAppend,
+			conflicts: 1,
+		},
+		{
+			name:   "update without a version applies without conflicts",
+			update: InspectionUpdate{Path: "sections.exterior.completed_at", UpdatedBy: mergeAlice, Timestamp: mergeAt(1)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.completed_at", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(0)},
+				{Path: "sections.exterior", Version: 3, UpdatedBy: mergeBob, Timestamp: mergeAt(5)},
+			},
+			outcome: MergeApply,
+		},
+		{
+			name:   "photo list without a version appends without conflicts",
+			update: InspectionUpdate{Path: "sections.exterior.items.paint.photos", UpdatedBy: mergeAlice, Timestamp: mergeAt(1)},
+			writes: []PathWrite{
+				{Path: "sections.exterior.items.paint.photos", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(2)},
+			},
+			outcome: MergeAppend,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := ResolveInspectionUpdate(&tt.update, tt.writes)
+			if decision.Outcome != tt.outcome {
+				t.Fatalf("outcome = %s, want %s", decision.Outcome, tt.outcome)
+			}
+			if len(decision.Conflicts) != tt.conflicts {
+				t.Fatalf("conflicts = %d, want %d", len(decision.Conflicts), tt.conflicts)
+			}
+			for i := 1; i < len(decision.Conflicts); i++ {
+				if decision.Conflicts[i-1].newerThan(decision.Conflicts[i]) {
+					t.Errorf("conflicts are not oldest first: %v", decision.Conflicts)
+				}
+			}
+			switch {
+			case tt.winner == uuid.Nil && decision.Winner != nil:
+				t.Errorf("winner = %v, want none", decision.Winner.UpdatedBy)
+			case tt.winner != uuid.Nil && (decision.Winner == nil || decision.Winner.UpdatedBy != tt.winner):
+				t.Errorf("winner = %v, want %v", decision.Winner, tt.winner)
+			}
+		})
+	}
+}
+
+// Two replicas seeing the same writes in a different order decide alike
+func TestResolveInspectionUpdateOrderIndependent(t *testing.T) {
+	update := InspectionUpdate{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeAlice, Timestamp: mergeAt(3)}
+	writes := []PathWrite{
+		{Path: "sections.exterior.notes", Version: 2, UpdatedBy: mergeBob, Timestamp: mergeAt(4)},
+		{Path: "sections.exterior", Version: 3, UpdatedBy: mergeAlice, Timestamp: mergeAt(4)},
+		{Path: "sections.exterior.notes", Version: 4, UpdatedBy: mergeBob, Timestamp: mergeAt(1)},
+	}
+	reversed := []PathWrite{writes[2], writes[1], writes[0]}
+
+	a := ResolveInspectionUpdate(&update, writes)
+	b := ResolveInspectionUpdate(&update, reversed)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("decisions differ:\n%+v\n%+v", a, b)
+	}
+	if a.Winner == nil || a.Winner.UpdatedBy != mergeBob || a.Winner.Version != 2 {
+		t.Fatalf("winner = %+v, want bob's version 2", a.Winner)
+	}
+}
+
+func TestPathsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"sections.exterior.notes", "sections.exterior.notes", true},
+		{"sections.exterior", "sections.exterior.notes", true},
+		{"sections.exterior.items.paint.status", "sections.exterior.items.paint", true},
+		{"sections.exterior", "sections.exterior_2.notes", false},
+		{"sections.exterior.notes", "sections.interior.notes", false},
+		{"sections.exterior.items.paint", "sections.exterior.items.paintwork", false},
+	}
+	for _, tt := range tests {
+		if got := PathsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("PathsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPathWriteNewerThan(t *testing.T) {
+	tests := []struct {
+		name string
+		w, o PathWrite
+		want bool
+	}{
+		{"later timestamp", PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(2)}, PathWrite{UpdatedBy: mergeBob, Timestamp: mergeAt(1)}, true},
+		{"earlier timestamp", PathWrite{UpdatedBy: mergeBob, Timestamp: mergeAt(1)}, PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(2)}, false},
+		{"tie, higher author", PathWrite{UpdatedBy: mergeBob, Timestamp: mergeAt(1)}, PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1)}, true},
+		{"tie, lower author", PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1)}, PathWrite{UpdatedBy: mergeBob, Timestamp: mergeAt(1)}, false},
+		{"tie, same author, higher version", PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1), Version: 3}, PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1), Version: 2}, true},
+		{"identical", PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1), Version: 2}, PathWrite{UpdatedBy: mergeAlice, Timestamp: mergeAt(1), Version: 2}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.newerThan(tt.o); got != tt.want {
+			t.Errorf("%s: newerThan = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendListValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []interface{}
+		value   interface{}
+		want    []interface{}
+	}{
+		{"append to empty", nil, "a.jpg", []interface{}{"a.jpg"}},
+		{"append a new photo", []interface{}{"a.jpg"}, "b.jpg", []interface{}{"a.jpg", "b.jpg"}},
+		{"same photo appended twice", []interface{}{"a.jpg"}, "a.jpg", []interface{}{"a.jpg"}},
+		{"list value skips known photos", []interface{}{"a.jpg"}, []interface{}{"a.jpg", "b.jpg", "b.jpg"}, []interface{}{"a.jpg", "b.jpg"}},
+		{"empty values are skipped", []interface{}{"a.jpg"}, "", []interface{}{"a.jpg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AppendListValue(tt.current, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Two interleaved retries of the same upload leave one photo
+	list := AppendListValue(nil, "a.jpg")
+	list = AppendListValue(list, "b.jpg")
+	list = AppendListValue(list, "a.jpg")
+	if !reflect.DeepEqual(list, []interface{}{"a.jpg", "b.jpg"}) {
+		t.Fatalf("after retries got %v", list)
+	}
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPath is returned for update paths that do not address editable
+// inspection data
+var ErrInvalidPath = errors.New("invalid inspection path")
+
+// patchableFields are the top-level inspection fields real-time updates may
+// write; status and versions change through their own workflows
+var patchableFields = map[string]bool{
+	"sections":  true,
+	"summary":   true,
+	"signature": true,
+}
+
+// arrayFields hold lists of objects addressed by their "id" in paths, e.g.
+// sections.exterior.items.scratches
+var arrayFields = map[string]bool{
+	"items":       true,
+	"annotations": true,
+}
+
+// ApplyUpdate writes update.Value at update.Path into the inspection, e.g.
+// "sections.exterior.items.scratches.photos". Missing sections and items
+// are created; list paths (see IsListPath) append instead of replacing.
+// Items touched by the update get their updated_at set to its timestamp.
+func (i *Inspection) ApplyUpdate(update *InspectionUpdate) error {
+	segments := strings.Split(update.Path, ".")
+	if !patchableFields[segments[0]] {
+		return fmt.Errorf("%w: %q", ErrInvalidPath, update.Path)
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if err := PatchDocument(doc, update.Path, update.Value, IsListPath(update.Path)); err != nil {
+		return err
+	}
+
+	// sections.<section>.items.<item>.<field> also stamps the item
+	if len(segments) > 4 && segments[0] == "sections" && segments[2] == "items" {
+		timestamp := update.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		itemPath := strings.Join(segments[:4], ".") + ".updated_at"
+		if err := PatchDocument(doc, itemPath, timestamp, false); err != nil {
+			return err
+		}
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var result Inspection
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return fmt.Errorf("%w: %q: %v", ErrInvalidPath, update.Path, err)
+	}
+
+	// Associations are not part of the document being edited
+	result.Vehicle, result.Inspector = i.Vehicle, i.Inspector
+	*i = result
+	return nil
+}
+
+// PatchDocument sets value at the dotted path inside doc, creating
+// intermediate objects. Segments inside arrays address the element whose
+// "id" matches, or an index; unknown IDs append a new {"id": ...} element
+// and a null value removes the element. With appendList the value is
+// appended to the list at path.
+func PatchDocument(doc map[string]interface{}, path string, value interface{}, appendList bool) error {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+
+	value, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	var container interface{} = doc
+	for depth, segment := range segments {
+		last := depth == len(segments)-1
+
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if last {
+				node[segment] = patchedValue(node[segment], value, appendList)
+				return nil
+			}
+			child, ok := node[segment]
+			if !ok || child == nil {
+				if arrayFields[segment] {
+					child = []interface{}{}
+				} else {
+					child = map[string]interface{}{}
+				}
+			}
+			// Arrays grow by reassignment, so write the child back first
+			node[segment] = child
+			if list, ok := child.([]interface{}); ok {
+				updated, err := patchArray(list, segments[depth+1:], value, appendList, path)
+				if err != nil {
+					return err
+				}
+				node[segment] = updated
+				return nil
+			}
+			container = child
+
+		default:
+			return fmt.Errorf("%w: %q: %s is not an object", ErrInvalidPath, path, strings.Join(segments[:depth], "."))
+		}
+	}
+	return nil
+}
+
+// patchArray applies the remaining path to the array element it addresses
+func patchArray(list []interface{}, segments []string, value interface{}, appendList bool, path string) ([]interface{}, error) {
+	if len(segments) == 0 {
+		return list, nil
+	}
+	segment := segments[0]
+
+	index := -1
+	for i, element := range list {
+		if object, ok := element.(map[string]interface{}); ok && fmt.Sprint(object["id"]) == segment {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		if n, err := strconv.Atoi(segment); err == nil && n >= 0 && n < len(list) {
+			index = n
+		}
+	}
+
+	if len(segments) == 1 {
+		if value == nil && !appendList {
+			if index >= 0 {
+				list = append(list[:index], list[index+1:]...)
+			}
+			return list, nil
+		}
+		if index >= 0 {
+			// A replaced element keeps its id, so later paths still find it
+			object, isObject := value.(map[string]interface{})
+			current, wasObject := list[index].(map[string]interface{})
+			if isObject && wasObject && !appendList && current["id"] != nil {
+				object["id"] = current["id"]
+			}
+			list[index] = patchedValue(list[index], value, appendList)
+			return list, nil
+		}
+		// A whole new element, e.g. an item written at once
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: no element %s", ErrInvalidPath, path, segment)
+		}
+		object["id"] = segment
+		return append(list, object), nil
+	}
+
+	if index < 0 {
+		list = append(list, map[string]interface{}{"id": segment})
+		index = len(list) - 1
+	}
+	element, ok := list[index].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q: element %s is not an object", ErrInvalidPath, path, segment)
+	}
+
+	rest := strings.Join(segments[1:], ".")
+	if err := PatchDocument(element, rest, value, appendList); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func patchedValue(current, value interface{}, appendList bool) interface{} {
+	if !appendList {
+		return value
+	}
+	existing, _ := current.([]interface{})
+	return AppendListValue(existing, value)
+}
+
+// normalizeJSON converts value to the generic form encoding/json decodes
+// into, so patched documents compare and merge consistently
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func patchDoc(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("bad test document %s: %v", raw, err)
+	}
+	return doc
+}
+
+func TestPatchDocumentArrays(t *testing.T) {
+	const exterior = `{"sections": {"exterior": {"items": [
+		{"id": "scratches", "status": "ok", "photos": ["a.jpg"]},
+		{"id": "dents", "status": "ok"}
+	]}}}`
+
+	tests := []struct {
+		name       string
+		doc        string
+		path       string
+		value      interface{}
+		appendList bool
+		want       string
+		err        bool
+	}{
+		{
+			name:  "field of an element addressed by id",
+			doc:   exterior,
+			path:  "sections.exterior.items.dents.status",
+			value: "fail",
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "ok", "photos": ["a.jpg"]},
+				{"id": "dents", "status": "fail"}
+			]}}}`,
+		},
+		{
+			name:  "field of an element addressed by index",
+			doc:   exterior,
+			path:  "sections.exterior.items.0.status",
+			value: "fail",
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "fail", "photos": ["a.jpg"]},
+				{"id": "dents", "status": "ok"}
+			]}}}`,
+		},
+		{
+			name:  "ids win over indexes",
+			doc:   `{"sections": {"exterior": {"items": [{"id": "a"}, {"id": "0"}]}}}`,
+			path:  "sections.exterior.items.0.status",
+			value: "fail",
+			want:  `{"sections": {"exterior": {"items": [{"id": "a"}, {"id": "0", "status": "fail"}]}}}`,
+		},
+		{
+			name:  "unknown id appends an element",
+			doc:   exterior,
+			path:  "sections.exterior.items.rust.status",
+			value: "fail",
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "ok", "photos": ["a.jpg"]},
+				{"id": "dents", "status": "ok"},
+				{"id": "rust", "status": "fail"}
+			]}}}`,
+		},
+		{
+			name:  "missing section and items are created",
+			doc:   `{"sections": {}}`,
+			path:  "sections.interior.items.seats.status",
+			value: "ok",
+			want:  `{"sections": {"interior": {"items": [{"id": "seats", "status": "ok"}]}}}`,
+		},
+		{
+			name:  "whole new element takes its id from the path",
+			doc:   `{"sections": {"exterior": {"items": []}}}`,
+			path:  "sections.exterior.items.rust",
+			value: map[string]interface{}{"status": "fail"},
+			want:  `{"sections": {"exterior": {"items": [{"id": "rust", "status": "fail"}]}}}`,
+		},
+		{
+			name:  "replaced element keeps its id",
+			doc:   exterior,
+			path:  "sections.exterior.items.1",
+			value: map[string]interface{}{"status": "fail"},
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "ok", "photos": ["a.jpg"]},
+				{"id": "dents", "status": "fail"}
+			]}}}`,
+		},
+		{
+			name: "null removes an element",
+			doc:  exterior,
+			path: "sections.exterior.items.scratches",
+			want: `{"sections": {"exterior": {"items": [{"id": "dents", "status": "ok"}]}}}`,
+		},
+		{
+			name: "null on an unknown element changes nothing",
+			doc:  exterior,
+			path: "sections.exterior.items.rust",
+			want: exterior,
+		},
+		{
+			name:       "list inside an element appends",
+			doc:        exterior,
+			path:       "sections.exterior.items.scratches.photos",
+			value:      "b.jpg",
+			appendList: true,
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "ok", "photos": ["a.jpg", "b.jpg"]},
+				{"id": "dents", "status": "ok"}
+			]}}}`,
+		},
+		{
+			name:  "nested array inside an element",
+			doc:   exterior,
+			path:  "sections.exterior.items.dents.annotations.n1",
+			value: map[string]interface{}{"shape": "circle"},
+			want: `{"sections": {"exterior": {"items": [
+				{"id": "scratches", "status": "ok", "photos": ["a.jpg"]},
+				{"id": "dents", "status": "ok", "annotations": [{"id": "n1", "shape": "circle"}]}
+			]}}}`,
+		},
+		{
+			name: "null removes a nested element",
+			doc:  `{"sections": {"exterior": {"items": [{"id": "dents", "annotations": [{"id": "n1"}, {"id": "n2"}]}]}}}`,
+			path: "sections.exterior.items.dents.annotations.n1",
+			want: `{"sections": {"exterior": {"items": [{"id": "dents", "annotations": [{"id": "n2"}]}]}}}`,
+		},
+		{
+			name:  "new element that is not an object",
+			doc:   exterior,
+			path:  "sections.exterior.items.rust",
+			value: "fail",
+			err:   true,
+		},
+		{
+			name:  "element that is not an object",
+			doc:   `{"sections": {"exterior": {"items": ["scratches"]}}}`,
+			path:  "sections.exterior.items.0.status",
+			value: "fail",
+			err:   true,
+		},
+		{
+			name:  "path through a value",
+			doc:   `{"sections": {"exterior": {"notes": "dirty"}}}`,
+			path:  "sections.exterior.notes.text",
+			value: "clean",
+			err:   true,
+		},
+		{
+			name:  "empty segment",
+			doc:   exterior,
+			path:  "sections.exterior.items..status",
+			value: "fail",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := patchDoc(t, tt.doc)
+			err := PatchDocument(doc, tt.path, tt.value, tt.appendList)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Fatalf("err = %v, want %v", err, ErrInvalidPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PatchDocument: %v", err)
+			}
+			if want := patchDoc(t, tt.want); !reflect.DeepEqual(doc, want) {
+				got, _ := json.Marshal(doc)
+				t.Fatalf("got %s", got)
+			}
+		})
+	}
+}
+
+func TestInspectionApplyUpdate(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	inspection := &Inspection{
+		Status: InspectionStatusInProgress,
+		Sections: JSONB{"exterior": InspectionSection{Name: "Exterior", Items: []InspectionItem{
+			{ID: "scratches", Status: ItemStatusOK, Photos: []string{"a.jpg"}},
+		}}},
+	}
+
+	updates := []InspectionUpdate{
+		{Path: "sections.exterior.items.scratches.photos", Value: "b.jpg", Timestamp: at},
+		{Path: "sections.exterior.items.scratches.photos", Value: "b.jpg", Timestamp: at},
+		{Path: "sections.exterior.items.dents.notes", Value: "left door", Timestamp: at.Add(time.Minute)},
+		{Path: "summary", Value: "Minor damage"},
+	}
+	for i := range updates {
+		if err := inspection.ApplyUpdate(&updates[i]); err != nil {
+			t.Fatalf("ApplyUpdate(%s): %v", updates[i].Path, err)
+		}
+	}
+
+	section, ok := inspection.GetSection("exterior")
+	if !ok || len(section.Items) != 2 {
+		t.Fatalf("section = %+v, want two items", section)
+	}
+	scratches, dents := section.Items[0], section.Items[1]
+	if !reflect.DeepEqual(scratches.Photos, []string{"a.jpg", "b.jpg"}) {
+		t.Errorf("photos = %v, want the retried photo once", scratches.Photos)
+	}
+	if scratches.Status != ItemStatusOK || !scratches.UpdatedAt.Equal(at) {
+		t.Errorf("scratches = %+v, want its status kept and updated_at stamped", scratches)
+	}
+	if dents.ID != "dents" || dents.Notes != "left door" || !dents.UpdatedAt.Equal(at.Add(time.Minute)) {
+		t.Errorf("dents = %+v, want a new stamped item", dents)
+	}
+	if inspection.Summary != "Minor damage" || inspection.Status != InspectionStatusInProgress {
+		t.Errorf("summary = %q, status = %s", inspection.Summary, inspection.Status)
+	}
+
+	for _, path := range []string{"status", "version", "sections.exterior.items.scratches.status.value"} {
+		before := *inspection
+		err := inspection.ApplyUpdate(&InspectionUpdate{Path: path, Value: "x"})
+		if !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("ApplyUpdate(%s) = %v, want %v", path, err, ErrInvalidPath)
+		}
+		if !reflect.DeepEqual(*inspection, before) {
+			t.Errorf("ApplyUpdate(%s) changed the inspection", path)
+		}
+	}
+}
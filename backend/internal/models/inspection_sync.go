@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sync operation types
+const (
+	SyncOpUpdate = "update" // a field edit, as sent over the socket
+	SyncOpPhoto  = "photo"  // a photo for an item, uploaded with the batch
+)
+
+// Sync operation outcomes
+const (
+	SyncApplied    = "applied"
+	SyncConflict   = "conflict"    // a newer concurrent write to the same path won
+	SyncRejected   = "rejected"    // the operation can never apply; drop it
+	SyncFailed     = "failed"      // transient failure; send it again
+	SyncInProgress = "in_progress" // an earlier submission is still applying it
+)
+
+// MaxSyncOperations bounds one sync batch
+const MaxSyncOperations = 500
+
+// SyncOperation is one change queued by a tablet while offline. ID is
+// generated on the device and makes resubmitting the queue safe.
+type SyncOperation struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Path      string      `json:"path,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	Version   int         `json:"version"`   // inspection version the edit was made on
+	Timestamp time.Time   `json:"timestamp"` // when the edit was made on the device
+	Section   string      `json:"section,omitempty"`
+	Item      string      `json:"item,omitempty"`
+	File      string      `json:"file,omitempty"` // multipart field holding the photo
+}
+
+// SyncOperationResult reports what happened to one operation. Duplicate
+// marks results recorded by an earlier submission of the same ID.
+type SyncOperationResult struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Version   int        `json:"version,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Winner    *PathWrite `json:"winner,omitempty"`
+	Duplicate bool       `json:"duplicate,omitempty"`
+}
+
+// SyncResult is the canonical inspection after a batch and the outcome of
+// each operation, in request order
+type SyncResult struct {
+	Inspection *Inspection           `json:"inspection"`
+	Version    int                   `json:"version"`
+	Results    []SyncOperationResult `json:"results"`
+}
+
+// Validate checks the fields the operation type needs
+func (op *SyncOperation) Validate() error {
+	if op.ID == "" || len(op.ID) > 128 {
+		return fmt.Errorf("operation id is required and at most 128 characters")
+	}
+	switch op.Type {
+	case SyncOpUpdate:
+		if op.Path == "" {
+			return fmt.Errorf("path is required")
+		}
+	case SyncOpPhoto:
+		if op.Section == "" || op.Item == "" || op.File == "" {
+			return fmt.Errorf("section, item and file are required")
+		}
+		if strings.ContainsAny(op.Section+op.Item, "./") {
+			return fmt.Errorf("%w: section and item must not contain '.' or '/'", ErrInvalidPath)
+		}
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+	return nil
+}
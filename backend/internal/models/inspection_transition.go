@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lifecycle actions moving an inspection between statuses
+const (
+	InspectionActionStart    = "start"    // draft -> in_progress
+	InspectionActionComplete = "complete" // draft, in_progress, rejected -> completed
+	InspectionActionApprove  = "approve"  // completed -> approved
+	InspectionActionReject   = "reject"   // completed -> rejected
+	InspectionActionReopen   = "reopen"   // completed, approved, rejected -> in_progress
+)
+
+// InspectionTransition is the audit trail of an inspection's lifecycle
+type InspectionTransition struct {
+	ID           uuid.UUID        `gorm:"type:uuid;primary_key" json:"id"`
+	InspectionID uuid.UUID        `gorm:"type:uuid;not null;index" json:"inspection_id"`
+	Action       string           `gorm:"not null" json:"action"`
+	FromStatus   InspectionStatus `gorm:"not null" json:"from_status"`
+	ToStatus     InspectionStatus `gorm:"not null" json:"to_status"`
+	ActorID      uuid.UUID        `gorm:"type:uuid;not null" json:"actor_id"`
+	Actor        *User            `json:"actor,omitempty"`
+	ActorRole    UserRole         `json:"actor_role"`
+	Reason       string           `json:"reason,omitempty"`
+	Version      int              `json:"version"` // inspection version after the transition
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// BeforeCreate hook
+func (t *InspectionTransition) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
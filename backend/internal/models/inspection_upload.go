@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Direct photo upload limits
+const (
+	MaxPhotoUploadSize       = 100 << 20 // bytes
+	MultipartUploadThreshold = 16 << 20  // larger photos are uploaded in parts
+	UploadPartSize           = 8 << 20   // S3 requires at least 5 MB per part but the last
+)
+
+// photoUploadTypes are the content types accepted for direct uploads, the
+// ones the photo pipeline can decode
+var photoUploadTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// PhotoUploadRequest asks for URLs to upload one photo for an inspection
+// item straight to storage
+type PhotoUploadRequest struct {
+	Section     string `json:"section"`
+	Item        string `json:"item"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`      // exact size in bytes, checked on confirmation
+	Multipart   bool   `json:"multipart"` // upload in parts even below the threshold
+}
+
+// Validate checks the target item and the announced file
+func (r *PhotoUploadRequest) Validate() error {
+	if r.Section == "" || r.Item == "" {
+		return fmt.Errorf("section and item are required")
+	}
+	if strings.ContainsAny(r.Section+r.Item, "./") {
+		return fmt.Errorf("%w: section and item must not contain '.' or '/'", ErrInvalidPath)
+	}
+	if _, ok := photoUploadTypes[r.ContentType]; !ok {
+		return fmt.Errorf("content type %q is not an accepted image type", r.ContentType)
+	}
+	if r.Size <= 0 || r.Size > MaxPhotoUploadSize {
+		return fmt.Errorf("size must be between 1 and %d bytes", MaxPhotoUploadSize)
+	}
+	return nil
+}
+
+// Extension returns the file extension of the announced content type
+func (r *PhotoUploadRequest) Extension() string {
+	return photoUploadTypes[r.ContentType]
+}
+
+// PhotoUpload is a direct upload handed to a client, kept until it is
+// confirmed or expires. URL is set once confirmed, so confirming again
+// returns the same photo.
+type PhotoUpload struct {
+	ID           string    `json:"id"`
+	InspectionID uuid.UUID `json:"inspection_id"`
+	Section      string    `json:"section"`
+	Item         string    `json:"item"`
+	Key          string    `json:"key"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	MultipartID  string    `json:"multipart_id,omitempty"`
+	PartCount    int       `json:"part_count,omitempty"`
+	UploadedBy   uuid.UUID `json:"uploaded_by"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	URL          string    `json:"url,omitempty"`
+	PhotoID      uuid.UUID `json:"photo_id,omitempty"`
+	Version      int       `json:"version,omitempty"`
+}
+
+// PhotoUploadTicket tells the client where to PUT the photo: either URL
+// with Headers, or each of Parts in PartSize chunks, sending the ETag of
+// every part back on confirmation
+type PhotoUploadTicket struct {
+	UploadID  string            `json:"upload_id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	PartSize  int64             `json:"part_size,omitempty"`
+	Parts     []UploadPartURL   `json:"parts,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// UploadPartURL is where one part of a multipart upload is sent
+type UploadPartURL struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
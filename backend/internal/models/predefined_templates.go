@@ -0,0 +1,105 @@
+package models
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+//go:embed predefined/categories.json predefined/templates/*.json
+var predefinedFS embed.FS
+
+var (
+	predefinedOnce       sync.Once
+	predefinedTemplates  []PredefinedTemplate
+	predefinedCategories []TemplateCategory
+	predefinedErr        error
+)
+
+// GetPredefinedTemplates returns all system templates, ordered for display.
+// It is empty when the embedded data is invalid; LoadPredefined reports why.
+func GetPredefinedTemplates() []PredefinedTemplate {
+	templates, _, _ := LoadPredefined()
+	return templates
+}
+
+// GetTemplateCategories returns all available categories
+func GetTemplateCategories() []TemplateCategory {
+	_, categories, _ := LoadPredefined()
+	return categories
+}
+
+// LoadPredefined parses the embedded templates and categories. Each template
+// must have a unique ID, a known category and valid conditions.
+func LoadPredefined() ([]PredefinedTemplate, []TemplateCategory, error) {
+	predefinedOnce.Do(func() {
+		predefinedTemplates, predefinedCategories, predefinedErr = parsePredefined(predefinedFS)
+	})
+	if predefinedErr != nil {
+		return nil, nil, predefinedErr
+	}
+	return append([]PredefinedTemplate(nil), predefinedTemplates...),
+		append([]TemplateCategory(nil), predefinedCategories...), nil
+}
+
+func parsePredefined(fsys fs.FS) ([]PredefinedTemplate, []TemplateCategory, error) {
+	data, err := fs.ReadFile(fsys, "predefined/categories.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	var categories []TemplateCategory
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return nil, nil, fmt.Errorf("predefined/categories.json: %w", err)
+	}
+	known := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		known[category.Slug] = true
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Order < categories[j].Order })
+
+	files, err := fs.Glob(fsys, "predefined/templates/*.json")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]string)
+	templates := make([]PredefinedTemplate, 0, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		var template PredefinedTemplate
+		if err := json.Unmarshal(data, &template); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		switch {
+		case template.ID == "":
+			return nil, nil, fmt.Errorf("%s: missing id", file)
+		case seen[template.ID] != "":
+			return nil, nil, fmt.Errorf("%s: id %q already used by %s", file, template.ID, seen[template.ID])
+		case !known[template.Category]:
+			return nil, nil, fmt.Errorf("%s: unknown category %q", file, template.Category)
+		case len(template.Config.Sections) == 0:
+			return nil, nil, fmt.Errorf("%s: template has no sections", file)
+		}
+		if err := template.Config.ValidateConditions(); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		seen[template.ID] = file
+		templates = append(templates, template)
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		if templates[i].Order != templates[j].Order {
+			return templates[i].Order < templates[j].Order
+		}
+		return templates[i].ID < templates[j].ID
+	})
+	return templates, categories, nil
+}
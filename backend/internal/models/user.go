@@ -24,10 +24,11 @@ type User struct {
 type UserRole string
 
 const (
-	RoleAdmin     UserRole = "admin"
-	RoleInspector UserRole = "inspector"
-	RoleMechanic  UserRole = "mechanic"
-	RoleViewer    UserRole = "viewer"
+	RoleAdmin      UserRole = "admin"
+	RoleInspector  UserRole = "inspector"
+	RoleSupervisor UserRole = "supervisor" // reviews completed inspections
+	RoleMechanic   UserRole = "mechanic"
+	RoleViewer     UserRole = "viewer"
 )
 
 // BeforeCreate hook
@@ -38,15 +38,61 @@ const (
 	VehicleStatusDelivered  VehicleStatus = "delivered"
 )
 
+var vehicleStatusLabels = map[VehicleStatus]string{
+	VehicleStatusPending:    "Pendiente",
+	VehicleStatusInspecting: "En inspección",
+	VehicleStatusRepairing:  "En reparación",
+	VehicleStatusCompleted:  "Completado",
+	VehicleStatusDelivered:  "Entregado",
+}
+
+// Label returns the Spanish display name of the status
+func (s VehicleStatus) Label() string {
+	if label, ok := vehicleStatusLabels[s]; ok {
+		return label
+	}
+	return string(s)
+}
+
+// VehiclePhoto is an uploaded photo. URL is the original as uploaded, which
+// keeps its EXIF; clients only ever see the processed renditions.
 type VehiclePhoto struct {
-	ID         uuid.UUID     `gorm:"type:uuid;primary_key" json:"id"`
-	VehicleID  uuid.UUID     `gorm:"type:uuid;not null" json:"vehicle_id"`
-	Category   PhotoCategory `json:"category"`
-	URL        string        `json:"url"`
-	Thumbnail  string        `json:"thumbnail"`
-	Metadata   JSONB         `gorm:"type:jsonb" json:"metadata"`
-	UploadedBy uuid.UUID     `gorm:"type:uuid" json:"uploaded_by"`
-	UploadedAt time.Time     `json:"uploaded_at"`
+	ID           uuid.UUID     `gorm:"type:uuid;primary_key" json:"id"`
+	VehicleID    uuid.UUID     `gorm:"type:uuid;not null" json:"vehicle_id"`
+	InspectionID *uuid.UUID    `gorm:"type:uuid;index" json:"inspection_id,omitempty"` // set for photos taken during an inspection
+	SectionID    string        `json:"section_id,omitempty"`
+	ItemID       string        `json:"item_id,omitempty"`
+	Category     PhotoCategory `json:"category"`
+	URL          string        `json:"url"`
+	Thumbnail    string        `json:"thumbnail"`
+	Medium       string        `json:"medium,omitempty"`
+	Display      string        `json:"display,omitempty"` // full size, upright and without GPS
+	ContentType  string        `json:"content_type,omitempty"`
+	Width        int           `json:"width,omitempty"`
+	Height       int           `json:"height,omitempty"`
+	Metadata     JSONB         `gorm:"type:jsonb" json:"metadata"` // exif and rendition keys
+	UploadedBy   uuid.UUID     `gorm:"type:uuid" json:"uploaded_by"`
+	UploadedAt   time.Time     `json:"uploaded_at"`
+
+	// Processing by the photo pipeline
+	Processing         PhotoProcessingStatus `gorm:"index;default:pending" json:"processing"`
+	ProcessingAttempts int                   `json:"processing_attempts,omitempty"`
+	ProcessingError    string                `json:"processing_error,omitempty"`
+	NextProcessingAt   *time.Time            `gorm:"index" json:"-"`
+	ProcessedAt        *time.Time            `json:"processed_at,omitempty"`
+}
+
+type PhotoProcessingStatus string
+
+const (
+	PhotoProcessingPending PhotoProcessingStatus = "pending"
+	PhotoProcessingReady   PhotoProcessingStatus = "ready"
+	PhotoProcessingFailed  PhotoProcessingStatus = "failed"
+)
+
+// ClientVisible reports whether the photo has client-facing renditions
+func (p *VehiclePhoto) ClientVisible() bool {
+	return p.Processing == PhotoProcessingReady && p.Display != ""
 }
 
 type PhotoCategory string
@@ -62,6 +108,7 @@ const (
 	PhotoCategoryTrunk          PhotoCategory = "trunk"
 	PhotoCategoryDamage         PhotoCategory = "damage"
 	PhotoCategoryDocument       PhotoCategory = "document"
+	PhotoCategoryInspection     PhotoCategory = "inspection"
 )
 
 type Owner struct {
@@ -99,6 +146,9 @@ func (p *VehiclePhoto) BeforeCreate(tx *gorm.DB) error {
 	if p.UploadedAt.IsZero() {
 		p.UploadedAt = time.Now()
 	}
+	if p.Processing == "" {
+		p.Processing = PhotoProcessingPending
+	}
 	return nil
 }
 
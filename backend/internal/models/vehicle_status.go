@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// What caused a vehicle status change
+const (
+	VehicleTriggerManual         = "manual"
+	VehicleTriggerEntryStarted   = "entry_inspection_started"
+	VehicleTriggerFailedItem     = "failed_item"
+	VehicleTriggerExitApproved   = "exit_inspection_approved"
+	VehicleTriggerDeliverySigned = "delivery_signed"
+)
+
+// vehicleStatusTransitions lists the statuses a vehicle may move to from
+// each status
+var vehicleStatusTransitions = map[VehicleStatus][]VehicleStatus{
+	VehicleStatusPending:    {VehicleStatusInspecting},
+	VehicleStatusInspecting: {VehicleStatusRepairing, VehicleStatusCompleted},
+	VehicleStatusRepairing:  {VehicleStatusInspecting, VehicleStatusCompleted},
+	VehicleStatusCompleted:  {VehicleStatusInspecting, VehicleStatusRepairing, VehicleStatusDelivered},
+	VehicleStatusDelivered:  {VehicleStatusPending, VehicleStatusInspecting}, // checked in again
+}
+
+// Valid reports whether s is a known status
+func (s VehicleStatus) Valid() bool {
+	_, ok := vehicleStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a vehicle may move from s to next
+func (s VehicleStatus) CanTransitionTo(next VehicleStatus) bool {
+	for _, allowed := range vehicleStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// VehicleStatusHistory records one status change of a vehicle
+type VehicleStatusHistory struct {
+	ID           uuid.UUID     `gorm:"type:uuid;primary_key" json:"id"`
+	VehicleID    uuid.UUID     `gorm:"type:uuid;not null;index" json:"vehicle_id"`
+	FromStatus   VehicleStatus `json:"from_status"`
+	ToStatus     VehicleStatus `gorm:"not null" json:"to_status"`
+	Trigger      string        `gorm:"not null" json:"trigger"` // manual, entry_inspection_started, failed_item, ...
+	Reason       string        `json:"reason,omitempty"`
+	InspectionID *uuid.UUID    `gorm:"type:uuid" json:"inspection_id,omitempty"`
+	ChangedByID  *uuid.UUID    `gorm:"type:uuid" json:"changed_by_id,omitempty"` // nil for changes made by the system
+	ChangedBy    *User         `json:"changed_by,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// BeforeCreate hook
+func (h *VehicleStatusHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Vehicle timeline event types. Inspection lifecycle events are named
+// inspection_started, inspection_completed, inspection_approved, ...
+const (
+	TimelineVehicleCheckedIn  = "vehicle_checked_in"
+	TimelineVehicleCheckedOut = "vehicle_checked_out"
+	TimelineStatusChanged     = "status_changed"
+	TimelineInspectionCreated = "inspection_created"
+	TimelinePhotoUploaded     = "photo_uploaded"
+	TimelineClientAccess      = "client_access"
+)
+
+// TimelineEvent is one entry of a vehicle's timeline, merged from status
+// changes, inspections, photos and client access logs
+type TimelineEvent struct {
+	Type         string                 `json:"type"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	Title        string                 `json:"title"` // Spanish display text
+	ActorID      *uuid.UUID             `json:"actor_id,omitempty"`
+	ActorName    string                 `json:"actor_name,omitempty"`
+	InspectionID *uuid.UUID             `json:"inspection_id,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook event types
+const (
+	WebhookEventVehicleAdded         = "vehicle.added"
+	WebhookEventVehicleStatusChanged = "vehicle.status_changed"
+	WebhookEventInspectionCompleted  = "inspection.completed"
+	WebhookEventReportReady          = "report.ready"
+)
+
+// WebhookEvents lists every event a subscription can receive
+var WebhookEvents = []string{
+	WebhookEventVehicleAdded,
+	WebhookEventVehicleStatusChanged,
+	WebhookEventInspectionCompleted,
+	WebhookEventReportReady,
+}
+
+// WebhookSubscription pushes events of a client organization to an HTTPS
+// endpoint. Payloads are signed with Secret.
+type WebhookSubscription struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	URL            string         `gorm:"not null" json:"url"`
+	Secret         string         `gorm:"not null" json:"secret"`
+	EventTypes     []string       `gorm:"type:jsonb;serializer:json" json:"event_types"` // empty means all
+	Active         bool           `gorm:"default:true" json:"active"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"
+	WebhookDeliveryCancelled WebhookDeliveryStatus = "cancelled"
+)
+
+// WebhookDelivery is one event queued for one subscription. The payload is
+// frozen at enqueue time so retries and replays send the same body.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primary_key" json:"id"`
+	SubscriptionID uuid.UUID             `gorm:"type:uuid;not null;index" json:"subscription_id"`
+	OrganizationID uuid.UUID             `gorm:"type:uuid;not null;index" json:"organization_id"`
+	EventID        uuid.UUID             `gorm:"type:uuid;not null" json:"event_id"`
+	EventType      string                `gorm:"not null" json:"event_type"`
+	Payload        JSONB                 `gorm:"type:jsonb" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"index" json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"index" json:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	ReplayOf       *uuid.UUID            `gorm:"type:uuid" json:"replay_of,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookDeadLetter keeps deliveries that exhausted their retries until an
+// admin replays them
+type WebhookDeadLetter struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	DeliveryID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"delivery_id"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null" json:"subscription_id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	EventType      string     `json:"event_type"`
+	Payload        JSONB      `gorm:"type:jsonb" json:"payload"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error"`
+	FailedAt       time.Time  `json:"failed_at"`
+	ReplayedAt     *time.Time `json:"replayed_at,omitempty"`
+}
+
+func (s *WebhookSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	if s.Secret == "" {
+		s.Secret = GenerateWebhookSecret()
+	}
+	return nil
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.Status == "" {
+		d.Status = WebhookDeliveryPending
+	}
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = time.Now()
+	}
+	return nil
+}
+
+func (d *WebhookDeadLetter) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.FailedAt.IsZero() {
+		d.FailedAt = time.Now()
+	}
+	return nil
+}
+
+// Subscribes reports whether the subscription receives eventType
+func (s *WebhookSubscription) Subscribes(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidWebhookEvent reports whether eventType is a known webhook event
+func ValidWebhookEvent(eventType string) bool {
+	for _, t := range WebhookEvents {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateWebhookSecret returns a random signing secret
+func GenerateWebhookSecret() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return uuid.New().String()
+	}
+	return "whsec_" + hex.EncodeToString(buf)
+}
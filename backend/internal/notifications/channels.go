@@ -0,0 +1,110 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/macal/inventory/internal/config"
+	"github.com/macal/inventory/internal/models"
+)
+
+// InAppChannel publishes notifications on the organization's Redis channel
+// so connected portal sessions see them immediately. The inbox row itself is
+// written by Notifier.Notify.
+type InAppChannel struct {
+	redis *redis.Client
+}
+
+func NewInAppChannel(redis *redis.Client) *InAppChannel {
+	return &InAppChannel{redis: redis}
+}
+
+func (c *InAppChannel) Name() string {
+	return "in_app"
+}
+
+func (c *InAppChannel) Enabled(org *models.ClientOrganization, n *models.ClientNotification) bool {
+	return true
+}
+
+func (c *InAppChannel) Send(ctx context.Context, org *models.ClientOrganization, n *models.ClientNotification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return c.redis.Publish(ctx, fmt.Sprintf("client:%s:notifications", org.ID), payload).Err()
+}
+
+// SMTPChannel emails notifications to the organization's recipients
+type SMTPChannel struct {
+	cfg config.SMTPConfig
+}
+
+func NewSMTPChannel(cfg config.SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{cfg: cfg}
+}
+
+func (c *SMTPChannel) Name() string {
+	return "email"
+}
+
+func (c *SMTPChannel) Enabled(org *models.ClientOrganization, n *models.ClientNotification) bool {
+	settings := org.NotificationSettings
+	return settings.Email && settings.Wants(n.Type)
+}
+
+func (c *SMTPChannel) Send(ctx context.Context, org *models.ClientOrganization, n *models.ClientNotification) error {
+	recipients := org.NotificationSettings.Recipients
+	if len(recipients) == 0 {
+		if org.Email == "" {
+			return fmt.Errorf("organization %s has no email recipients", org.ID)
+		}
+		recipients = []string{org.Email}
+	}
+
+	from := c.cfg.From
+	if addr, err := parseAddress(from); err == nil {
+		from = addr
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.SentAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Message, "\n", "\r\n"))
+	if url, ok := n.Data["url"].(string); ok {
+		fmt.Fprintf(&msg, "\r\n\r\n%s", url)
+	}
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, from, recipients, msg.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,257 @@
+// Package notifications creates ClientNotifications and delivers them to
+// client organizations through pluggable channels (in-app, email, webhooks).
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const deliveryTimeout = 30 * time.Second
+
+// Channel delivers a stored notification to a client organization
+type Channel interface {
+	Name() string
+	// Enabled reports whether the organization wants this channel
+	Enabled(org *models.ClientOrganization, n *models.ClientNotification) bool
+	Send(ctx context.Context, org *models.ClientOrganization, n *models.ClientNotification) error
+}
+
+// Notifier stores notifications in the client inbox and fans them out to
+// every enabled channel
+type Notifier struct {
+	db       *gorm.DB
+	channels []Channel
+	logger   *zap.SugaredLogger
+}
+
+func NewNotifier(db *gorm.DB, channels ...Channel) *Notifier {
+	logger, _ := zap.NewProduction()
+	return &Notifier{
+		db:       db,
+		channels: channels,
+		logger:   logger.Sugar(),
+	}
+}
+
+// Notify saves the notification and delivers it asynchronously. Delivery
+// failures are logged, the inbox copy is always kept.
+func (n *Notifier) Notify(ctx context.Context, org *models.ClientOrganization, notification *models.ClientNotification) error {
+	notification.OrganizationID = org.ID
+	if err := n.db.WithContext(ctx).Create(notification).Error; err != nil {
+		return err
+	}
+
+	go n.deliver(*org, *notification)
+	return nil
+}
+
+func (n *Notifier) deliver(org models.ClientOrganization, notification models.ClientNotification) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	for _, channel := range n.channels {
+		if !channel.Enabled(&org, &notification) {
+			continue
+		}
+		if err := channel.Send(ctx, &org, &notification); err != nil {
+			n.logger.Errorf("Failed to deliver notification %s to %s via %s: %v", notification.ID, org.ID, channel.Name(), err)
+		}
+	}
+}
+
+// VehicleAdded notifies every organization whose VehicleFilters match a new
+// vehicle
+func (n *Notifier) VehicleAdded(ctx context.Context, vehicle *models.Vehicle) {
+	orgs, err := n.followers(ctx, vehicle)
+	if err != nil {
+		n.logger.Errorf("Failed to find organizations for vehicle %s: %v", vehicle.ID, err)
+		return
+	}
+
+	for i := range orgs {
+		notification := &models.ClientNotification{
+			Type:    models.NotificationVehicleAdded,
+			Title:   fmt.Sprintf("Nuevo vehículo: %s", vehicle.LicensePlate),
+			Message: fmt.Sprintf("El vehículo %s %s (%s) ingresó al patio.", vehicle.Make, vehicle.Model, vehicle.LicensePlate),
+			Data: models.JSONB{
+				"vehicle_id":    vehicle.ID,
+				"license_plate": vehicle.LicensePlate,
+				"status":        vehicle.Status,
+			},
+		}
+		if err := n.Notify(ctx, &orgs[i], notification); err != nil {
+			n.logger.Errorf("Failed to notify %s of vehicle %s: %v", orgs[i].ID, vehicle.ID, err)
+		}
+	}
+}
+
+// VehicleStatusChanged notifies the organizations following vehicle that its
+// status moved from previous to vehicle.Status
+func (n *Notifier) VehicleStatusChanged(ctx context.Context, vehicle *models.Vehicle, previous models.VehicleStatus) {
+	orgs, err := n.followers(ctx, vehicle)
+	if err != nil {
+		n.logger.Errorf("Failed to find organizations for vehicle %s: %v", vehicle.ID, err)
+		return
+	}
+
+	for i := range orgs {
+		notification := &models.ClientNotification{
+			Type:    models.NotificationVehicleStatusChanged,
+			Title:   fmt.Sprintf("Cambio de estado: %s", vehicle.LicensePlate),
+			Message: fmt.Sprintf("El vehículo %s pasó de %s a %s.", vehicle.LicensePlate, previous.Label(), vehicle.Status.Label()),
+			Data: models.JSONB{
+				"vehicle_id":      vehicle.ID,
+				"license_plate":   vehicle.LicensePlate,
+				"previous_status": previous,
+				"status":          vehicle.Status,
+			},
+		}
+		if err := n.Notify(ctx, &orgs[i], notification); err != nil {
+			n.logger.Errorf("Failed to notify %s of vehicle %s: %v", orgs[i].ID, vehicle.ID, err)
+		}
+	}
+}
+
+// InspectionCompleted notifies the organizations following the inspected
+// vehicle that are allowed to see inspections
+func (n *Notifier) InspectionCompleted(ctx context.Context, inspection *models.Inspection) {
+	vehicle := inspection.Vehicle
+	if vehicle == nil {
+		vehicle = &models.Vehicle{}
+		if err := n.db.WithContext(ctx).First(vehicle, "id = ?", inspection.VehicleID).Error; err != nil {
+			n.logger.Errorf("Failed to load vehicle for inspection %s: %v", inspection.ID, err)
+			return
+		}
+	}
+
+	orgs, err := n.followers(ctx, vehicle)
+	if err != nil {
+		n.logger.Errorf("Failed to find organizations for vehicle %s: %v", vehicle.ID, err)
+		return
+	}
+
+	for i := range orgs {
+		if !orgs[i].Permissions.CanViewInspections {
+			continue
+		}
+		notification := &models.ClientNotification{
+			Type:    models.NotificationInspectionCompleted,
+			Title:   fmt.Sprintf("Inspección completada: %s", vehicle.LicensePlate),
+			Message: fmt.Sprintf("Se completó la inspección del vehículo %s.", vehicle.LicensePlate),
+			Data: models.JSONB{
+				"vehicle_id":      vehicle.ID,
+				"inspection_id":   inspection.ID,
+				"inspection_type": inspection.Type,
+				"license_plate":   vehicle.LicensePlate,
+			},
+		}
+		if err := n.Notify(ctx, &orgs[i], notification); err != nil {
+			n.logger.Errorf("Failed to notify %s of inspection %s: %v", orgs[i].ID, inspection.ID, err)
+		}
+	}
+}
+
+// followers returns the valid organizations allowed to see vehicle
+func (n *Notifier) followers(ctx context.Context, vehicle *models.Vehicle) ([]models.ClientOrganization, error) {
+	var orgs []models.ClientOrganization
+	if err := n.db.WithContext(ctx).Where("active = ?", true).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+
+	matching := orgs[:0]
+	for _, org := range orgs {
+		if org.IsValid() && org.CanAccessVehicle(vehicle) {
+			matching = append(matching, org)
+		}
+	}
+	return matching, nil
+}
+
+const previousStatusKey = "notifications:previous_status"
+
+// RegisterCallbacks hooks VehicleAdded and VehicleStatusChanged into GORM so
+// vehicle changes from any code path notify their followers. Status changes
+// are detected on updates through a loaded model, e.g.
+// db.Model(&vehicle).Update("status", ...), and notified once the update
+// commits. Updates made inside a caller's transaction commit later than the
+// callback can see, so the caller notifies after it, as VehicleWorkflow does.
+func (n *Notifier) RegisterCallbacks(db *gorm.DB) error {
+	err := db.Callback().Create().After("gorm:create").Register("notifications:vehicle_added", func(tx *gorm.DB) {
+		if tx.Error != nil {
+			return
+		}
+
+		var vehicles []models.Vehicle
+		switch dest := tx.Statement.Dest.(type) {
+		case *models.Vehicle:
+			vehicles = []models.Vehicle{*dest}
+		case []models.Vehicle:
+			vehicles = dest
+		case *[]models.Vehicle:
+			vehicles = *dest
+		default:
+			return
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+			for i := range vehicles {
+				n.VehicleAdded(ctx, &vehicles[i])
+			}
+		}()
+	})
+	if err != nil {
+		return err
+	}
+
+	err = db.Callback().Update().Before("gorm:update").Register("notifications:vehicle_status_before", func(tx *gorm.DB) {
+		vehicle, ok := tx.Statement.Model.(*models.Vehicle)
+		if !ok || vehicle.ID == uuid.Nil || tx.Statement.Dest == tx.Statement.Model {
+			return
+		}
+		if tx.Statement.Changed("Status") {
+			tx.InstanceSet(previousStatusKey, vehicle.Status)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("notifications:vehicle_status_after", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.RowsAffected == 0 {
+			return
+		}
+		if _, inTransaction := tx.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+			return
+		}
+		previous, ok := tx.InstanceGet(previousStatusKey)
+		if !ok {
+			return
+		}
+		vehicle, ok := tx.Statement.Model.(*models.Vehicle)
+		if !ok {
+			return
+		}
+
+		updated := *vehicle
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+
+			// Reload so the notification carries the persisted state
+			if err := n.db.WithContext(ctx).First(&updated, "id = ?", updated.ID).Error; err != nil {
+				n.logger.Errorf("Failed to reload vehicle %s: %v", updated.ID, err)
+				return
+			}
+			n.VehicleStatusChanged(ctx, &updated, previous.(models.VehicleStatus))
+		}()
+	})
+}
@@ -0,0 +1,291 @@
+package notifications
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/config"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// notifierTest wires a Notifier to an in-memory database and a
+// LocalSMTPServer, as main.go wires it to Postgres and the SMTP relay
+type notifierTest struct {
+	db       *gorm.DB
+	smtp     *LocalSMTPServer
+	notifier *Notifier
+}
+
+func newNotifierTest(t *testing.T) *notifierTest {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// One connection, so every goroutine sees the same in-memory database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.ClientOrganization{}, &models.ClientNotification{}, &models.Vehicle{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	server, err := NewLocalSMTPServer()
+	if err != nil {
+		t.Fatalf("start smtp server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	host, port, _ := net.SplitHostPort(server.Addr())
+	portNumber, _ := strconv.Atoi(port)
+	notifier := NewNotifier(db, NewSMTPChannel(config.SMTPConfig{
+		Host: host,
+		Port: portNumber,
+		From: "Inventario <noreply@example.com>",
+	}))
+	return &notifierTest{db: db, smtp: server, notifier: notifier}
+}
+
+func (nt *notifierTest) organization(t *testing.T, org models.ClientOrganization) *models.ClientOrganization {
+	t.Helper()
+	org.Active = true
+	if err := nt.db.Create(&org).Error; err != nil {
+		t.Fatalf("create organization %s: %v", org.Name, err)
+	}
+	return &org
+}
+
+func (nt *notifierTest) inbox(t *testing.T, org *models.ClientOrganization) []models.ClientNotification {
+	t.Helper()
+	var rows []models.ClientNotification
+	if err := nt.db.Where("organization_id = ?", org.ID).Find(&rows).Error; err != nil {
+		t.Fatalf("load inbox of %s: %v", org.Name, err)
+	}
+	return rows
+}
+
+// waitForInbox waits until org has n inbox rows, the status callback
+// notifies asynchronously
+func (nt *notifierTest) waitForInbox(t *testing.T, org *models.ClientOrganization, n int) []models.ClientNotification {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rows := nt.inbox(t, org)
+		if len(rows) >= n || time.Now().After(deadline) {
+			return rows
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForMessages waits until n messages arrived, delivery is asynchronous
+func (nt *notifierTest) waitForMessages(t *testing.T, n int) []Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages := nt.smtp.Messages()
+		if len(messages) >= n || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// parsedMessage is a captured email with its subject decoded
+type parsedMessage struct {
+	subject string
+	body    string
+}
+
+func parseMessage(t *testing.T, m Message) parsedMessage {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(m.Data)))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return parsedMessage{subject: subject, body: string(body)}
+}
+
+func TestVehicleAddedNotifiesFollowers(t *testing.T) {
+	nt := newNotifierTest(t)
+	if err := nt.notifier.RegisterCallbacks(nt.db); err != nil {
+		t.Fatalf("register callbacks: %v", err)
+	}
+
+	emailed := nt.organization(t, models.ClientOrganization{
+		Name:                 "Banco Uno",
+		Email:                "flota@example.com",
+		Permissions:          models.ClientPermissions{CanViewVehicles: true},
+		NotificationSettings: models.NotificationSettings{Email: true},
+	})
+	inboxOnly := nt.organization(t, models.ClientOrganization{
+		Name:        "Seguros Dos",
+		Email:       "siniestros@example.com",
+		Permissions: models.ClientPermissions{CanViewVehicles: true},
+		NotificationSettings: models.NotificationSettings{
+			Email: true,
+			Types: []string{models.NotificationInspectionCompleted},
+		},
+	})
+	unrelated := nt.organization(t, models.ClientOrganization{
+		Name:  "Automotora Tres",
+		Email: "ventas@example.com",
+		Permissions: models.ClientPermissions{
+			CanViewVehicles: true,
+			VehicleFilters:  models.VehicleFilters{LicensePlates: []string{"ZZZ999"}},
+		},
+		NotificationSettings: models.NotificationSettings{Email: true},
+	})
+
+	vehicle := models.Vehicle{LicensePlate: "ABC123", VIN: "VIN123", Make: "Toyota", Model: "Corolla"}
+	if err := nt.db.Create(&vehicle).Error; err != nil {
+		t.Fatalf("create vehicle: %v", err)
+	}
+
+	messages := nt.waitForMessages(t, 1)
+	// Give a wrongly sent second email the chance to arrive
+	time.Sleep(100 * time.Millisecond)
+	messages = nt.smtp.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d emails, want 1", len(messages))
+	}
+	if len(messages[0].To) != 1 || messages[0].To[0] != "flota@example.com" {
+		t.Errorf("email sent to %v, want the organization email", messages[0].To)
+	}
+	if messages[0].From != "noreply@example.com" {
+		t.Errorf("envelope from = %q, want the bare address", messages[0].From)
+	}
+	msg := parseMessage(t, messages[0])
+	if msg.subject != "Nuevo vehículo: ABC123" {
+		t.Errorf("subject = %q", msg.subject)
+	}
+	if want := "El vehículo Toyota Corolla (ABC123) ingresó al patio."; !strings.Contains(msg.body, want) {
+		t.Errorf("body = %q, want it to contain %q", msg.body, want)
+	}
+
+	for _, org := range []*models.ClientOrganization{emailed, inboxOnly} {
+		rows := nt.inbox(t, org)
+		if len(rows) != 1 {
+			t.Fatalf("%s has %d inbox rows, want 1", org.Name, len(rows))
+		}
+		row := rows[0]
+		if row.Type != models.NotificationVehicleAdded || row.Title != "Nuevo vehículo: ABC123" || row.Read {
+			t.Errorf("%s inbox row = %q %q read=%v", org.Name, row.Type, row.Title, row.Read)
+		}
+		if row.Data["vehicle_id"] != vehicle.ID.String() || row.Data["license_plate"] != "ABC123" {
+			t.Errorf("%s inbox data = %v", org.Name, row.Data)
+		}
+	}
+	if rows := nt.inbox(t, unrelated); len(rows) != 0 {
+		t.Errorf("%s was notified of a vehicle outside its filters", unrelated.Name)
+	}
+}
+
+func TestInspectionCompletedNeedsInspectionPermission(t *testing.T) {
+	nt := newNotifierTest(t)
+
+	allowed := nt.organization(t, models.ClientOrganization{
+		Name:        "Banco Uno",
+		Email:       "flota@example.com",
+		Permissions: models.ClientPermissions{CanViewVehicles: true, CanViewInspections: true},
+		NotificationSettings: models.NotificationSettings{
+			Email:      true,
+			Recipients: []string{"inspecciones@example.com", "gerencia@example.com"},
+		},
+	})
+	vehiclesOnly := nt.organization(t, models.ClientOrganization{
+		Name:                 "Seguros Dos",
+		Email:                "siniestros@example.com",
+		Permissions:          models.ClientPermissions{CanViewVehicles: true},
+		NotificationSettings: models.NotificationSettings{Email: true},
+	})
+
+	vehicle := &models.Vehicle{ID: uuid.New(), LicensePlate: "ABC123"}
+	inspection := &models.Inspection{
+		ID:        uuid.New(),
+		VehicleID: vehicle.ID,
+		Vehicle:   vehicle,
+		Type:      "entry",
+	}
+	nt.notifier.InspectionCompleted(context.Background(), inspection)
+
+	rows := nt.inbox(t, allowed)
+	if len(rows) != 1 {
+		t.Fatalf("%s has %d inbox rows, want 1", allowed.Name, len(rows))
+	}
+	if rows[0].Type != models.NotificationInspectionCompleted || rows[0].Data["inspection_id"] != inspection.ID.String() {
+		t.Errorf("inbox row = %q %v", rows[0].Type, rows[0].Data)
+	}
+	if rows := nt.inbox(t, vehiclesOnly); len(rows) != 0 {
+		t.Errorf("%s was notified without the inspection permission", vehiclesOnly.Name)
+	}
+
+	messages := nt.waitForMessages(t, 1)
+	if len(messages) != 1 {
+		t.Fatalf("got %d emails, want 1", len(messages))
+	}
+	if got := strings.Join(messages[0].To, ","); got != "inspecciones@example.com,gerencia@example.com" {
+		t.Errorf("email sent to %s, want the configured recipients", got)
+	}
+	if msg := parseMessage(t, messages[0]); msg.subject != "Inspección completada: ABC123" {
+		t.Errorf("subject = %q", msg.subject)
+	}
+}
+
+func TestVehicleStatusCallbackNotifiesAfterCommit(t *testing.T) {
+	nt := newNotifierTest(t)
+	org := nt.organization(t, models.ClientOrganization{
+		Name:        "Banco Uno",
+		Permissions: models.ClientPermissions{CanViewVehicles: true},
+	})
+	vehicle := models.Vehicle{LicensePlate: "ABC123", VIN: "VIN123", Status: models.VehicleStatusInspecting}
+	if err := nt.db.Create(&vehicle).Error; err != nil {
+		t.Fatalf("create vehicle: %v", err)
+	}
+	if err := nt.notifier.RegisterCallbacks(nt.db); err != nil {
+		t.Fatalf("register callbacks: %v", err)
+	}
+
+	if err := nt.db.Model(&vehicle).Update("status", models.VehicleStatusRepairing).Error; err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	rows := nt.waitForInbox(t, org, 1)
+	if len(rows) != 1 {
+		t.Fatalf("got %d inbox rows, want 1", len(rows))
+	}
+	if rows[0].Type != models.NotificationVehicleStatusChanged ||
+		rows[0].Data["previous_status"] != string(models.VehicleStatusInspecting) ||
+		rows[0].Data["status"] != string(models.VehicleStatusRepairing) {
+		t.Errorf("inbox row = %q %v, want inspecting to repairing", rows[0].Type, rows[0].Data)
+	}
+
+	// Inside a caller's transaction the caller notifies after the commit
+	err := nt.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&vehicle).Update("status", models.VehicleStatusCompleted).Error
+	})
+	if err != nil {
+		t.Fatalf("update status in transaction: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if rows := nt.inbox(t, org); len(rows) != 1 {
+		t.Errorf("got %d inbox rows after a transactional update, want it left to the caller", len(rows))
+	}
+}
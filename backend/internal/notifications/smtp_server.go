@@ -0,0 +1,172 @@
+package notifications
+
+import (
+	"bufio"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+)
+
+// Message is an email captured by LocalSMTPServer
+type Message struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// LocalSMTPServer is a minimal in-process SMTP server that accepts every
+// message and keeps it in memory. It stands in for a real relay during
+// development and tests: point SMTPConfig at Addr() with no credentials.
+type LocalSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+// NewLocalSMTPServer starts listening on an ephemeral loopback port
+func NewLocalSMTPServer() (*LocalSMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &LocalSMTPServer{listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *LocalSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Messages returns a copy of the messages received so far
+func (s *LocalSMTPServer) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server and waits for open sessions to finish
+func (s *LocalSMTPServer) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *LocalSMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *LocalSMTPServer) session(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(line string) {
+		w.WriteString(line + "\r\n")
+		w.Flush()
+	}
+
+	reply("220 localhost SMTP ready")
+
+	var current Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+		if i := strings.IndexByte(verb, ' '); i >= 0 {
+			verb = verb[:i]
+		}
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			current = Message{From: pathArgument(line)}
+			reply("250 OK")
+		case "RCPT":
+			current.To = append(current.To, pathArgument(line))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				return
+			}
+			current.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+			current = Message{}
+			reply("250 OK")
+		case "RSET":
+			current = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// pathArgument extracts the address from "MAIL FROM:<addr>" or "RCPT TO:<addr>"
+func pathArgument(line string) string {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return ""
+	}
+	arg := strings.TrimSpace(line[i+1:])
+	if j := strings.IndexByte(arg, ' '); j >= 0 {
+		arg = arg[:j]
+	}
+	return strings.Trim(arg, "<>")
+}
+
+// readData reads a dot-terminated DATA block, undoing dot-stuffing
+func readData(r *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return data, nil
+		}
+		if strings.HasPrefix(line, "..") {
+			line = line[1:]
+		}
+		data = append(data, line...)
+	}
+}
+
+// parseAddress returns the bare address of a "Name <addr>" header value
+func parseAddress(value string) (string, error) {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
@@ -0,0 +1,226 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/macal/inventory/internal/models"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 64 * 1024
+	sendBuffer     = 256 // messages queued per socket before it counts as slow
+)
+
+// Inbound message types
+const (
+	MessageEdit     = "edit"
+	MessagePresence = "presence"
+	MessageLock     = "lock"
+	MessageUnlock   = "unlock"
+)
+
+// Outbound message types besides the InspectionUpdates published by the
+// service ("field_update", "update_conflict", ...)
+const (
+	MessageWelcome      = "welcome"
+	MessageAck          = "ack"
+	MessageError        = "error"
+	TypePresence        = "presence"
+	TypeLockAcquired    = "lock_acquired"
+	TypeLockReleased    = "lock_released"
+	defaultLockDuration = 30 * time.Second
+	maxLockDuration     = 5 * time.Minute
+)
+
+// Client is one WebSocket connection to an inspection
+type Client struct {
+	id           string
+	hub          *Hub
+	conn         *websocket.Conn
+	inspectionID uuid.UUID
+	userID       uuid.UUID
+
+	sub   *Subscription
+	leave sync.Once
+
+	mu      sync.Mutex
+	section string
+	mode    string
+}
+
+// inbound is a message sent by the browser
+type inbound struct {
+	Type    string      `json:"type"`
+	Ref     string      `json:"ref,omitempty"` // echoed in the ack or error
+	Path    string      `json:"path,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+	Version int         `json:"version,omitempty"`
+	Section string      `json:"section,omitempty"`
+	Mode    string      `json:"mode,omitempty"`
+	TTL     int         `json:"ttl,omitempty"` // lock duration in seconds
+}
+
+// reply is a message addressed to a single socket
+type reply struct {
+	Type    string      `json:"type"`
+	Ref     string      `json:"ref,omitempty"`
+	Path    string      `json:"path,omitempty"`
+	Version int         `json:"version,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.leave(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				c.hub.logger.Debugf("WebSocket for inspection %s closed: %v", c.inspectionID, err)
+			}
+			return
+		}
+
+		var msg inbound
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(reply{Type: MessageError, Error: "invalid message"})
+			continue
+		}
+		c.handle(&msg)
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-c.sub.Events():
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, event.Data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) handle(msg *inbound) {
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+
+	switch msg.Type {
+	case MessageEdit:
+		c.edit(ctx, msg)
+
+	case MessagePresence:
+		c.mu.Lock()
+		c.section = msg.Section
+		if msg.Mode == PresenceEditing {
+			c.mode = PresenceEditing
+		} else {
+			c.mode = PresenceViewing
+		}
+		c.mu.Unlock()
+		c.hub.setPresence(ctx, c)
+
+	case MessageLock:
+		ttl := time.Duration(msg.TTL) * time.Second
+		if ttl <= 0 {
+			ttl = defaultLockDuration
+		}
+		if ttl > maxLockDuration {
+			ttl = maxLockDuration
+		}
+		lock, err := c.hub.acquireLock(ctx, c, msg.Path, ttl)
+		if err != nil {
+			c.reply(reply{Type: MessageError, Ref: msg.Ref, Path: msg.Path, Error: err.Error(), Data: lock})
+			return
+		}
+		c.reply(reply{Type: MessageAck, Ref: msg.Ref, Path: msg.Path, Data: lock})
+
+	case MessageUnlock:
+		if err := c.hub.releaseLock(ctx, c, msg.Path); err != nil {
+			c.reply(reply{Type: MessageError, Ref: msg.Ref, Path: msg.Path, Error: err.Error()})
+			return
+		}
+		c.reply(reply{Type: MessageAck, Ref: msg.Ref, Path: msg.Path})
+
+	default:
+		c.reply(reply{Type: MessageError, Ref: msg.Ref, Error: "unknown message type"})
+	}
+}
+
+// edit applies an inbound edit through the inspection service; the
+// resulting field_update reaches every socket through the event stream
+func (c *Client) edit(ctx context.Context, msg *inbound) {
+	if msg.Path == "" {
+		c.reply(reply{Type: MessageError, Ref: msg.Ref, Error: "path is required"})
+		return
+	}
+	if lock, held := c.hub.lockedByOther(ctx, c, msg.Path); held {
+		c.reply(reply{Type: MessageError, Ref: msg.Ref, Path: msg.Path, Error: ErrFieldLocked.Error(), Data: lock})
+		return
+	}
+
+	update := &models.InspectionUpdate{
+		InspectionID: c.inspectionID,
+		Path:         msg.Path,
+		Value:        msg.Value,
+		UpdatedBy:    c.userID,
+		Version:      msg.Version,
+		Timestamp:    time.Now(),
+		Type:         "field_update",
+	}
+	if err := c.hub.editor.UpdateInspectionField(ctx, update); err != nil {
+		c.reply(reply{Type: MessageError, Ref: msg.Ref, Path: msg.Path, Error: err.Error()})
+		return
+	}
+	c.reply(reply{Type: MessageAck, Ref: msg.Ref, Path: msg.Path, Version: update.Version})
+}
+
+// reply queues a message for this socket only; a socket that is not
+// keeping up is dropped
+func (c *Client) reply(r reply) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if !c.sub.send(Event{Type: r.Type, Data: data}) {
+		c.hub.unsubscribe(c.sub)
+	}
+}
+
+// ErrFieldLocked is returned for edits and locks on a path another user holds
+var ErrFieldLocked = errors.New("field is locked by another user")
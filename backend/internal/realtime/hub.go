@@ -0,0 +1,209 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+)
+
+// Editor reads and edits inspections for subscribers; InspectionService
+// implements it
+type Editor interface {
+	GetInspection(ctx context.Context, id uuid.UUID) (*models.Inspection, error)
+	UpdateInspectionField(ctx context.Context, update *models.InspectionUpdate) error
+}
+
+// Hub manages the subscriptions of this replica. Inspection updates are
+// appended to the Redis stream inspection:<id>:events, which every replica
+// with a subscriber of that inspection reads, so sockets on different
+// replicas see the same ordered stream and can resume it after a drop.
+type Hub struct {
+	redis    *redis.Client
+	editor   Editor
+	logger   *zap.SugaredLogger
+	upgrader websocket.Upgrader
+	wakeKey  string
+
+	mu     sync.Mutex
+	rooms  map[uuid.UUID]*room
+	closed bool
+}
+
+// room holds the subscribers of one inspection on this replica
+type room struct {
+	cursor string // ID of the last stream entry routed to the room
+	subs   map[*Subscription]bool
+}
+
+func NewHub(redis *redis.Client, editor Editor) *Hub {
+	logger, _ := zap.NewProduction()
+	return &Hub{
+		redis:  redis,
+		editor: editor,
+		logger: logger.Sugar(),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+		},
+		wakeKey: fmt.Sprintf("realtime:wake:%s", uuid.NewString()),
+		rooms:   make(map[uuid.UUID]*room),
+	}
+}
+
+// Run routes stream events to local subscribers and refreshes presence
+// until ctx is cancelled, then closes every subscription
+func (h *Hub) Run(ctx context.Context) {
+	go h.readStreams(ctx)
+
+	heartbeat := time.NewTicker(presenceRefresh)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.shutdown()
+			return
+		case <-heartbeat.C:
+			h.refreshPresence(ctx)
+		}
+	}
+}
+
+// Serve upgrades the request and attaches the socket to an inspection. With
+// lastEventID the events missed since are replayed after the welcome.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, inspectionID, userID uuid.UUID, lastEventID string) error {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client := &Client{
+		id:           uuid.NewString(),
+		hub:          h,
+		conn:         conn,
+		inspectionID: inspectionID,
+		userID:       userID,
+		mode:         PresenceViewing,
+	}
+	sub, cursor, err := h.subscribe(ctx, inspectionID, lastEventID != "", client)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client.sub = sub
+
+	go client.writePump()
+	go client.readPump()
+
+	h.sendWelcome(ctx, client, cursor)
+	if lastEventID != "" {
+		h.replay(ctx, sub, lastEventID, cursor)
+	}
+	h.setPresence(ctx, client)
+	return nil
+}
+
+// leave detaches a client once; its presence and locks are dropped
+func (h *Hub) leave(client *Client) {
+	client.leave.Do(func() {
+		h.unsubscribe(client.sub)
+
+		h.mu.Lock()
+		closed := h.closed
+		h.mu.Unlock()
+		if closed {
+			return
+		}
+
+		ctx := context.Background()
+		h.clearPresence(ctx, client)
+		h.releaseConnectionLocks(ctx, client)
+	})
+}
+
+// route delivers a stream entry to the subscribers of its inspection
+func (h *Hub) route(inspectionID uuid.UUID, message redis.XMessage) {
+	h.mu.Lock()
+	r, ok := h.rooms[inspectionID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	r.cursor = message.ID
+	subs := make([]*Subscription, 0, len(r.subs))
+	for sub := range r.subs {
+		subs = append(subs, sub)
+	}
+	h.mu.Unlock()
+
+	event, ok := streamEvent(message)
+	if !ok {
+		return
+	}
+	for _, sub := range subs {
+		if !sub.deliver(event) {
+			h.logger.Warnf("Dropping slow subscriber on inspection %s", inspectionID)
+			h.unsubscribe(sub)
+		}
+	}
+}
+
+func (h *Hub) shutdown() {
+	clients := h.localClients()
+
+	h.mu.Lock()
+	h.closed = true
+	var subs []*Subscription
+	for _, r := range h.rooms {
+		for sub := range r.subs {
+			subs = append(subs, sub)
+		}
+	}
+	h.rooms = make(map[uuid.UUID]*room)
+	h.mu.Unlock()
+
+	ctx := context.Background()
+	for _, client := range clients {
+		// Clients reconnect to another replica; drop what they held here
+		h.clearPresence(ctx, client)
+		h.releaseConnectionLocks(ctx, client)
+		client.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(writeWait))
+		client.conn.Close()
+	}
+	for _, sub := range subs {
+		sub.closeEvents()
+	}
+}
+
+// publish appends an update to the event stream of its inspection
+func (h *Hub) publish(ctx context.Context, update *models.InspectionUpdate) {
+	if err := AppendUpdate(ctx, h.redis, update).Err(); err != nil {
+		h.logger.Errorf("Failed to publish %s for inspection %s: %v", update.Type, update.InspectionID, err)
+	}
+}
+
+// localClients returns the sockets connected to this replica
+func (h *Hub) localClients() []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var clients []*Client
+	for _, r := range h.rooms {
+		for sub := range r.subs {
+			if sub.client != nil {
+				clients = append(clients, sub.client)
+			}
+		}
+	}
+	return clients
+}
@@ -0,0 +1,329 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+)
+
+// Presence modes
+const (
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+)
+
+const (
+	presenceRefresh = 20 * time.Second
+	presenceStale   = 3 * presenceRefresh // entries of crashed replicas age out
+	presenceTTL     = 2 * presenceStale
+)
+
+// Presence is one connection looking at an inspection
+type Presence struct {
+	ConnectionID string    `json:"connection_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	Section      string    `json:"section,omitempty"`
+	Mode         string    `json:"mode"`
+	SeenAt       time.Time `json:"seen_at"`
+}
+
+// FieldLock is a soft lock on an inspection path. It only stops edits made
+// over the socket by other users and expires on its own.
+type FieldLock struct {
+	Path         string    `json:"path"`
+	UserID       uuid.UUID `json:"user_id"`
+	ConnectionID string    `json:"connection_id"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	ExpiresMs    int64     `json:"expires_ms"`
+}
+
+// Takes the lock in field ARGV[1] unless another user holds an unexpired
+// one; returns the blocking lock or nil
+var acquireLockScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], ARGV[1])
+if current then
+	local lock = cjson.decode(current)
+	if lock.user_id ~= ARGV[2] and tonumber(lock.expires_ms) > tonumber(ARGV[3]) then
+		return current
+	end
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[5])
+return false`)
+
+// Deletes the lock in field ARGV[1] if the JSON field ARGV[2] equals ARGV[3]
+var releaseLockScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], ARGV[1])
+if not current then
+	return 0
+end
+local lock = cjson.decode(current)
+if lock[ARGV[2]] ~= ARGV[3] then
+	return -1
+end
+return redis.call("HDEL", KEYS[1], ARGV[1])`)
+
+func presenceKey(inspectionID uuid.UUID) string {
+	return fmt.Sprintf("inspection:%s:presence", inspectionID)
+}
+
+func locksKey(inspectionID uuid.UUID) string {
+	return fmt.Sprintf("inspection:%s:locks", inspectionID)
+}
+
+func (c *Client) presence() Presence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Presence{
+		ConnectionID: c.id,
+		UserID:       c.userID,
+		Section:      c.section,
+		Mode:         c.mode,
+		SeenAt:       time.Now(),
+	}
+}
+
+// setPresence records the client's presence and broadcasts the new list
+func (h *Hub) setPresence(ctx context.Context, client *Client) {
+	if err := h.writePresence(ctx, client); err != nil {
+		h.logger.Errorf("Failed to record presence on inspection %s: %v", client.inspectionID, err)
+		return
+	}
+	h.broadcastPresence(ctx, client.inspectionID, client.userID)
+}
+
+func (h *Hub) writePresence(ctx context.Context, client *Client) error {
+	data, err := json.Marshal(client.presence())
+	if err != nil {
+		return err
+	}
+	key := presenceKey(client.inspectionID)
+	pipe := h.redis.Pipeline()
+	pipe.HSet(ctx, key, client.id, data)
+	pipe.Expire(ctx, key, presenceTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (h *Hub) clearPresence(ctx context.Context, client *Client) {
+	if err := h.redis.HDel(ctx, presenceKey(client.inspectionID), client.id).Err(); err != nil {
+		h.logger.Errorf("Failed to clear presence on inspection %s: %v", client.inspectionID, err)
+		return
+	}
+	h.broadcastPresence(ctx, client.inspectionID, client.userID)
+}
+
+// refreshPresence keeps this replica's entries fresh and prunes those of
+// replicas that stopped refreshing theirs
+func (h *Hub) refreshPresence(ctx context.Context) {
+	inspections := make(map[uuid.UUID]bool)
+	for _, client := range h.localClients() {
+		if err := h.writePresence(ctx, client); err != nil {
+			h.logger.Errorf("Failed to refresh presence on inspection %s: %v", client.inspectionID, err)
+		}
+		inspections[client.inspectionID] = true
+	}
+	for inspectionID := range inspections {
+		if pruned := h.prunePresence(ctx, inspectionID); pruned > 0 {
+			h.broadcastPresence(ctx, inspectionID, uuid.Nil)
+		}
+	}
+}
+
+func (h *Hub) prunePresence(ctx context.Context, inspectionID uuid.UUID) int {
+	raw, err := h.redis.HGetAll(ctx, presenceKey(inspectionID)).Result()
+	if err != nil {
+		return 0
+	}
+	var stale []string
+	for id, data := range raw {
+		var p Presence
+		if json.Unmarshal([]byte(data), &p) != nil || time.Since(p.SeenAt) > presenceStale {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		h.redis.HDel(ctx, presenceKey(inspectionID), stale...)
+	}
+	return len(stale)
+}
+
+// Presences lists the live connections to an inspection across replicas
+func (h *Hub) Presences(ctx context.Context, inspectionID uuid.UUID) ([]Presence, error) {
+	raw, err := h.redis.HGetAll(ctx, presenceKey(inspectionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	presences := make([]Presence, 0, len(raw))
+	for _, data := range raw {
+		var p Presence
+		if json.Unmarshal([]byte(data), &p) != nil || time.Since(p.SeenAt) > presenceStale {
+			continue
+		}
+		presences = append(presences, p)
+	}
+	sort.Slice(presences, func(i, j int) bool { return presences[i].ConnectionID < presences[j].ConnectionID })
+	return presences, nil
+}
+
+func (h *Hub) broadcastPresence(ctx context.Context, inspectionID, changedBy uuid.UUID) {
+	presences, err := h.Presences(ctx, inspectionID)
+	if err != nil {
+		h.logger.Errorf("Failed to read presence on inspection %s: %v", inspectionID, err)
+		return
+	}
+	h.publish(ctx, &models.InspectionUpdate{
+		InspectionID: inspectionID,
+		UpdatedBy:    changedBy,
+		Timestamp:    time.Now(),
+		Type:         TypePresence,
+		Metadata:     map[string]interface{}{"viewers": presences},
+	})
+}
+
+// Locks lists the unexpired field locks of an inspection
+func (h *Hub) Locks(ctx context.Context, inspectionID uuid.UUID) ([]FieldLock, error) {
+	raw, err := h.redis.HGetAll(ctx, locksKey(inspectionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	locks := make([]FieldLock, 0, len(raw))
+	for _, data := range raw {
+		var lock FieldLock
+		if json.Unmarshal([]byte(data), &lock) != nil || !lock.ExpiresAt.After(now) {
+			continue
+		}
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Path < locks[j].Path })
+	return locks, nil
+}
+
+// acquireLock takes or renews a soft lock on path for ttl. When another
+// user holds it, that lock is returned with ErrFieldLocked.
+func (h *Hub) acquireLock(ctx context.Context, client *Client, path string, ttl time.Duration) (*FieldLock, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	now := time.Now()
+	lock := FieldLock{
+		Path:         path,
+		UserID:       client.userID,
+		ConnectionID: client.id,
+		ExpiresAt:    now.Add(ttl),
+		ExpiresMs:    now.Add(ttl).UnixMilli(),
+	}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := acquireLockScript.Run(ctx, h.redis, []string{locksKey(client.inspectionID)},
+		path, client.userID.String(), now.UnixMilli(), data, maxLockDuration.Milliseconds()).Text()
+	switch {
+	case err == redis.Nil:
+		// Acquired
+	case err != nil:
+		return nil, err
+	default:
+		var holder FieldLock
+		json.Unmarshal([]byte(held), &holder)
+		return &holder, ErrFieldLocked
+	}
+
+	h.publish(ctx, &models.InspectionUpdate{
+		InspectionID: client.inspectionID,
+		Path:         path,
+		UpdatedBy:    client.userID,
+		Timestamp:    now,
+		Type:         TypeLockAcquired,
+		Metadata:     map[string]interface{}{"expires_at": lock.ExpiresAt},
+	})
+	return &lock, nil
+}
+
+// releaseLock drops the user's lock on path
+func (h *Hub) releaseLock(ctx context.Context, client *Client, path string) error {
+	released, err := releaseLockScript.Run(ctx, h.redis, []string{locksKey(client.inspectionID)},
+		path, "user_id", client.userID.String()).Int()
+	if err != nil {
+		return err
+	}
+	if released < 0 {
+		return ErrFieldLocked
+	}
+	if released > 0 {
+		h.publishLockReleased(ctx, client, path)
+	}
+	return nil
+}
+
+// releaseConnectionLocks drops the locks taken over a closing connection
+func (h *Hub) releaseConnectionLocks(ctx context.Context, client *Client) {
+	locks, err := h.Locks(ctx, client.inspectionID)
+	if err != nil {
+		return
+	}
+	for _, lock := range locks {
+		if lock.ConnectionID != client.id {
+			continue
+		}
+		released, err := releaseLockScript.Run(ctx, h.redis, []string{locksKey(client.inspectionID)},
+			lock.Path, "connection_id", client.id).Int()
+		if err == nil && released > 0 {
+			h.publishLockReleased(ctx, client, lock.Path)
+		}
+	}
+}
+
+func (h *Hub) publishLockReleased(ctx context.Context, client *Client, path string) {
+	h.publish(ctx, &models.InspectionUpdate{
+		InspectionID: client.inspectionID,
+		Path:         path,
+		UpdatedBy:    client.userID,
+		Timestamp:    time.Now(),
+		Type:         TypeLockReleased,
+	})
+}
+
+// lockedByOther reports whether another user holds an unexpired lock on
+// path or on a path containing or contained in it
+func (h *Hub) lockedByOther(ctx context.Context, client *Client, path string) (*FieldLock, bool) {
+	locks, err := h.Locks(ctx, client.inspectionID)
+	if err != nil {
+		// Locks are advisory; do not block edits when Redis hiccups
+		return nil, false
+	}
+	for i := range locks {
+		if locks[i].UserID != client.userID && models.PathsOverlap(locks[i].Path, path) {
+			return &locks[i], true
+		}
+	}
+	return nil, false
+}
+
+// sendWelcome gives a new socket the current version, viewers and locks,
+// and the event ID live delivery continues after
+func (h *Hub) sendWelcome(ctx context.Context, client *Client, lastEventID string) {
+	version, _ := h.redis.HGet(ctx, fmt.Sprintf("inspection:%s", client.inspectionID), "version").Int()
+	presences, _ := h.Presences(ctx, client.inspectionID)
+	locks, _ := h.Locks(ctx, client.inspectionID)
+
+	client.reply(reply{
+		Type:    MessageWelcome,
+		Version: version,
+		Data: map[string]interface{}{
+			"connection_id": client.id,
+			"last_event_id": lastEventID,
+			"viewers":       presences,
+			"locks":         locks,
+		},
+	})
+}
@@ -0,0 +1,424 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+)
+
+const (
+	streamMaxLen = 1000           // events kept per inspection for replay
+	streamTTL    = 24 * time.Hour // matches the inspection cache
+	streamBlock  = 5 * time.Second
+	streamBatch  = 100
+	replayLimit  = 500 // larger gaps are answered with a snapshot
+)
+
+// MessageSnapshot carries the whole inspection to a subscriber whose
+// last_event_id can no longer be replayed. Events that follow it may already
+// be contained in it; clients skip those with a version not above its own.
+const MessageSnapshot = "snapshot"
+
+// Event is one message for a subscriber. ID is the stream position for
+// inspection events and empty for replies addressed to a single socket.
+type Event struct {
+	ID   string
+	Type string
+	Data []byte
+}
+
+// Subscription receives the events of one inspection, starting after the
+// last event the subscriber saw
+type Subscription struct {
+	hub          *Hub
+	inspectionID uuid.UUID
+	client       *Client // nil for Server-Sent Events
+
+	mu        sync.Mutex
+	events    chan Event
+	closed    bool
+	replaying bool
+	pending   []Event // live events held back during replay
+}
+
+// AppendUpdate adds update to the event stream of its inspection. cmd may be
+// the pipeline of the Redis transaction making the change.
+func AppendUpdate(ctx context.Context, cmd redis.Cmdable, update *models.InspectionUpdate) *redis.StringCmd {
+	data, _ := json.Marshal(update)
+	key := streamKey(update.InspectionID)
+	id := cmd.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"type": update.Type, "update": data},
+	})
+	cmd.Expire(ctx, key, streamTTL)
+	return id
+}
+
+// Subscribe streams the events of an inspection. With lastEventID the
+// events after it are replayed first, or a snapshot is sent when they are
+// no longer available.
+func (h *Hub) Subscribe(ctx context.Context, inspectionID uuid.UUID, lastEventID string) (*Subscription, error) {
+	sub, cursor, err := h.subscribe(ctx, inspectionID, lastEventID != "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if lastEventID != "" {
+		h.replay(ctx, sub, lastEventID, cursor)
+	}
+	return sub, nil
+}
+
+// Events returns the subscription's events; it is closed when the
+// subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// subscribe adds a subscription to its room and returns the stream position
+// live delivery starts after. A replaying subscription holds live events
+// back until replay has caught up to that position.
+func (h *Hub) subscribe(ctx context.Context, inspectionID uuid.UUID, replaying bool, client *Client) (*Subscription, string, error) {
+	head, err := h.streamHead(ctx, inspectionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sub := &Subscription{
+		hub:          h,
+		inspectionID: inspectionID,
+		client:       client,
+		events:       make(chan Event, sendBuffer+replayLimit+1),
+		replaying:    replaying,
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, "", fmt.Errorf("hub is shutting down")
+	}
+	r, ok := h.rooms[inspectionID]
+	if !ok {
+		r = &room{cursor: head, subs: make(map[*Subscription]bool)}
+		h.rooms[inspectionID] = r
+	}
+	r.subs[sub] = true
+	cursor := r.cursor
+	h.mu.Unlock()
+
+	if !ok {
+		h.wake(ctx)
+	}
+	return sub, cursor, nil
+}
+
+// unsubscribe removes a subscription and closes its events; it reports
+// whether the subscription was still active
+func (h *Hub) unsubscribe(sub *Subscription) bool {
+	h.mu.Lock()
+	r, ok := h.rooms[sub.inspectionID]
+	active := ok && r.subs[sub]
+	if active {
+		delete(r.subs, sub)
+		if len(r.subs) == 0 {
+			delete(h.rooms, sub.inspectionID)
+		}
+	}
+	h.mu.Unlock()
+
+	sub.closeEvents()
+	return active
+}
+
+// replay sends the events after lastEventID up to cursor, then releases the
+// live events held back meanwhile
+func (h *Hub) replay(ctx context.Context, sub *Subscription, lastEventID, cursor string) {
+	events, ok := h.missedEvents(ctx, sub.inspectionID, lastEventID, cursor)
+	if !ok {
+		snapshot, err := h.snapshot(ctx, sub.inspectionID, cursor)
+		if err != nil {
+			h.logger.Errorf("Failed to build snapshot of inspection %s: %v", sub.inspectionID, err)
+			h.unsubscribe(sub)
+			return
+		}
+		events = []Event{snapshot}
+	}
+
+	for _, event := range events {
+		if !sub.send(event) {
+			h.unsubscribe(sub)
+			return
+		}
+	}
+	if !sub.finishReplay() {
+		h.unsubscribe(sub)
+	}
+}
+
+// missedEvents reads the events in (lastEventID, cursor]. It reports false
+// when they cannot all be replayed: the ID is malformed or unknown, the
+// stream was trimmed past it or the gap exceeds replayLimit.
+func (h *Hub) missedEvents(ctx context.Context, inspectionID uuid.UUID, lastEventID, cursor string) ([]Event, bool) {
+	order, ok := compareStreamIDs(lastEventID, cursor)
+	if !ok || order > 0 {
+		return nil, false
+	}
+	if order == 0 {
+		return nil, true
+	}
+
+	key := streamKey(inspectionID)
+	first, err := h.redis.XRangeN(ctx, key, "-", "+", 1).Result()
+	if err != nil || len(first) == 0 {
+		return nil, false
+	}
+	if order, _ := compareStreamIDs(lastEventID, first[0].ID); order < 0 {
+		// The event itself was trimmed, and maybe some after it
+		return nil, false
+	}
+
+	messages, err := h.redis.XRangeN(ctx, key, "("+lastEventID, cursor, replayLimit+1).Result()
+	if err != nil || len(messages) > replayLimit {
+		return nil, false
+	}
+	events := make([]Event, 0, len(messages))
+	for _, message := range messages {
+		if event, ok := streamEvent(message); ok {
+			events = append(events, event)
+		}
+	}
+	return events, true
+}
+
+// snapshot wraps the current inspection; it reflects at least every event
+// up to cursor
+func (h *Hub) snapshot(ctx context.Context, inspectionID uuid.UUID, cursor string) (Event, error) {
+	inspection, err := h.editor.GetInspection(ctx, inspectionID)
+	if err != nil {
+		return Event{}, err
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       MessageSnapshot,
+		"event_id":   cursor,
+		"version":    inspection.Version,
+		"inspection": inspection,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: cursor, Type: MessageSnapshot, Data: data}, nil
+}
+
+// readStreams routes new stream entries of every room on this replica to
+// its subscribers until ctx is cancelled
+func (h *Hub) readStreams(ctx context.Context) {
+	// The wake stream is private to this hub, so any entry in it is news
+	wakeCursor := "0-0"
+	for ctx.Err() == nil {
+		streams := h.readArgs(wakeCursor)
+		result, err := h.redis.XRead(ctx, &redis.XReadArgs{
+			Streams: streams,
+			Count:   streamBatch,
+			Block:   streamBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			h.logger.Errorf("Failed to read inspection event streams: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for _, stream := range result {
+			if stream.Stream == h.wakeKey {
+				wakeCursor = stream.Messages[len(stream.Messages)-1].ID
+				continue
+			}
+			inspectionID, err := uuid.Parse(strings.TrimSuffix(strings.TrimPrefix(stream.Stream, "inspection:"), ":events"))
+			if err != nil {
+				continue
+			}
+			for _, message := range stream.Messages {
+				h.route(inspectionID, message)
+			}
+		}
+	}
+}
+
+// readArgs lists the streams of every room followed by the position to read
+// after. The wake stream comes first so joining a new room interrupts the
+// blocking read.
+func (h *Hub) readArgs(wakeCursor string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := []string{h.wakeKey}
+	ids := []string{wakeCursor}
+	for inspectionID, r := range h.rooms {
+		keys = append(keys, streamKey(inspectionID))
+		ids = append(ids, r.cursor)
+	}
+	return append(keys, ids...)
+}
+
+func (h *Hub) wake(ctx context.Context) {
+	pipe := h.redis.Pipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: h.wakeKey, MaxLen: 1, Values: map[string]interface{}{"wake": 1}})
+	pipe.Expire(ctx, h.wakeKey, time.Minute)
+	pipe.Exec(ctx)
+}
+
+// streamHead returns the ID of the newest event of an inspection, or 0-0
+func (h *Hub) streamHead(ctx context.Context, inspectionID uuid.UUID) (string, error) {
+	newest, err := h.redis.XRevRangeN(ctx, streamKey(inspectionID), "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(newest) == 0 {
+		return "0-0", nil
+	}
+	return newest[0].ID, nil
+}
+
+// streamEvent decodes a stream entry and stamps the update with its ID
+func streamEvent(message redis.XMessage) (Event, bool) {
+	raw, _ := message.Values["update"].(string)
+	var update models.InspectionUpdate
+	if err := json.Unmarshal([]byte(raw), &update); err != nil {
+		return Event{}, false
+	}
+	update.EventID = message.ID
+	data, err := json.Marshal(update)
+	if err != nil {
+		return Event{}, false
+	}
+	return Event{ID: message.ID, Type: update.Type, Data: data}, true
+}
+
+// deliver queues an inspection event, holding it back while replaying; false
+// means the subscriber is not keeping up
+func (s *Subscription) deliver(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	if s.replaying {
+		if len(s.pending) >= sendBuffer {
+			return false
+		}
+		s.pending = append(s.pending, event)
+		return true
+	}
+	return s.queue(event)
+}
+
+// send queues an event ahead of any held back ones
+func (s *Subscription) send(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	return s.queue(event)
+}
+
+func (s *Subscription) finishReplay() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	for _, event := range s.pending {
+		if !s.queue(event) {
+			return false
+		}
+	}
+	s.pending = nil
+	s.replaying = false
+	return true
+}
+
+func (s *Subscription) queue(event Event) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Subscription) closeEvents() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+func streamKey(inspectionID uuid.UUID) string {
+	return fmt.Sprintf("inspection:%s:events", inspectionID)
+}
+
+// compareStreamIDs orders two stream IDs of the form <ms>-<seq>
+func compareStreamIDs(a, b string) (int, bool) {
+	aMs, aSeq, ok := parseStreamID(a)
+	if !ok {
+		return 0, false
+	}
+	bMs, bSeq, ok := parseStreamID(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1, true
+		}
+		return 1, true
+	case aSeq != bSeq:
+		if aSeq < bSeq {
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, true
+}
+
+func parseStreamID(id string) (uint64, uint64, bool) {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	msValue, err := strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seqValue, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return msValue, seqValue, true
+}
@@ -0,0 +1,51 @@
+package reports
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvWriter struct {
+	w    io.Writer
+	csv  *csv.Writer
+	rows int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteHeader(columns []Column) error {
+	// UTF-8 BOM so spreadsheet software detects accented characters
+	if _, err := c.w.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = col.Title
+	}
+	return c.csv.Write(record)
+}
+
+func (c *csvWriter) WriteRow(row Row) error {
+	record := make([]string, len(row))
+	for i, value := range row {
+		record[i] = formatCell(value)
+	}
+	if err := c.csv.Write(record); err != nil {
+		return err
+	}
+
+	// Flush regularly so the client starts receiving data right away
+	c.rows++
+	if c.rows%100 == 0 {
+		c.csv.Flush()
+	}
+	return c.csv.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.csv.Flush()
+	return c.csv.Error()
+}
@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/macal/inventory/pkg/pdf"
+)
+
+const (
+	pdfMargin    = 30.0
+	pdfRowHeight = 16.0
+	pdfFontSize  = 8.0
+)
+
+var (
+	pdfHeaderColor = pdf.Color{R: 30, G: 64, B: 175}
+	pdfStripeColor = pdf.Color{R: 243, G: 244, B: 246}
+	pdfMutedColor  = pdf.Color{R: 107, G: 114, B: 128}
+)
+
+// pdfWriter lays rows out as a table on landscape A4 pages, repeating the
+// header on every page. Finished pages are flushed by the pdf package.
+type pdfWriter struct {
+	doc     *pdf.Document
+	page    *pdf.Page
+	title   string
+	client  string
+	columns []Column
+	widths  []float64
+	y       float64
+	rows    int
+}
+
+func newPDFWriter(w io.Writer, title, client string) *pdfWriter {
+	doc := pdf.New(w, pdf.A4Height, pdf.A4Width)
+	doc.SetTitle(title)
+	return &pdfWriter{doc: doc, title: title, client: client}
+}
+
+func (p *pdfWriter) WriteHeader(columns []Column) error {
+	p.columns = columns
+
+	total := 0.0
+	for _, col := range columns {
+		total += col.Width
+	}
+	available := p.doc.Width() - 2*pdfMargin
+	p.widths = make([]float64, len(columns))
+	for i, col := range columns {
+		p.widths[i] = available * col.Width / total
+	}
+
+	p.newPage()
+	return nil
+}
+
+func (p *pdfWriter) WriteRow(row Row) error {
+	if p.y+pdfRowHeight > p.doc.Height()-pdfMargin {
+		p.newPage()
+	}
+
+	if p.rows%2 == 1 {
+		p.page.SetFillColor(pdfStripeColor)
+		p.page.Rect(pdfMargin, p.y, p.doc.Width()-2*pdfMargin, pdfRowHeight, pdf.Fill)
+	}
+	p.page.SetFillColor(pdf.Black)
+	p.page.SetFont(pdf.Helvetica, pdfFontSize)
+	p.drawCells(row)
+
+	p.y += pdfRowHeight
+	p.rows++
+	return nil
+}
+
+func (p *pdfWriter) Close() error {
+	if p.page == nil {
+		p.newPage()
+	}
+
+	if p.y+pdfRowHeight > p.doc.Height()-pdfMargin {
+		p.newPage()
+	}
+	p.page.SetFont(pdf.HelveticaBold, pdfFontSize)
+	p.page.Text(pdfMargin, p.y+12, fmt.Sprintf("Total: %d", p.rows))
+
+	return p.doc.Close()
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = p.doc.AddPage()
+	page := p.page
+	width := p.doc.Width()
+
+	page.SetFillColor(pdfHeaderColor)
+	page.SetFont(pdf.HelveticaBold, 14)
+	page.Text(pdfMargin, pdfMargin+10, p.title)
+	page.SetFont(pdf.Helvetica, 9)
+	page.SetFillColor(pdfMutedColor)
+	page.Text(pdfMargin, pdfMargin+24, p.client)
+	page.TextRight(width-pdfMargin, pdfMargin+10, time.Now().Format("02/01/2006 15:04"))
+	page.TextRight(width-pdfMargin, pdfMargin+24, fmt.Sprintf("Página %d", p.doc.PageCount()))
+
+	p.y = pdfMargin + 36
+	page.SetFillColor(pdfHeaderColor)
+	page.Rect(pdfMargin, p.y, width-2*pdfMargin, pdfRowHeight, pdf.Fill)
+	page.SetFillColor(pdf.White)
+	page.SetFont(pdf.HelveticaBold, pdfFontSize)
+
+	header := make(Row, len(p.columns))
+	for i, col := range p.columns {
+		header[i] = col.Title
+	}
+	p.drawCells(header)
+	p.y += pdfRowHeight
+}
+
+func (p *pdfWriter) drawCells(row Row) {
+	x := pdfMargin
+	for i, value := range row {
+		if i >= len(p.widths) {
+			break
+		}
+		text := truncate(p.page, formatCell(value), p.widths[i]-6)
+		p.page.Text(x+3, p.y+11, text)
+		x += p.widths[i]
+	}
+}
+
+// truncate shortens text with an ellipsis so it fits in width points
+func truncate(page *pdf.Page, text string, width float64) string {
+	if page.TextWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && page.TextWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
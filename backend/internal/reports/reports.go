@@ -0,0 +1,234 @@
+// Package reports builds the downloadable vehicle reports offered to client
+// organizations. Rows are read from the database in batches and streamed to
+// the selected writer, so report size is bounded by the client, not memory.
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+)
+
+// Format is an output format for a report
+type Format string
+
+const (
+	FormatPDF   Format = "pdf"
+	FormatExcel Format = "excel"
+	FormatCSV   Format = "csv"
+)
+
+// Report types
+const (
+	TypeVehicleStatus     = "vehicle_status"
+	TypeInspectionSummary = "inspection_summary"
+)
+
+const batchSize = 500
+
+var (
+	ErrUnknownFormat = errors.New("unknown report format")
+	ErrUnknownType   = errors.New("unknown report type")
+	ErrNotPermitted  = errors.New("report not permitted for this client")
+)
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatPDF:
+		return "application/pdf"
+	case FormatExcel:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Extension returns the file extension of the format
+func (f Format) Extension() string {
+	if f == FormatExcel {
+		return "xlsx"
+	}
+	return string(f)
+}
+
+// Valid reports whether f is a supported format
+func (f Format) Valid() bool {
+	return f == FormatPDF || f == FormatExcel || f == FormatCSV
+}
+
+// Options narrows the vehicles included in a report on top of the client's
+// own VehicleFilters
+type Options struct {
+	Status   string     `json:"status,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// Config is the shape of ClientReport.Config for scheduled reports
+type Config struct {
+	Format Format `json:"format"`
+	Options
+}
+
+// ParseConfig decodes a ClientReport.Config, defaulting to PDF output
+func ParseConfig(raw models.JSONB) (Config, error) {
+	var cfg Config
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatPDF
+	}
+	if !cfg.Format.Valid() {
+		return cfg, fmt.Errorf("%w: %s", ErrUnknownFormat, cfg.Format)
+	}
+	return cfg, nil
+}
+
+// ValidType reports whether reportType names a known report
+func ValidType(reportType string) bool {
+	return reportType == TypeVehicleStatus || reportType == TypeInspectionSummary
+}
+
+// Column describes one report column. Width is a relative weight used by
+// the PDF layout.
+type Column struct {
+	Key   string
+	Title string
+	Width float64
+}
+
+// Row holds one value per column. Values are strings, ints, float64s,
+// time.Time or nil.
+type Row []interface{}
+
+// rowWriter serializes rows to a specific format
+type rowWriter interface {
+	WriteHeader(columns []Column) error
+	WriteRow(row Row) error
+	Close() error
+}
+
+// source produces the columns and rows of a report type
+type source interface {
+	Title() string
+	Filename() string
+	Columns() []Column
+	Each(ctx context.Context, db *gorm.DB, fn func(Row) error) error
+}
+
+// Report is a report type resolved for a specific client
+type Report struct {
+	db     *gorm.DB
+	client *models.ClientOrganization
+	source source
+
+	// Progress, when set, is called before the header, every batch of rows
+	// and before the file is finished, so a download can extend its write
+	// deadline as it goes
+	Progress func()
+}
+
+// New resolves reportType for client, checking the client's permissions.
+// An empty type selects the vehicle status report.
+func New(db *gorm.DB, client *models.ClientOrganization, reportType string, opts Options) (*Report, error) {
+	permissions := client.Permissions
+	if !permissions.CanDownloadReports || !permissions.CanViewVehicles {
+		return nil, ErrNotPermitted
+	}
+
+	base := vehicleSource{permissions: permissions, options: opts}
+
+	var src source
+	switch reportType {
+	case "", TypeVehicleStatus:
+		src = &vehicleStatusSource{vehicleSource: base}
+	case TypeInspectionSummary:
+		if !permissions.CanViewInspections {
+			return nil, ErrNotPermitted
+		}
+		src = &inspectionSummarySource{vehicleSource: base}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, reportType)
+	}
+
+	return &Report{db: db, client: client, source: src}, nil
+}
+
+// Filename returns the download filename for the given format
+func (r *Report) Filename(format Format) string {
+	return r.source.Filename() + "." + format.Extension()
+}
+
+// Write streams the report to w in the given format
+func (r *Report) Write(ctx context.Context, format Format, w io.Writer) error {
+	var out rowWriter
+	switch format {
+	case FormatCSV:
+		out = newCSVWriter(w)
+	case FormatExcel:
+		out = newXLSXWriter(w, r.source.Title())
+	case FormatPDF:
+		out = newPDFWriter(w, r.source.Title(), r.client.Name)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	progress := r.Progress
+	if progress == nil {
+		progress = func() {}
+	}
+
+	progress()
+	if err := out.WriteHeader(r.source.Columns()); err != nil {
+		return err
+	}
+	rows := 0
+	err := r.source.Each(ctx, r.db.WithContext(ctx), func(row Row) error {
+		if rows++; rows%batchSize == 0 {
+			progress()
+		}
+		return out.WriteRow(row)
+	})
+	if err != nil {
+		return err
+	}
+	progress()
+	return out.Close()
+}
+
+// formatCell renders a value as text for CSV and PDF output
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("02/01/2006")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return formatCell(*v)
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,287 @@
+package reports
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+)
+
+// vehicleSource selects the vehicles a client is allowed to see
+type vehicleSource struct {
+	permissions models.ClientPermissions
+	options     Options
+}
+
+// vehicleColumn is a column whose visibility follows HiddenFields
+type vehicleColumn struct {
+	Column
+	hideKey string
+	value   func(v *models.Vehicle, owner *models.Owner) interface{}
+}
+
+func (s vehicleSource) query(db *gorm.DB) *gorm.DB {
+	query := s.permissions.VehicleFilters.Apply(db.Model(&models.Vehicle{}))
+
+	if s.options.Status != "" {
+		query = query.Where("status = ?", s.options.Status)
+	}
+	if s.options.DateFrom != nil {
+		query = query.Where("check_in_date >= ?", s.options.DateFrom)
+	}
+	if s.options.DateTo != nil {
+		query = query.Where("check_in_date <= ?", s.options.DateTo)
+	}
+
+	return query.Order("check_in_date DESC")
+}
+
+// eachBatch streams the matching vehicles through a cursor and hands them to
+// fn in batches, together with their owners when the client may see them
+func (s vehicleSource) eachBatch(ctx context.Context, db *gorm.DB, fn func([]models.Vehicle, map[uuid.UUID]*models.Owner) error) error {
+	rows, err := s.query(db).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := make([]models.Vehicle, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		owners, err := s.loadOwners(db, batch)
+		if err != nil {
+			return err
+		}
+		if err := fn(batch, owners); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var vehicle models.Vehicle
+		if err := db.ScanRows(rows, &vehicle); err != nil {
+			return err
+		}
+		batch = append(batch, vehicle)
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func (s vehicleSource) loadOwners(db *gorm.DB, vehicles []models.Vehicle) (map[uuid.UUID]*models.Owner, error) {
+	owners := make(map[uuid.UUID]*models.Owner)
+	if !s.permissions.CanViewOwnerInfo {
+		return owners, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		if vehicle.OwnerID != uuid.Nil {
+			ids = append(ids, vehicle.OwnerID)
+		}
+	}
+	if len(ids) == 0 {
+		return owners, nil
+	}
+
+	var list []models.Owner
+	if err := db.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		owners[list[i].ID] = &list[i]
+	}
+	return owners, nil
+}
+
+// visible drops the columns the client is not allowed to see
+func (s vehicleSource) visible(columns []vehicleColumn) []vehicleColumn {
+	out := make([]vehicleColumn, 0, len(columns))
+	for _, col := range columns {
+		if col.hideKey == "owner" && !s.permissions.CanViewOwnerInfo {
+			continue
+		}
+		if col.hideKey != "" && s.permissions.HidesField(col.hideKey) {
+			continue
+		}
+		out = append(out, col)
+	}
+	return out
+}
+
+func headers(columns []vehicleColumn) []Column {
+	out := make([]Column, len(columns))
+	for i, col := range columns {
+		out[i] = col.Column
+	}
+	return out
+}
+
+// Columns shared by every vehicle based report
+var baseVehicleColumns = []vehicleColumn{
+	{Column: Column{Key: "licensePlate", Title: "Patente", Width: 1}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.LicensePlate }},
+	{Column: Column{Key: "vin", Title: "VIN", Width: 1.8}, hideKey: "vin", value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.VIN }},
+	{Column: Column{Key: "make", Title: "Marca", Width: 1}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Make }},
+	{Column: Column{Key: "model", Title: "Modelo", Width: 1}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Model }},
+}
+
+// vehicleStatusSource lists every visible vehicle with its current status
+type vehicleStatusSource struct {
+	vehicleSource
+}
+
+func (s *vehicleStatusSource) Title() string {
+	return "Estado de Vehículos"
+}
+
+func (s *vehicleStatusSource) Filename() string {
+	return "reporte-vehiculos"
+}
+
+func (s *vehicleStatusSource) columns() []vehicleColumn {
+	columns := append([]vehicleColumn{}, baseVehicleColumns...)
+	columns = append(columns,
+		vehicleColumn{Column: Column{Key: "year", Title: "Año", Width: 0.6}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Year }},
+		vehicleColumn{Column: Column{Key: "color", Title: "Color", Width: 0.8}, hideKey: "color", value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Color }},
+		vehicleColumn{Column: Column{Key: "mileage", Title: "Kilometraje", Width: 0.9}, hideKey: "mileage", value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Mileage }},
+		vehicleColumn{Column: Column{Key: "status", Title: "Estado", Width: 1.1}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Status.Label() }},
+		vehicleColumn{Column: Column{Key: "checkInDate", Title: "Ingreso", Width: 0.9}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.CheckInDate }},
+		vehicleColumn{Column: Column{Key: "checkOutDate", Title: "Salida", Width: 0.9}, hideKey: "checkOutDate", value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.CheckOutDate }},
+		vehicleColumn{Column: Column{Key: "owner", Title: "Propietario", Width: 1.4}, hideKey: "owner", value: ownerName},
+	)
+	return s.visible(columns)
+}
+
+func (s *vehicleStatusSource) Columns() []Column {
+	return headers(s.columns())
+}
+
+func (s *vehicleStatusSource) Each(ctx context.Context, db *gorm.DB, fn func(Row) error) error {
+	columns := s.columns()
+	return s.eachBatch(ctx, db, func(vehicles []models.Vehicle, owners map[uuid.UUID]*models.Owner) error {
+		for i := range vehicles {
+			row := make(Row, len(columns))
+			for j, col := range columns {
+				row[j] = col.value(&vehicles[i], owners[vehicles[i].OwnerID])
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// inspectionSummarySource summarizes the inspections of each visible vehicle
+type inspectionSummarySource struct {
+	vehicleSource
+}
+
+func (s *inspectionSummarySource) Title() string {
+	return "Resumen de Inspecciones"
+}
+
+func (s *inspectionSummarySource) Filename() string {
+	return "reporte-inspecciones"
+}
+
+func (s *inspectionSummarySource) vehicleColumns() []vehicleColumn {
+	columns := append([]vehicleColumn{}, baseVehicleColumns...)
+	columns = append(columns,
+		vehicleColumn{Column: Column{Key: "status", Title: "Estado", Width: 1.1}, value: func(v *models.Vehicle, _ *models.Owner) interface{} { return v.Status.Label() }},
+	)
+	return s.visible(columns)
+}
+
+var inspectionSummaryColumns = []Column{
+	{Key: "inspections", Title: "Inspecciones", Width: 0.8},
+	{Key: "lastInspection", Title: "Última inspección", Width: 1},
+	{Key: "lastInspectionType", Title: "Tipo", Width: 0.7},
+	{Key: "lastInspectionStatus", Title: "Resultado", Width: 0.9},
+	{Key: "ok", Title: "OK", Width: 0.5},
+	{Key: "warnings", Title: "Advertencias", Width: 0.8},
+	{Key: "failures", Title: "Fallas", Width: 0.5},
+}
+
+func (s *inspectionSummarySource) Columns() []Column {
+	return append(headers(s.vehicleColumns()), inspectionSummaryColumns...)
+}
+
+func (s *inspectionSummarySource) Each(ctx context.Context, db *gorm.DB, fn func(Row) error) error {
+	columns := s.vehicleColumns()
+	return s.eachBatch(ctx, db, func(vehicles []models.Vehicle, owners map[uuid.UUID]*models.Owner) error {
+		ids := make([]uuid.UUID, len(vehicles))
+		for i, vehicle := range vehicles {
+			ids[i] = vehicle.ID
+		}
+
+		var inspections []models.Inspection
+		if err := db.Where("vehicle_id IN ?", ids).Order("started_at ASC").Find(&inspections).Error; err != nil {
+			return err
+		}
+
+		counts := make(map[uuid.UUID]int)
+		latest := make(map[uuid.UUID]*models.Inspection)
+		for i := range inspections {
+			counts[inspections[i].VehicleID]++
+			latest[inspections[i].VehicleID] = &inspections[i]
+		}
+
+		for i := range vehicles {
+			vehicle := &vehicles[i]
+			row := make(Row, 0, len(columns)+len(inspectionSummaryColumns))
+			for _, col := range columns {
+				row = append(row, col.value(vehicle, owners[vehicle.OwnerID]))
+			}
+
+			last := latest[vehicle.ID]
+			if last == nil {
+				row = append(row, 0, nil, nil, nil, nil, nil, nil)
+			} else {
+				statuses := last.ItemStatusCounts()
+				row = append(row,
+					counts[vehicle.ID],
+					last.StartedAt,
+					string(last.Type),
+					string(last.Status),
+					statuses[models.ItemStatusOK],
+					statuses[models.ItemStatusWarning],
+					statuses[models.ItemStatusFail],
+				)
+			}
+
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func ownerName(_ *models.Vehicle, owner *models.Owner) interface{} {
+	if owner == nil {
+		return nil
+	}
+	if owner.CompanyName != "" {
+		return owner.CompanyName
+	}
+	return owner.Name
+}
@@ -0,0 +1,221 @@
+package reports
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxWriter streams a single-sheet OOXML workbook. The static parts are
+// written first so the worksheet can be the last zip entry and be written
+// row by row.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	title string
+	row   int
+	err   error
+}
+
+// Cell style indexes defined in xlsxStyles
+const (
+	xlsxStyleHeader = 1
+	xlsxStyleDate   = 2
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="dd/mm/yyyy"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+func newXLSXWriter(w io.Writer, title string) *xlsxWriter {
+	return &xlsxWriter{zip: zip.NewWriter(w), title: title}
+}
+
+func (x *xlsxWriter) WriteHeader(columns []Column) error {
+	x.writePart("[Content_Types].xml", xlsxContentTypes)
+	x.writePart("_rels/.rels", xlsxRootRels)
+	x.writePart("xl/_rels/workbook.xml.rels", xlsxWorkbookRels)
+	x.writePart("xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheetName(x.title))))
+	x.writePart("xl/styles.xml", xlsxStyles)
+	if x.err != nil {
+		return x.err
+	}
+
+	sheet, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(sheet)
+
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	x.sheet.WriteString("<cols>")
+	for i, col := range columns {
+		fmt.Fprintf(x.sheet, `<col min="%d" max="%d" width="%.1f" customWidth="1"/>`, i+1, i+1, 12*col.Width+4)
+	}
+	x.sheet.WriteString("</cols><sheetData>")
+
+	header := make(Row, len(columns))
+	for i, col := range columns {
+		header[i] = col.Title
+	}
+	x.writeRow(header, xlsxStyleHeader)
+	return x.err
+}
+
+func (x *xlsxWriter) WriteRow(row Row) error {
+	x.writeRow(row, 0)
+	return x.err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.sheet != nil {
+		x.sheet.WriteString("</sheetData></worksheet>")
+		if err := x.sheet.Flush(); err != nil && x.err == nil {
+			x.err = err
+		}
+	}
+	if err := x.zip.Close(); err != nil && x.err == nil {
+		x.err = err
+	}
+	return x.err
+}
+
+func (x *xlsxWriter) writePart(name, content string) {
+	if x.err != nil {
+		return
+	}
+	part, err := x.zip.Create(name)
+	if err != nil {
+		x.err = err
+		return
+	}
+	_, x.err = io.WriteString(part, content)
+}
+
+func (x *xlsxWriter) writeRow(row Row, style int) {
+	if x.err != nil {
+		return
+	}
+	x.row++
+
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for i, value := range row {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(x.sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		case time.Time:
+			x.writeDate(ref, v)
+		case *time.Time:
+			if v != nil {
+				x.writeDate(ref, *v)
+			}
+		default:
+			text := formatCell(v)
+			if text == "" {
+				continue
+			}
+			styleAttr := ""
+			if style != 0 {
+				styleAttr = fmt.Sprintf(` s="%d"`, style)
+			}
+			fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, xmlEscape(text))
+		}
+	}
+	_, x.err = x.sheet.WriteString("</row>")
+}
+
+func (x *xlsxWriter) writeDate(ref string, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	fmt.Fprintf(x.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDate, strconv.FormatFloat(excelSerial(t), 'f', 6, 64))
+}
+
+// excelSerial converts t to a spreadsheet date serial number (days since
+// 1899-12-30), keeping the local wall clock time
+func excelSerial(t time.Time) float64 {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// columnName converts a zero based column index to its letter (0 -> A, 26 -> AA)
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// sheetName strips the characters sheet names cannot contain
+func sheetName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', '/', '?', '*', '[', ']', ':':
+			return -1
+		}
+		return r
+	}, title)
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	if name == "" {
+		name = "Reporte"
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
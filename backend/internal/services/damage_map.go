@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/realtime"
+	"gorm.io/gorm"
+)
+
+// ErrDamageNotFound is returned for damage records that are not on the inspection
+var ErrDamageNotFound = errors.New("damage not found")
+
+// InspectionDamages returns the damage recorded during an inspection, oldest
+// first
+func (s *InspectionService) InspectionDamages(ctx context.Context, inspectionID uuid.UUID) ([]models.InspectionDamage, error) {
+	var damages []models.InspectionDamage
+	err := s.db.WithContext(ctx).
+		Where("inspection_id = ?", inspectionID).
+		Order("created_at ASC").
+		Find(&damages).Error
+	return damages, err
+}
+
+// RecordDamage adds a damage to the inspection's damage map and broadcasts
+// it as a damage_added update
+func (s *InspectionService) RecordDamage(ctx context.Context, inspectionID, userID uuid.UUID, input *models.DamageInput) (*models.InspectionDamage, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	inspection, err := s.editableInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveDamageLinks(ctx, inspection, input); err != nil {
+		return nil, err
+	}
+
+	damage := &models.InspectionDamage{
+		InspectionID: inspection.ID,
+		VehicleID:    inspection.VehicleID,
+		RecordedByID: &userID,
+	}
+	input.ApplyTo(damage)
+	err = s.changeDamage(ctx, damageUpdate(damage, "damage_added", userID, damage), func(tx *gorm.DB) error {
+		return tx.Create(damage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return damage, nil
+}
+
+// UpdateDamage replaces the zone, type, severity, notes and links of a damage
+func (s *InspectionService) UpdateDamage(ctx context.Context, inspectionID, damageID, userID uuid.UUID, input *models.DamageInput) (*models.InspectionDamage, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	inspection, err := s.editableInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	damage, err := s.inspectionDamage(ctx, inspectionID, damageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveDamageLinks(ctx, inspection, input); err != nil {
+		return nil, err
+	}
+
+	input.ApplyTo(damage)
+	err = s.changeDamage(ctx, damageUpdate(damage, "damage_updated", userID, damage), func(tx *gorm.DB) error {
+		return tx.Save(damage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return damage, nil
+}
+
+// RemoveDamage deletes a damage from the inspection's damage map
+func (s *InspectionService) RemoveDamage(ctx context.Context, inspectionID, damageID, userID uuid.UUID) error {
+	if _, err := s.editableInspection(ctx, inspectionID); err != nil {
+		return err
+	}
+	damage, err := s.inspectionDamage(ctx, inspectionID, damageID)
+	if err != nil {
+		return err
+	}
+	return s.changeDamage(ctx, damageUpdate(damage, "damage_removed", userID, nil), func(tx *gorm.DB) error {
+		return tx.Delete(damage).Error
+	})
+}
+
+// changeDamage applies write and bumps the inspection's version with it, as
+// edits of the document do, so reports cached for the previous version are
+// not served. Like transitions, the database transaction commits only if the
+// Redis transaction, which fails on any concurrent edit, went through.
+func (s *InspectionService) changeDamage(ctx context.Context, update *models.InspectionUpdate, write func(tx *gorm.DB) error) error {
+	var err error
+	// Edits racing the change make the Redis transaction fail; retry
+	for attempt := 0; attempt < 3; attempt++ {
+		err = s.changeDamageOnce(ctx, update, write)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	return err
+}
+
+func (s *InspectionService) changeDamageOnce(ctx context.Context, update *models.InspectionUpdate, write func(tx *gorm.DB) error) error {
+	key := fmt.Sprintf("inspection:%s", update.InspectionID)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.redis.Watch(ctx, func(rtx *redis.Tx) error {
+			inspection, err := s.loadForUpdate(ctx, rtx, key, update.InspectionID)
+			if err != nil {
+				return err
+			}
+			if !inspection.CanEdit() {
+				return fmt.Errorf("%w: status is %s", ErrInspectionNotEditable, inspection.Status)
+			}
+			if err := write(tx); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			inspection.Version++
+			inspection.UpdatedAt = now
+			update.Version = inspection.Version
+
+			err = tx.Model(&models.Inspection{}).Where("id = ?", inspection.ID).
+				Select(inspectionDocumentColumns).
+				Updates(inspection).Error
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(inspection)
+			if err != nil {
+				return err
+			}
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, key, "data", data)
+				pipe.HSet(ctx, key, "version", inspection.Version)
+				pipe.HSet(ctx, key, "updated_at", now)
+				pipe.Expire(ctx, key, 24*time.Hour)
+				realtime.AppendUpdate(ctx, pipe, update)
+				return nil
+			})
+			return err
+		}, key)
+	})
+}
+
+// VehicleDamageMap compares the damage recorded by the vehicle's latest
+// entry inspection with the exit inspection that followed it. Until both
+// exist, the damage of whichever there is is shown as recorded.
+func (s *InspectionService) VehicleDamageMap(ctx context.Context, vehicleID uuid.UUID) (*models.VehicleDamageMap, error) {
+	entry, err := s.latestInspection(ctx, vehicleID, models.InspectionTypeEntry, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var since time.Time
+	if entry != nil {
+		since = entry.StartedAt
+	}
+	exit, err := s.latestInspection(ctx, vehicleID, models.InspectionTypeExit, since, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := s.compareInspections(ctx, entry, exit)
+	if err != nil {
+		return nil, err
+	}
+
+	var entryRef, exitRef *models.DamageMapInspection
+	if entry != nil {
+		entryRef = models.NewDamageMapInspection(entry)
+	}
+	if exit != nil {
+		exitRef = models.NewDamageMapInspection(exit)
+	}
+	return models.NewVehicleDamageMap(vehicleID, entryRef, exitRef, findings), nil
+}
+
+// inspectionDamageFindings returns the damage of an inspection for its
+// report. Exit inspections are compared with the entry inspection before them.
+func (s *InspectionService) inspectionDamageFindings(ctx context.Context, inspection *models.Inspection) ([]models.DamageFinding, error) {
+	if inspection.Type != models.InspectionTypeExit {
+		return s.compareInspections(ctx, inspection, nil)
+	}
+	entry, err := s.latestInspection(ctx, inspection.VehicleID, models.InspectionTypeEntry, time.Time{}, inspection.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	return s.compareInspections(ctx, entry, inspection)
+}
+
+// compareInspections compares the damage of two inspections, either of which
+// may be nil
+func (s *InspectionService) compareInspections(ctx context.Context, entry, exit *models.Inspection) ([]models.DamageFinding, error) {
+	var entryDamages, exitDamages []models.InspectionDamage
+	var err error
+	if entry != nil {
+		if entryDamages, err = s.InspectionDamages(ctx, entry.ID); err != nil {
+			return nil, err
+		}
+	}
+	if exit != nil {
+		if exitDamages, err = s.InspectionDamages(ctx, exit.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case entry != nil && exit != nil:
+		return models.CompareDamages(entryDamages, exitDamages), nil
+	case exit != nil:
+		return models.RecordedDamages(exitDamages), nil
+	default:
+		return models.RecordedDamages(entryDamages), nil
+	}
+}
+
+// latestInspection returns the vehicle's most recent inspection of a type
+// started within [after, before), zero times leaving that end open. Nil when
+// there is none.
+func (s *InspectionService) latestInspection(ctx context.Context, vehicleID uuid.UUID, inspectionType models.InspectionType, after, before time.Time) (*models.Inspection, error) {
+	query := s.db.WithContext(ctx).Where("vehicle_id = ? AND type = ?", vehicleID, inspectionType)
+	if !after.IsZero() {
+		query = query.Where("started_at >= ?", after)
+	}
+	if !before.IsZero() {
+		query = query.Where("started_at < ?", before)
+	}
+
+	var inspection models.Inspection
+	err := query.Order("started_at DESC").First(&inspection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inspection, nil
+}
+
+func (s *InspectionService) editableInspection(ctx context.Context, inspectionID uuid.UUID) (*models.Inspection, error) {
+	inspection, err := s.GetInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if !inspection.CanEdit() {
+		return nil, fmt.Errorf("%w: status is %s", ErrInspectionNotEditable, inspection.Status)
+	}
+	return inspection, nil
+}
+
+func (s *InspectionService) inspectionDamage(ctx context.Context, inspectionID, damageID uuid.UUID) (*models.InspectionDamage, error) {
+	var damage models.InspectionDamage
+	err := s.db.WithContext(ctx).First(&damage, "id = ? AND inspection_id = ?", damageID, inspectionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDamageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &damage, nil
+}
+
+// resolveDamageLinks checks that the linked photos were taken during the
+// inspection and the linked annotations are drawn on its photos. The photo
+// of a linked annotation is linked too.
+func (s *InspectionService) resolveDamageLinks(ctx context.Context, inspection *models.Inspection, input *models.DamageInput) error {
+	if len(input.AnnotationIDs) > 0 {
+		photoOf := make(map[string]uuid.UUID)
+		for photoID, annotations := range inspection.AnnotationsByPhoto() {
+			for _, annotation := range annotations {
+				photoOf[annotation.ID] = photoID
+			}
+		}
+		for _, id := range input.AnnotationIDs {
+			photoID, ok := photoOf[id]
+			if !ok {
+				return fmt.Errorf("%w: annotation %s is not on a photo of this inspection", models.ErrInvalidDamage, id)
+			}
+			input.PhotoIDs = append(input.PhotoIDs, photoID)
+		}
+	}
+	if len(input.PhotoIDs) == 0 {
+		return nil
+	}
+
+	var found []uuid.UUID
+	err := s.db.WithContext(ctx).Model(&models.VehiclePhoto{}).
+		Where("id IN ? AND inspection_id = ?", input.PhotoIDs, inspection.ID).
+		Pluck("id", &found).Error
+	if err != nil {
+		return err
+	}
+	known := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		known[id] = true
+	}
+	for _, id := range input.PhotoIDs {
+		if !known[id] {
+			return fmt.Errorf("%w: photo %s was not taken during this inspection", models.ErrInvalidDamage, id)
+		}
+	}
+	return nil
+}
+
+// damageUpdate describes a change to the damage map for live viewers of the
+// inspection
+func damageUpdate(damage *models.InspectionDamage, updateType string, userID uuid.UUID, value interface{}) *models.InspectionUpdate {
+	return &models.InspectionUpdate{
+		InspectionID: damage.InspectionID,
+		Path:         fmt.Sprintf("damages.%s", damage.ID),
+		Value:        value,
+		UpdatedBy:    userID,
+		Timestamp:    time.Now(),
+		Type:         updateType,
+		Metadata: map[string]interface{}{
+			"damage_id": damage.ID,
+			"zone":      damage.Zone,
+		},
+	}
+}
+
+// SignDamages fills in the photo links of damage records. For a client,
+// only processed renditions are linked, nothing without CanViewPhotos, and
+// the staff member who recorded the damage is left out.
+func (l *MediaLinks) SignDamages(ctx context.Context, damages []models.InspectionDamage, client *models.ClientOrganization) error {
+	records := make([]*models.InspectionDamage, len(damages))
+	for i := range damages {
+		records[i] = &damages[i]
+	}
+	return l.signDamages(ctx, records, client)
+}
+
+// SignDamageMap fills in the photo links of every damage on the map
+func (l *MediaLinks) SignDamageMap(ctx context.Context, damageMap *models.VehicleDamageMap, client *models.ClientOrganization) error {
+	var records []*models.InspectionDamage
+	for z := range damageMap.Zones {
+		for f := range damageMap.Zones[z].Findings {
+			finding := &damageMap.Zones[z].Findings[f]
+			for i := range finding.Entry {
+				records = append(records, &finding.Entry[i])
+			}
+			for i := range finding.Exit {
+				records = append(records, &finding.Exit[i])
+			}
+		}
+	}
+	return l.signDamages(ctx, records, client)
+}
+
+func (l *MediaLinks) signDamages(ctx context.Context, damages []*models.InspectionDamage, client *models.ClientOrganization) error {
+	if client != nil {
+		for _, damage := range damages {
+			damage.RecordedByID = nil
+		}
+		if !client.Permissions.CanViewPhotos {
+			for _, damage := range damages {
+				damage.PhotoIDs = nil
+				damage.AnnotationIDs = nil
+			}
+			return nil
+		}
+	}
+
+	var ids []uuid.UUID
+	for _, damage := range damages {
+		ids = append(ids, damage.PhotoIDs...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var photos []models.VehiclePhoto
+	if err := l.db.WithContext(ctx).Where("id IN ?", ids).Find(&photos).Error; err != nil {
+		return fmt.Errorf("failed to load photos: %w", err)
+	}
+	byID := make(map[uuid.UUID]*models.VehiclePhoto, len(photos))
+	for i := range photos {
+		byID[photos[i].ID] = &photos[i]
+	}
+
+	expiry := StaffLinkExpiry
+	if client != nil {
+		expiry = client.LinkExpiry(ClientLinkExpiry)
+	}
+	sign := l.Signer(ctx, expiry)
+	for _, damage := range damages {
+		damage.Photos = nil
+		for _, id := range damage.PhotoIDs {
+			photo, ok := byID[id]
+			if !ok {
+				continue
+			}
+			link := models.DamagePhoto{ID: photo.ID}
+			switch {
+			case photo.ClientVisible():
+				link.Thumbnail, link.Display = sign(photo.Thumbnail), sign(photo.Display)
+			case client != nil:
+				continue
+			default:
+				// Staff see photos still being processed as uploaded
+				link.Display = sign(photo.URL)
+				link.Thumbnail = link.Display
+			}
+			damage.Photos = append(damage.Photos, link)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/realtime"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransition is returned for actions the inspection's current
+	// status does not allow
+	ErrInvalidTransition = errors.New("invalid inspection transition")
+	// ErrTransitionForbidden is returned when the actor's role may not take
+	// the action on this inspection
+	ErrTransitionForbidden = errors.New("not allowed to change this inspection")
+	// ErrReasonRequired is returned for rejections and reopenings without one
+	ErrReasonRequired = errors.New("a reason is required")
+)
+
+// inspectionTransition describes one lifecycle action
+type inspectionTransition struct {
+	from   []models.InspectionStatus
+	to     models.InspectionStatus
+	roles  []models.UserRole // inspectors may only act on their own inspections
+	review bool              // the inspector cannot review their own work
+	reason bool
+}
+
+var inspectionTransitions = map[string]inspectionTransition{
+	models.InspectionActionStart: {
+		from:  []models.InspectionStatus{models.InspectionStatusDraft},
+		to:    models.InspectionStatusInProgress,
+		roles: []models.UserRole{models.RoleAdmin, models.RoleSupervisor, models.RoleInspector},
+	},
+	models.InspectionActionComplete: {
+		from:  []models.InspectionStatus{models.InspectionStatusDraft, models.InspectionStatusInProgress, models.InspectionStatusRejected},
+		to:    models.InspectionStatusCompleted,
+		roles: []models.UserRole{models.RoleAdmin, models.RoleSupervisor, models.RoleInspector},
+	},
+	models.InspectionActionApprove: {
+		from:   []models.InspectionStatus{models.InspectionStatusCompleted},
+		to:     models.InspectionStatusApproved,
+		roles:  []models.UserRole{models.RoleAdmin, models.RoleSupervisor},
+		review: true,
+	},
+	models.InspectionActionReject: {
+		from:   []models.InspectionStatus{models.InspectionStatusCompleted},
+		to:     models.InspectionStatusRejected,
+		roles:  []models.UserRole{models.RoleAdmin, models.RoleSupervisor},
+		review: true,
+		reason: true,
+	},
+	models.InspectionActionReopen: {
+		from:   []models.InspectionStatus{models.InspectionStatusCompleted, models.InspectionStatusApproved, models.InspectionStatusRejected},
+		to:     models.InspectionStatusInProgress,
+		roles:  []models.UserRole{models.RoleAdmin, models.RoleSupervisor},
+		reason: true,
+	},
+}
+
+// TransitionInspection applies a lifecycle action on behalf of actorID and
+// records it in the audit trail. Completing validates the inspection first.
+func (s *InspectionService) TransitionInspection(ctx context.Context, inspectionID, actorID uuid.UUID, action, reason string) (*models.Inspection, error) {
+	rule, ok := inspectionTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidTransition, action)
+	}
+	reason = strings.TrimSpace(reason)
+	if rule.reason && reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var actor models.User
+	if err := s.db.WithContext(ctx).First(&actor, "id = ?", actorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown user", ErrTransitionForbidden)
+		}
+		return nil, err
+	}
+
+	var (
+		inspection *models.Inspection
+		from       models.InspectionStatus
+		err        error
+	)
+	// Edits racing the transition make the Redis transaction fail; retry
+	for attempt := 0; attempt < 3; attempt++ {
+		inspection, from, err = s.transitionOnce(ctx, inspectionID, &actor, action, rule, reason)
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.to == models.InspectionStatusCompleted && s.notifier != nil {
+		completed := *inspection
+		go s.notifier.InspectionCompleted(context.Background(), &completed)
+	}
+
+	s.logger.Infof("Inspection %s moved from %s to %s by %s (%s)", inspectionID, from, rule.to, actor.ID, action)
+	s.vehicles.InspectionTransitioned(ctx, inspection, action, actor.ID)
+	return inspection, nil
+}
+
+// transitionOnce changes the status in the cached document and the database
+// together: the database transaction commits only if the Redis transaction,
+// which fails on any concurrent edit, went through
+func (s *InspectionService) transitionOnce(ctx context.Context, inspectionID uuid.UUID, actor *models.User, action string, rule inspectionTransition, reason string) (*models.Inspection, models.InspectionStatus, error) {
+	key := fmt.Sprintf("inspection:%s", inspectionID)
+	var (
+		result *models.Inspection
+		from   models.InspectionStatus
+	)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.redis.Watch(ctx, func(rtx *redis.Tx) error {
+			inspection, err := s.loadForUpdate(ctx, rtx, key, inspectionID)
+			if err != nil {
+				return err
+			}
+			if err := authorizeTransition(inspection, actor, action, rule); err != nil {
+				return err
+			}
+			if rule.to == models.InspectionStatusCompleted {
+				if err := s.validateForCompletion(ctx, inspection); err != nil {
+					return err
+				}
+			}
+
+			now := time.Now()
+			from = inspection.Status
+			inspection.Status = rule.to
+			inspection.Version++
+			inspection.UpdatedAt = now
+
+			switch action {
+			case models.InspectionActionComplete:
+				inspection.CompletedAt = &now
+				inspection.ReviewedByID, inspection.ReviewedAt = nil, nil
+			case models.InspectionActionApprove, models.InspectionActionReject:
+				inspection.ReviewedByID, inspection.ReviewedAt = &actor.ID, &now
+			case models.InspectionActionReopen:
+				inspection.CompletedAt = nil
+				inspection.ReviewedByID, inspection.ReviewedAt = nil, nil
+			}
+			inspection.StatusReason = reason
+
+			err = tx.Model(&models.Inspection{}).Where("id = ?", inspectionID).
+				Select(inspectionDocumentColumns).
+				Updates(inspection).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Create(&models.InspectionTransition{
+				InspectionID: inspectionID,
+				Action:       action,
+				FromStatus:   from,
+				ToStatus:     rule.to,
+				ActorID:      actor.ID,
+				ActorRole:    actor.Role,
+				Reason:       reason,
+				Version:      inspection.Version,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(inspection)
+			if err != nil {
+				return err
+			}
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, key, "data", data)
+				pipe.HSet(ctx, key, "version", inspection.Version)
+				pipe.HSet(ctx, key, "updated_at", now)
+				pipe.Expire(ctx, key, 24*time.Hour)
+				realtime.AppendUpdate(ctx, pipe, &models.InspectionUpdate{
+					InspectionID: inspectionID,
+					Path:         "status",
+					Value:        rule.to,
+					UpdatedBy:    actor.ID,
+					Version:      inspection.Version,
+					Timestamp:    now,
+					Type:         "inspection_" + inspectionActionEvents[action],
+					Metadata: map[string]interface{}{
+						"from":   from,
+						"action": action,
+						"reason": reason,
+					},
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			result = inspection
+			return nil
+		}, key)
+	})
+	return result, from, err
+}
+
+// inspectionDocumentColumns are the columns held authoritatively in the
+// cached document, written by transitions and the write-behind persister
+var inspectionDocumentColumns = []string{
+	"sections", "summary", "signature", "version", "updated_at",
+	"status", "completed_at", "reviewed_by_id", "reviewed_at", "status_reason",
+}
+
+// inspectionActionEvents names the update published for each action
+var inspectionActionEvents = map[string]string{
+	models.InspectionActionStart:    "started",
+	models.InspectionActionComplete: "completed",
+	models.InspectionActionApprove:  "approved",
+	models.InspectionActionReject:   "rejected",
+	models.InspectionActionReopen:   "reopened",
+}
+
+// authorizeTransition checks the inspection's status and the actor's role
+func authorizeTransition(inspection *models.Inspection, actor *models.User, action string, rule inspectionTransition) error {
+	allowedFrom := false
+	for _, status := range rule.from {
+		if inspection.Status == status {
+			allowedFrom = true
+			break
+		}
+	}
+	if !allowedFrom {
+		return fmt.Errorf("%w: cannot %s an inspection that is %s", ErrInvalidTransition, action, inspection.Status)
+	}
+
+	allowedRole := false
+	for _, role := range rule.roles {
+		if actor.Role == role {
+			allowedRole = true
+			break
+		}
+	}
+	if !allowedRole {
+		return fmt.Errorf("%w: %s may not %s inspections", ErrTransitionForbidden, actor.Role, action)
+	}
+	if actor.Role == models.RoleInspector && inspection.InspectorID != actor.ID {
+		return fmt.Errorf("%w: inspectors may only %s their own inspections", ErrTransitionForbidden, action)
+	}
+	if rule.review && inspection.InspectorID == actor.ID {
+		return fmt.Errorf("%w: an inspection cannot be reviewed by its inspector", ErrTransitionForbidden)
+	}
+	return nil
+}
+
+// validateForCompletion checks an inspection against its template, or for
+// free-form inspections that no item is still pending
+func (s *InspectionService) validateForCompletion(ctx context.Context, inspection *models.Inspection) error {
+	if inspection.FormTemplateID != nil {
+		return s.ValidateAgainstTemplate(ctx, inspection)
+	}
+
+	var errs models.FormValidationErrors
+	for name := range inspection.Sections {
+		section, ok := inspection.GetSection(name)
+		if !ok {
+			continue
+		}
+		for _, item := range section.Items {
+			if item.Status == models.ItemStatusPending {
+				errs = append(errs, models.FieldError{
+					SectionID: name,
+					FieldID:   item.ID,
+					Label:     item.Name,
+					Rule:      "required",
+					Message:   fmt.Sprintf("%s is still pending", item.Name),
+				})
+			}
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// InspectionHistory returns the lifecycle audit trail, oldest first
+func (s *InspectionService) InspectionHistory(ctx context.Context, inspectionID uuid.UUID) ([]models.InspectionTransition, error) {
+	var transitions []models.InspectionTransition
+	err := s.db.WithContext(ctx).Preload("Actor").
+		Where("inspection_id = ?", inspectionID).
+		Order("created_at ASC").
+		Find(&transitions).Error
+	return transitions, err
+}
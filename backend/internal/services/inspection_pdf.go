@@ -0,0 +1,828 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"github.com/macal/inventory/pkg/pdf"
+)
+
+const (
+	pdfMargin       = 40.0
+	pdfPhotoSize    = 120.0
+	pdfPhotosPerRow = 4
+	pdfMaxPhotos    = 8
+
+	// Photos without a processed rendition are re-encoded at most this size
+	pdfPhotoMaxSide = 1600
+	pdfPhotoQuality = 85
+)
+
+var (
+	pdfBrandColor  = pdf.Color{R: 30, G: 64, B: 175}
+	pdfMutedColor  = pdf.Color{R: 107, G: 114, B: 128}
+	pdfBorderColor = pdf.Color{R: 209, G: 213, B: 219}
+	pdfPanelColor  = pdf.Color{R: 243, G: 244, B: 246}
+)
+
+// Colours and labels used for each InspectionItemStatus in reports
+var itemStatusColors = map[models.InspectionItemStatus]pdf.Color{
+	models.ItemStatusOK:      {R: 22, G: 163, B: 74},
+	models.ItemStatusWarning: {R: 217, G: 119, B: 6},
+	models.ItemStatusFail:    {R: 220, G: 38, B: 38},
+	models.ItemStatusNA:      {R: 156, G: 163, B: 175},
+	models.ItemStatusPending: {R: 59, G: 130, B: 246},
+}
+
+var itemStatusLabels = map[models.InspectionItemStatus]string{
+	models.ItemStatusOK:      "OK",
+	models.ItemStatusWarning: "Advertencia",
+	models.ItemStatusFail:    "Falla",
+	models.ItemStatusNA:      "N/A",
+	models.ItemStatusPending: "Pendiente",
+}
+
+var itemStatusOrder = []models.InspectionItemStatus{
+	models.ItemStatusOK,
+	models.ItemStatusWarning,
+	models.ItemStatusFail,
+	models.ItemStatusNA,
+	models.ItemStatusPending,
+}
+
+// Colours of the damage map badges: the change at exit when compared with
+// entry, the severity otherwise
+var damageChangeColors = map[models.DamageChange]pdf.Color{
+	models.DamageChangeNew:         {R: 220, G: 38, B: 38},
+	models.DamageChangeWorsened:    {R: 217, G: 119, B: 6},
+	models.DamageChangePreExisting: {R: 107, G: 114, B: 128},
+	models.DamageChangeRepaired:    {R: 22, G: 163, B: 74},
+}
+
+var damageSeverityColors = map[models.DamageSeverity]pdf.Color{
+	models.DamageSeverityMinor:    {R: 59, G: 130, B: 246},
+	models.DamageSeverityModerate: {R: 217, G: 119, B: 6},
+	models.DamageSeveritySevere:   {R: 220, G: 38, B: 38},
+}
+
+// inspectionReport lays out a single inspection as a PDF document
+type inspectionReport struct {
+	ctx        context.Context
+	service    *InspectionService
+	inspection *models.Inspection
+	doc        *pdf.Document
+	page       *pdf.Page
+	y          float64
+	images     map[string]*pdf.Image
+	counts     map[models.InspectionItemStatus]int
+
+	// Photos of the inspection by original URL, embedded as their display
+	// rendition and with their annotations burned in
+	photos      map[string]*models.VehiclePhoto
+	annotations map[uuid.UUID][]models.InspectionAnnotation
+}
+
+func (s *InspectionService) generatePDFReport(ctx context.Context, inspection *models.Inspection) ([]byte, error) {
+	// Cached inspections do not carry their relations
+	if inspection.Vehicle == nil {
+		var vehicle models.Vehicle
+		if err := s.db.First(&vehicle, "id = ?", inspection.VehicleID).Error; err == nil {
+			inspection.Vehicle = &vehicle
+		}
+	}
+	if inspection.Inspector == nil {
+		var inspector models.User
+		if err := s.db.First(&inspector, "id = ?", inspection.InspectorID).Error; err == nil {
+			inspection.Inspector = &inspector
+		}
+	}
+
+	var buf bytes.Buffer
+	r := &inspectionReport{
+		ctx:        ctx,
+		service:    s,
+		inspection: inspection,
+		doc:        pdf.New(&buf, pdf.A4Width, pdf.A4Height),
+		images:     make(map[string]*pdf.Image),
+		counts:     make(map[models.InspectionItemStatus]int),
+		photos:     make(map[string]*models.VehiclePhoto),
+	}
+	if err := r.loadPhotos(); err != nil {
+		return nil, err
+	}
+	r.doc.SetTitle(fmt.Sprintf("Inspección %s", inspection.ID))
+
+	r.newPage()
+	r.renderHeader()
+	r.renderVehicle()
+	sections, err := s.reportSections(ctx, inspection)
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		r.renderSection(section)
+	}
+	findings, err := s.inspectionDamageFindings(ctx, inspection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load damage map: %w", err)
+	}
+	r.renderDamageMap(findings)
+	r.renderSummary()
+
+	if err := r.doc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *inspectionReport) newPage() {
+	r.page = r.doc.AddPage()
+	r.y = pdfMargin
+
+	// Footer with page number
+	r.page.SetFont(pdf.Helvetica, 8)
+	r.page.SetFillColor(pdfMutedColor)
+	r.page.Text(pdfMargin, r.doc.Height()-20, fmt.Sprintf("Inspección %s", r.inspection.ID))
+	r.page.TextRight(r.doc.Width()-pdfMargin, r.doc.Height()-20, fmt.Sprintf("Página %d", r.doc.PageCount()))
+	r.page.SetFillColor(pdf.Black)
+}
+
+// ensureSpace starts a new page when fewer than h points remain
+func (r *inspectionReport) ensureSpace(h float64) {
+	if r.y+h > r.doc.Height()-pdfMargin {
+		r.newPage()
+	}
+}
+
+func (r *inspectionReport) contentWidth() float64 {
+	return r.doc.Width() - 2*pdfMargin
+}
+
+func (r *inspectionReport) renderHeader() {
+	p := r.page
+	p.SetFillColor(pdfBrandColor)
+	p.Rect(0, 0, r.doc.Width(), 70, pdf.Fill)
+
+	p.SetFillColor(pdf.White)
+	p.SetFont(pdf.HelveticaBold, 20)
+	p.Text(pdfMargin, 38, "Informe de Inspección")
+	p.SetFont(pdf.Helvetica, 10)
+	p.Text(pdfMargin, 56, fmt.Sprintf("Tipo: %s   Estado: %s   Versión: %d",
+		inspectionTypeLabel(r.inspection.Type), inspectionStatusLabel(r.inspection.Status), r.inspection.Version))
+
+	p.TextRight(r.doc.Width()-pdfMargin, 38, "MACAL")
+	p.TextRight(r.doc.Width()-pdfMargin, 56, r.inspection.StartedAt.Format("02/01/2006 15:04"))
+
+	p.SetFillColor(pdf.Black)
+	r.y = 90
+}
+
+func (r *inspectionReport) renderVehicle() {
+	vehicle := r.inspection.Vehicle
+	if vehicle == nil {
+		vehicle = &models.Vehicle{ID: r.inspection.VehicleID}
+	}
+
+	inspector := "-"
+	if r.inspection.Inspector != nil {
+		inspector = r.inspection.Inspector.Name
+	}
+	completed := "-"
+	if r.inspection.CompletedAt != nil {
+		completed = r.inspection.CompletedAt.Format("02/01/2006 15:04")
+	}
+
+	rows := [][2]string{
+		{"Patente", vehicle.LicensePlate},
+		{"VIN", vehicle.VIN},
+		{"Marca / Modelo", strings.TrimSpace(vehicle.Make + " " + vehicle.Model)},
+		{"Año", yearLabel(vehicle.Year)},
+		{"Color", vehicle.Color},
+		{"Kilometraje", fmt.Sprintf("%d km", vehicle.Mileage)},
+		{"Inspector", inspector},
+		{"Finalizada", completed},
+	}
+
+	const rowHeight = 16.0
+	half := (len(rows) + 1) / 2
+	boxHeight := float64(half)*rowHeight + 30
+
+	p := r.page
+	p.SetFillColor(pdfPanelColor)
+	p.Rect(pdfMargin, r.y, r.contentWidth(), boxHeight, pdf.Fill)
+
+	p.SetFillColor(pdfBrandColor)
+	p.SetFont(pdf.HelveticaBold, 12)
+	p.Text(pdfMargin+10, r.y+18, "Vehículo")
+
+	colWidth := r.contentWidth() / 2
+	for i, row := range rows {
+		x := pdfMargin + 10 + float64(i/half)*colWidth
+		y := r.y + 36 + float64(i%half)*rowHeight
+		p.SetFillColor(pdfMutedColor)
+		p.SetFont(pdf.Helvetica, 9)
+		p.Text(x, y, row[0])
+		p.SetFillColor(pdf.Black)
+		p.SetFont(pdf.HelveticaBold, 10)
+		value := row[1]
+		if value == "" {
+			value = "-"
+		}
+		p.Text(x+90, y, value)
+	}
+
+	r.y += boxHeight + 20
+}
+
+func (r *inspectionReport) renderSection(section *models.InspectionSection) {
+	r.ensureSpace(60)
+
+	title := section.Name
+
+	p := r.page
+	p.SetFillColor(pdfBrandColor)
+	p.Rect(pdfMargin, r.y, r.contentWidth(), 22, pdf.Fill)
+	p.SetFillColor(pdf.White)
+	p.SetFont(pdf.HelveticaBold, 11)
+	p.Text(pdfMargin+8, r.y+15, title)
+	if section.CompletedAt != nil {
+		p.SetFont(pdf.Helvetica, 9)
+		p.TextRight(r.doc.Width()-pdfMargin-8, r.y+15, "Completada "+section.CompletedAt.Format("02/01/2006 15:04"))
+	}
+	p.SetFillColor(pdf.Black)
+	r.y += 30
+
+	for _, item := range section.Items {
+		r.renderItem(item)
+	}
+
+	if section.Notes != "" {
+		r.renderParagraph("Observaciones: "+section.Notes, pdf.HelveticaOblique, 9, pdfMargin)
+	}
+	if len(section.Photos) > 0 {
+		r.renderPhotos(section.Photos)
+	}
+
+	r.y += 10
+}
+
+func (r *inspectionReport) renderItem(item models.InspectionItem) {
+	status := item.Status
+	if status == "" {
+		status = models.ItemStatusPending
+	}
+	r.counts[status]++
+
+	label := item.Name
+	if label == "" {
+		label = item.ID
+	}
+	value := formatItemValue(item.Value)
+
+	const badgeWidth = 70.0
+	textX := pdfMargin + badgeWidth + 10
+	textWidth := r.contentWidth() - badgeWidth - 10
+	lines := pdf.WrapText(pdf.HelveticaBold, 10, label, textWidth)
+	height := float64(len(lines))*13 + 6
+	if value != "" {
+		height += 12
+	}
+	r.ensureSpace(height)
+
+	p := r.page
+	p.SetFillColor(statusColor(status))
+	p.Rect(pdfMargin, r.y, badgeWidth, 16, pdf.Fill)
+	p.SetFillColor(pdf.White)
+	p.SetFont(pdf.HelveticaBold, 8)
+	statusLabel := statusLabel(status)
+	p.Text(pdfMargin+(badgeWidth-p.TextWidth(statusLabel))/2, r.y+11, statusLabel)
+
+	p.SetFillColor(pdf.Black)
+	p.SetFont(pdf.HelveticaBold, 10)
+	for i, line := range lines {
+		p.Text(textX, r.y+11+float64(i)*13, line)
+	}
+	r.y += float64(len(lines))*13 + 4
+
+	if value != "" {
+		p.SetFont(pdf.Helvetica, 9)
+		p.SetFillColor(pdfMutedColor)
+		p.Text(textX, r.y+8, "Valor: "+value)
+		p.SetFillColor(pdf.Black)
+		r.y += 12
+	}
+	if item.Notes != "" {
+		r.renderParagraph(item.Notes, pdf.HelveticaOblique, 9, textX)
+	}
+	if len(item.Photos) > 0 {
+		r.renderPhotos(item.Photos)
+	}
+
+	p = r.page
+	p.SetStrokeColor(pdfBorderColor)
+	p.SetLineWidth(0.5)
+	p.Line(pdfMargin, r.y+2, r.doc.Width()-pdfMargin, r.y+2)
+	r.y += 8
+}
+
+// renderDamageMap lists the damage found zone by zone. Exit inspections
+// compared with their entry inspection flag new and worsened damage.
+func (r *inspectionReport) renderDamageMap(findings []models.DamageFinding) {
+	if len(findings) == 0 {
+		return
+	}
+	r.ensureSpace(60)
+
+	compared := false
+	newDamage := 0
+	for i := range findings {
+		if findings[i].Change != models.DamageChangeRecorded {
+			compared = true
+		}
+		if findings[i].IsNew() {
+			newDamage++
+		}
+	}
+
+	p := r.page
+	p.SetFillColor(pdfBrandColor)
+	p.Rect(pdfMargin, r.y, r.contentWidth(), 22, pdf.Fill)
+	p.SetFillColor(pdf.White)
+	p.SetFont(pdf.HelveticaBold, 11)
+	p.Text(pdfMargin+8, r.y+15, "Mapa de daños")
+	if compared {
+		p.SetFont(pdf.Helvetica, 9)
+		p.TextRight(r.doc.Width()-pdfMargin-8, r.y+15, fmt.Sprintf("Daños nuevos o agravados: %d", newDamage))
+	}
+	p.SetFillColor(pdf.Black)
+	r.y += 30
+
+	for i := range findings {
+		r.renderDamageFinding(&findings[i], compared)
+	}
+	r.y += 10
+}
+
+func (r *inspectionReport) renderDamageFinding(f *models.DamageFinding, compared bool) {
+	badge, color := f.Severity.Label(), damageSeverityColors[f.Severity]
+	if compared {
+		badge, color = f.Change.Label(), damageChangeColors[f.Change]
+	}
+	if color == (pdf.Color{}) {
+		color = pdfMutedColor
+	}
+
+	detail := "Severidad: " + f.Severity.Label()
+	if f.Change == models.DamageChangeWorsened {
+		detail += fmt.Sprintf(" (ingreso: %s)", f.EntrySeverity.Label())
+	}
+
+	// Notes of the latest inspection that found it
+	records := f.Exit
+	if len(records) == 0 {
+		records = f.Entry
+	}
+	var notes []string
+	for _, d := range records {
+		if d.Notes != "" {
+			notes = append(notes, d.Notes)
+		}
+	}
+
+	const badgeWidth = 70.0
+	textX := pdfMargin + badgeWidth + 10
+	r.ensureSpace(32)
+
+	p := r.page
+	p.SetFillColor(color)
+	p.Rect(pdfMargin, r.y, badgeWidth, 16, pdf.Fill)
+	p.SetFillColor(pdf.White)
+	p.SetFont(pdf.HelveticaBold, 8)
+	p.Text(pdfMargin+(badgeWidth-p.TextWidth(badge))/2, r.y+11, badge)
+
+	p.SetFillColor(pdf.Black)
+	p.SetFont(pdf.HelveticaBold, 10)
+	p.Text(textX, r.y+11, f.Zone.Label()+" - "+f.Type.Label())
+	p.SetFont(pdf.Helvetica, 9)
+	p.SetFillColor(pdfMutedColor)
+	p.Text(textX, r.y+25, detail)
+	p.SetFillColor(pdf.Black)
+	r.y += 30
+
+	if len(notes) > 0 {
+		r.renderParagraph(strings.Join(notes, "; "), pdf.HelveticaOblique, 9, textX)
+	}
+
+	p = r.page
+	p.SetStrokeColor(pdfBorderColor)
+	p.SetLineWidth(0.5)
+	p.Line(pdfMargin, r.y+2, r.doc.Width()-pdfMargin, r.y+2)
+	r.y += 8
+}
+
+func (r *inspectionReport) renderParagraph(text string, font pdf.Font, size, x float64) {
+	lineHeight := size + 3
+	for _, line := range pdf.WrapText(font, size, text, r.doc.Width()-pdfMargin-x) {
+		r.ensureSpace(lineHeight)
+		r.page.SetFont(font, size)
+		r.page.SetFillColor(pdfMutedColor)
+		r.page.Text(x, r.y+size, line)
+		r.y += lineHeight
+	}
+	r.page.SetFillColor(pdf.Black)
+}
+
+// renderPhotos lays photos out in a grid, scaling each one into a square cell
+func (r *inspectionReport) renderPhotos(urls []string) {
+	if len(urls) > pdfMaxPhotos {
+		urls = urls[:pdfMaxPhotos]
+	}
+
+	gap := (r.contentWidth() - pdfPhotosPerRow*pdfPhotoSize) / (pdfPhotosPerRow - 1)
+	col := 0
+	for _, url := range urls {
+		img := r.loadImage(url)
+		if img == nil {
+			continue
+		}
+
+		if col == 0 {
+			r.ensureSpace(pdfPhotoSize + 8)
+		}
+
+		w, h := fitBox(img.Width, img.Height, pdfPhotoSize, pdfPhotoSize)
+		x := pdfMargin + float64(col)*(pdfPhotoSize+gap) + (pdfPhotoSize-w)/2
+		y := r.y + 4 + (pdfPhotoSize-h)/2
+		r.page.DrawImage(img, x, y, w, h)
+
+		col++
+		if col == pdfPhotosPerRow {
+			col = 0
+			r.y += pdfPhotoSize + 8
+		}
+	}
+	if col > 0 {
+		r.y += pdfPhotoSize + 8
+	}
+}
+
+func (r *inspectionReport) renderSummary() {
+	r.newPage()
+	p := r.page
+
+	p.SetFillColor(pdfBrandColor)
+	p.SetFont(pdf.HelveticaBold, 16)
+	p.Text(pdfMargin, r.y+16, "Resumen")
+	r.y += 36
+
+	total := 0
+	for _, n := range r.counts {
+		total += n
+	}
+
+	const barHeight = 18.0
+	barWidth := r.contentWidth() - 160
+	for _, status := range itemStatusOrder {
+		n := r.counts[status]
+		p.SetFillColor(pdf.Black)
+		p.SetFont(pdf.Helvetica, 10)
+		p.Text(pdfMargin, r.y+13, statusLabel(status))
+		p.SetFillColor(pdfPanelColor)
+		p.Rect(pdfMargin+100, r.y, barWidth, barHeight, pdf.Fill)
+		if total > 0 && n > 0 {
+			p.SetFillColor(statusColor(status))
+			p.Rect(pdfMargin+100, r.y, barWidth*float64(n)/float64(total), barHeight, pdf.Fill)
+		}
+		p.SetFillColor(pdf.Black)
+		p.TextRight(r.doc.Width()-pdfMargin, r.y+13, fmt.Sprintf("%d", n))
+		r.y += barHeight + 6
+	}
+
+	p.SetFont(pdf.HelveticaBold, 10)
+	p.Text(pdfMargin, r.y+14, fmt.Sprintf("Total de puntos inspeccionados: %d", total))
+	r.y += 30
+
+	if r.inspection.Summary != "" {
+		p.SetFont(pdf.HelveticaBold, 11)
+		p.Text(pdfMargin, r.y+12, "Observaciones generales")
+		r.y += 20
+		r.renderParagraph(r.inspection.Summary, pdf.Helvetica, 10, pdfMargin)
+		r.y += 10
+	}
+
+	r.renderSignature()
+
+	r.ensureSpace(20)
+	r.page.SetFont(pdf.Helvetica, 8)
+	r.page.SetFillColor(pdfMutedColor)
+	r.page.Text(pdfMargin, r.y+10, "Generado el "+time.Now().Format("02/01/2006 15:04"))
+}
+
+func (r *inspectionReport) renderSignature() {
+	const boxWidth, boxHeight = 220.0, 90.0
+	r.ensureSpace(boxHeight + 40)
+
+	p := r.page
+	p.SetFillColor(pdf.Black)
+	p.SetFont(pdf.HelveticaBold, 11)
+	p.Text(pdfMargin, r.y+12, "Firma del inspector")
+	r.y += 20
+
+	if img := r.loadSignature(); img != nil {
+		w, h := fitBox(img.Width, img.Height, boxWidth, boxHeight)
+		p.DrawImage(img, pdfMargin, r.y, w, h)
+	} else {
+		p.SetFont(pdf.HelveticaOblique, 9)
+		p.SetFillColor(pdfMutedColor)
+		p.Text(pdfMargin, r.y+boxHeight/2, "Sin firma registrada")
+		p.SetFillColor(pdf.Black)
+	}
+
+	p.SetStrokeColor(pdf.Black)
+	p.SetLineWidth(0.75)
+	p.Line(pdfMargin, r.y+boxHeight+4, pdfMargin+boxWidth, r.y+boxHeight+4)
+	if r.inspection.Inspector != nil {
+		p.SetFont(pdf.Helvetica, 9)
+		p.Text(pdfMargin, r.y+boxHeight+16, r.inspection.Inspector.Name)
+	}
+	r.y += boxHeight + 30
+}
+
+// loadPhotos finds the processed renditions and annotations of the
+// inspection's photos
+func (r *inspectionReport) loadPhotos() error {
+	r.annotations = r.inspection.AnnotationsByPhoto()
+
+	var photos []models.VehiclePhoto
+	if err := r.service.db.WithContext(r.ctx).Where("inspection_id = ?", r.inspection.ID).Find(&photos).Error; err != nil {
+		return fmt.Errorf("failed to load inspection photos: %w", err)
+	}
+	for i := range photos {
+		r.photos[photos[i].URL] = &photos[i]
+	}
+	return nil
+}
+
+// loadImage fetches a photo through storage and embeds it once per document.
+// Originals keep their EXIF, GPS included, so the display rendition is
+// embedded instead, with any annotations burned in; photos not processed
+// yet are decoded and re-encoded rather than passed through.
+func (r *inspectionReport) loadImage(url string) *pdf.Image {
+	if img, ok := r.images[url]; ok {
+		return img
+	}
+	r.images[url] = nil
+
+	key, ok := r.service.storage.KeyFromURL(url)
+	if !ok {
+		r.service.logger.Warnf("Skipping photo outside storage in PDF: %s", url)
+		return nil
+	}
+
+	var data []byte
+	if photo, ok := r.photos[url]; ok {
+		switch {
+		case len(r.annotations[photo.ID]) > 0:
+			rendered, err := RenderAnnotatedPhoto(r.ctx, r.service.storage, photo, r.annotations[photo.ID], AnnotatedPhotoMaxSide)
+			if err != nil {
+				// Better the bare photo than none
+				r.service.logger.Errorf("Failed to render annotations of photo %s for PDF: %v", photo.ID, err)
+			}
+			data = rendered
+		case photo.ClientVisible():
+			if displayKey, ok := r.service.storage.KeyFromURL(photo.Display); ok {
+				display, err := r.service.storage.Download(r.ctx, displayKey)
+				if err != nil {
+					r.service.logger.Errorf("Failed to download display rendition %s for PDF: %v", displayKey, err)
+				}
+				data = display
+			}
+		}
+	}
+	if data == nil {
+		downloaded, err := r.service.storage.Download(r.ctx, key)
+		if err != nil {
+			r.service.logger.Errorf("Failed to download photo %s for PDF: %v", key, err)
+			return nil
+		}
+		if data, err = reencodePDFPhoto(downloaded); err != nil {
+			r.service.logger.Errorf("Failed to re-encode photo %s for PDF: %v", key, err)
+			return nil
+		}
+	}
+
+	img, err := r.doc.AddImageData(data)
+	if err != nil {
+		r.service.logger.Errorf("Failed to embed photo %s in PDF: %v", key, err)
+		return nil
+	}
+	r.images[url] = img
+	return img
+}
+
+// reencodePDFPhoto decodes a JPEG and encodes it again upright, scaled down
+// and without its EXIF, as the PDF would otherwise embed the file as is.
+// Other formats are decoded to pixels by the PDF writer anyway.
+func reencodePDFPhoto(data []byte) ([]byte, error) {
+	if format, _ := imaging.Sniff(data); format != imaging.FormatJPEG {
+		return data, nil
+	}
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if exif := imaging.ReadEXIF(data); exif != nil {
+		img = imaging.Orient(img, exif.Orientation)
+	}
+	return imaging.EncodeJPEG(imaging.Fit(img, pdfPhotoMaxSide), pdfPhotoQuality)
+}
+
+// loadSignature accepts either a data URL from the signature pad or a
+// storage URL
+func (r *inspectionReport) loadSignature() *pdf.Image {
+	signature := r.inspection.Signature
+	if signature == "" {
+		return nil
+	}
+	if !strings.HasPrefix(signature, "data:") {
+		return r.loadImage(signature)
+	}
+
+	comma := strings.Index(signature, ",")
+	if comma < 0 {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(signature[comma+1:])
+	if err != nil {
+		r.service.logger.Errorf("Failed to decode signature: %v", err)
+		return nil
+	}
+	img, err := r.doc.AddImageData(data)
+	if err != nil {
+		r.service.logger.Errorf("Failed to embed signature in PDF: %v", err)
+		return nil
+	}
+	return img
+}
+
+// reportSections returns the sections to print. Inspections filled against
+// a template follow the order and labels of the template version they used,
+// so historical reports keep their original wording.
+func (s *InspectionService) reportSections(ctx context.Context, inspection *models.Inspection) ([]*models.InspectionSection, error) {
+	template, err := s.InspectionTemplate(ctx, inspection)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return sortedSections(inspection), nil
+	}
+	config, err := template.ParseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []*models.InspectionSection
+	for _, view := range config.SideBySide(inspection) {
+		section := &models.InspectionSection{
+			Name:        view.Name,
+			Notes:       view.Notes,
+			Photos:      view.Photos,
+			CompletedAt: view.CompletedAt,
+		}
+		for _, field := range view.Fields {
+			if !field.Answered {
+				continue
+			}
+			section.Items = append(section.Items, models.InspectionItem{
+				ID:     field.ID,
+				Name:   field.Label,
+				Status: field.Status,
+				Value:  field.Value,
+				Notes:  field.Notes,
+				Photos: field.Photos,
+			})
+		}
+		section.Items = append(section.Items, view.Unmatched...)
+		if len(section.Items) == 0 && section.Notes == "" && len(section.Photos) == 0 {
+			continue
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// sortedSections returns a free-form inspection's sections by key
+func sortedSections(inspection *models.Inspection) []*models.InspectionSection {
+	names := make([]string, 0, len(inspection.Sections))
+	for name := range inspection.Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sections := make([]*models.InspectionSection, 0, len(names))
+	for _, name := range names {
+		if section, ok := inspection.GetSection(name); ok {
+			if section.Name == "" {
+				section.Name = name
+			}
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// fitBox scales width x height to fit inside maxW x maxH keeping aspect ratio
+func fitBox(width, height int, maxW, maxH float64) (float64, float64) {
+	if width <= 0 || height <= 0 {
+		return maxW, maxH
+	}
+	scale := maxW / float64(width)
+	if s := maxH / float64(height); s < scale {
+		scale = s
+	}
+	return float64(width) * scale, float64(height) * scale
+}
+
+func statusColor(status models.InspectionItemStatus) pdf.Color {
+	if c, ok := itemStatusColors[status]; ok {
+		return c
+	}
+	return pdfMutedColor
+}
+
+func statusLabel(status models.InspectionItemStatus) string {
+	if label, ok := itemStatusLabels[status]; ok {
+		return label
+	}
+	return string(status)
+}
+
+func formatItemValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "Sí"
+		}
+		return "No"
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprintf("%d", int64(v))
+		}
+		return fmt.Sprintf("%.2f", v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = formatItemValue(part)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func yearLabel(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", year)
+}
+
+func inspectionTypeLabel(t models.InspectionType) string {
+	switch t {
+	case models.InspectionTypeEntry:
+		return "Ingreso"
+	case models.InspectionTypeRoutine:
+		return "Rutina"
+	case models.InspectionTypeExit:
+		return "Salida"
+	case models.InspectionTypeSpecial:
+		return "Especial"
+	}
+	return string(t)
+}
+
+func inspectionStatusLabel(status models.InspectionStatus) string {
+	switch status {
+	case models.InspectionStatusDraft:
+		return "Borrador"
+	case models.InspectionStatusInProgress:
+		return "En progreso"
+	case models.InspectionStatusCompleted:
+		return "Completada"
+	case models.InspectionStatusApproved:
+		return "Aprobada"
+	case models.InspectionStatusRejected:
+		return "Rechazada"
+	}
+	return string(status)
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	persistQueueKey    = "inspections:dirty"          // ZSET of inspection IDs scored by when to flush
+	persistAttemptsKey = "inspections:dirty:attempts" // HASH of failed flush attempts per ID
+	persistStatsKey    = "inspections:dirty:stats"    // HASH of counters shared by all replicas
+	persistDebounce    = 5 * time.Second
+	persistTick        = time.Second
+	persistBatch       = 100
+	persistLease       = time.Minute // claimed entries reappear if a replica dies mid-flush
+	persistMaxBackoff  = 5 * time.Minute
+	persistLagWarning  = time.Minute
+)
+
+// Claims up to ARGV[2] entries due at ARGV[1] by pushing their score past
+// the lease so other replicas skip them while they are flushed
+var claimDirtyScript = redis.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call("ZADD", KEYS[1], ARGV[3], id)
+end
+return ids`)
+
+// Removes a flushed entry unless the inspection changed while it was being
+// written, in which case it is scheduled again
+var settleDirtyScript = redis.NewScript(`
+local version = tonumber(redis.call("HGET", KEYS[2], "version") or "0")
+if version > tonumber(ARGV[2]) then
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+	return 0
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+return 1`)
+
+// InspectionPersister writes real-time inspection edits from Redis to
+// Postgres. Edits only mark the inspection dirty in a Redis sorted set; the
+// worker flushes each inspection at most once per debounce window, so a
+// burst of edits costs one write and survives an API restart.
+type InspectionPersister struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	logger *zap.SugaredLogger
+}
+
+// PersisterStats describes the write-behind backlog and recent activity
+// across all replicas
+type PersisterStats struct {
+	Pending     int64      `json:"pending"`     // dirty inspections waiting to be written
+	Due         int64      `json:"due"`         // of those, already past their flush time
+	LagSeconds  float64    `json:"lag_seconds"` // how long the most overdue entry has waited
+	Flushed     int64      `json:"flushed"`     // successful writes
+	Failed      int64      `json:"failed"`      // failed writes, each retried
+	LastFlushAt *time.Time `json:"last_flush_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+func NewInspectionPersister(db *gorm.DB, redis *redis.Client) *InspectionPersister {
+	logger, _ := zap.NewProduction()
+	return &InspectionPersister{
+		db:     db,
+		redis:  redis,
+		logger: logger.Sugar(),
+	}
+}
+
+// MarkDirty schedules an inspection to be written. Further edits inside the
+// debounce window do not push the flush back, which bounds the lag.
+func (p *InspectionPersister) MarkDirty(ctx context.Context, inspectionID uuid.UUID) error {
+	return markInspectionDirty(ctx, p.redis, inspectionID).Err()
+}
+
+// markInspectionDirty queues the write on cmd, which may be the pipeline of
+// the Redis transaction making the edit so both happen atomically
+func markInspectionDirty(ctx context.Context, cmd redis.Cmdable, inspectionID uuid.UUID) *redis.IntCmd {
+	due := time.Now().Add(persistDebounce)
+	return cmd.ZAddNX(ctx, persistQueueKey, &redis.Z{
+		Score:  float64(due.UnixMilli()),
+		Member: inspectionID.String(),
+	})
+}
+
+// Run flushes due inspections until ctx is cancelled
+func (p *InspectionPersister) Run(ctx context.Context) {
+	ticker := time.NewTicker(persistTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.flushDue(ctx, time.Now())
+			p.warnOnLag(ctx)
+		}
+	}
+}
+
+// Flush writes every dirty inspection regardless of its debounce window.
+// It is called on shutdown once no more edits are accepted.
+func (p *InspectionPersister) Flush(ctx context.Context) error {
+	// Bounded so entries that keep failing cannot keep shutdown busy
+	for round := 0; round < 20; round++ {
+		claimed, err := p.flushDue(ctx, time.Now().Add(persistMaxBackoff+persistLease))
+		if err != nil {
+			return err
+		}
+		if claimed < persistBatch {
+			break
+		}
+	}
+
+	pending, err := p.redis.ZCard(ctx, persistQueueKey).Result()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d inspections could not be persisted", pending)
+	}
+	return nil
+}
+
+// Stats returns the current backlog and counters
+func (p *InspectionPersister) Stats(ctx context.Context) (PersisterStats, error) {
+	var stats PersisterStats
+
+	counters, err := p.redis.HGetAll(ctx, persistStatsKey).Result()
+	if err != nil {
+		return stats, err
+	}
+	stats.Flushed, _ = strconv.ParseInt(counters["flushed"], 10, 64)
+	stats.Failed, _ = strconv.ParseInt(counters["failed"], 10, 64)
+	stats.LastError = counters["last_error"]
+	if at, err := time.Parse(time.RFC3339Nano, counters["last_flush_at"]); err == nil {
+		stats.LastFlushAt = &at
+	}
+
+	now := time.Now()
+	pending, err := p.redis.ZCard(ctx, persistQueueKey).Result()
+	if err != nil {
+		return stats, err
+	}
+	due, err := p.redis.ZCount(ctx, persistQueueKey, "-inf", fmt.Sprint(now.UnixMilli())).Result()
+	if err != nil {
+		return stats, err
+	}
+	stats.Pending, stats.Due = pending, due
+
+	oldest, err := p.redis.ZRangeWithScores(ctx, persistQueueKey, 0, 0).Result()
+	if err != nil {
+		return stats, err
+	}
+	if len(oldest) > 0 {
+		if lag := now.Sub(time.UnixMilli(int64(oldest[0].Score))); lag > 0 {
+			stats.LagSeconds = lag.Seconds()
+		}
+	}
+	return stats, nil
+}
+
+// flushDue claims the entries due at until and writes them. It returns how
+// many entries were claimed.
+func (p *InspectionPersister) flushDue(ctx context.Context, until time.Time) (int, error) {
+	leaseUntil := time.Now().Add(persistLease).UnixMilli()
+	ids, err := claimDirtyScript.Run(ctx, p.redis, []string{persistQueueKey},
+		until.UnixMilli(), persistBatch, leaseUntil).StringSlice()
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Errorf("Failed to claim dirty inspections: %v", err)
+		}
+		return 0, err
+	}
+
+	for _, raw := range ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			p.redis.ZRem(ctx, persistQueueKey, raw)
+			continue
+		}
+		p.flushOne(ctx, id)
+	}
+	return len(ids), nil
+}
+
+func (p *InspectionPersister) flushOne(ctx context.Context, id uuid.UUID) {
+	cacheKey := fmt.Sprintf("inspection:%s", id)
+
+	version, err := p.persist(ctx, cacheKey)
+	if err != nil {
+		p.retry(ctx, id, err)
+		return
+	}
+
+	next := time.Now().Add(persistDebounce).UnixMilli()
+	err = settleDirtyScript.Run(ctx, p.redis, []string{persistQueueKey, cacheKey, persistAttemptsKey},
+		id.String(), version, next).Err()
+	if err != nil {
+		// The lease expires and the entry is written again, which is harmless
+		p.logger.Warnf("Failed to settle persisted inspection %s: %v", id, err)
+	}
+
+	pipe := p.redis.Pipeline()
+	pipe.HIncrBy(ctx, persistStatsKey, "flushed", 1)
+	pipe.HSet(ctx, persistStatsKey, "last_flush_at", time.Now().Format(time.RFC3339Nano))
+	pipe.Exec(ctx)
+}
+
+// persist writes the cached document of one inspection and returns the
+// version written. Versions already in the database are not overwritten
+// with older ones.
+func (p *InspectionPersister) persist(ctx context.Context, cacheKey string) (int, error) {
+	data, err := p.redis.HGet(ctx, cacheKey, "data").Bytes()
+	if err == redis.Nil {
+		// Nothing cached any more; the database is all there is
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var inspection models.Inspection
+	if err := json.Unmarshal(data, &inspection); err != nil {
+		return 0, fmt.Errorf("failed to decode cached inspection: %w", err)
+	}
+
+	err = p.db.WithContext(ctx).Model(&models.Inspection{}).
+		Where("id = ? AND version < ?", inspection.ID, inspection.Version).
+		Select(inspectionDocumentColumns).
+		Updates(&inspection).Error
+	if err != nil {
+		return 0, err
+	}
+	return inspection.Version, nil
+}
+
+// retry schedules a failed write again with exponential backoff. Entries
+// are never dropped; a persistently failing one shows up as lag.
+func (p *InspectionPersister) retry(ctx context.Context, id uuid.UUID, cause error) {
+	attempts, err := p.redis.HIncrBy(ctx, persistAttemptsKey, id.String(), 1).Result()
+	if err != nil {
+		attempts = 1
+	}
+
+	backoff := persistDebounce
+	for i := int64(1); i < attempts && backoff < persistMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > persistMaxBackoff {
+		backoff = persistMaxBackoff
+	}
+
+	p.redis.ZAdd(ctx, persistQueueKey, &redis.Z{
+		Score:  float64(time.Now().Add(backoff).UnixMilli()),
+		Member: id.String(),
+	})
+
+	pipe := p.redis.Pipeline()
+	pipe.HIncrBy(ctx, persistStatsKey, "failed", 1)
+	pipe.HSet(ctx, persistStatsKey, "last_error", cause.Error())
+	pipe.Exec(ctx)
+
+	p.logger.Errorf("Failed to persist inspection %s (attempt %d, retrying in %s): %v", id, attempts, backoff, cause)
+}
+
+func (p *InspectionPersister) warnOnLag(ctx context.Context) {
+	oldest, err := p.redis.ZRangeWithScores(ctx, persistQueueKey, 0, 0).Result()
+	if err != nil || len(oldest) == 0 {
+		return
+	}
+	if lag := time.Since(time.UnixMilli(int64(oldest[0].Score))); lag > persistLagWarning {
+		p.logger.Warnf("Inspection persistence is lagging by %s", lag.Round(time.Second))
+	}
+}
@@ -3,37 +3,111 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/notifications"
+	"github.com/macal/inventory/internal/realtime"
+	"github.com/macal/inventory/pkg/imaging"
 	"github.com/macal/inventory/pkg/storage"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrInspectionNotEditable is returned when an inspection can no longer change
+var ErrInspectionNotEditable = errors.New("inspection is no longer editable")
+
+// ErrUpdateConflict is returned when a real-time update loses to a newer
+// concurrent write on an overlapping path
+var ErrUpdateConflict = errors.New("update conflicts with a newer write")
+
+// UpdateConflictError carries the write an update lost to
+type UpdateConflictError struct {
+	Path   string
+	Winner models.PathWrite
+}
+
+func (e *UpdateConflictError) Error() string {
+	return fmt.Sprintf("%s: %s was written by %s at version %d", ErrUpdateConflict, e.Winner.Path, e.Winner.UpdatedBy, e.Winner.Version)
+}
+
+func (e *UpdateConflictError) Unwrap() error {
+	return ErrUpdateConflict
+}
+
+// ErrFormTemplateInactive is returned when a new inspection references a
+// template version that has been superseded or deleted
+var ErrFormTemplateInactive = errors.New("form template version is no longer active")
+
 type InspectionService struct {
-	db      *gorm.DB
-	redis   *redis.Client
-	storage storage.Storage
-	logger  *zap.SugaredLogger
-	pubsub  *redis.PubSub
+	db        *gorm.DB
+	redis     *redis.Client
+	storage   storage.Storage
+	notifier  *notifications.Notifier
+	logger    *zap.SugaredLogger
+	hub       *realtime.Hub
+	vehicles  *VehicleWorkflow
+	media     *MediaLinks
+	persister *InspectionPersister
 }
 
-func NewInspectionService(db *gorm.DB, redis *redis.Client, storage storage.Storage) *InspectionService {
+func NewInspectionService(db *gorm.DB, redis *redis.Client, storage storage.Storage, notifier *notifications.Notifier) *InspectionService {
 	logger, _ := zap.NewProduction()
-	return &InspectionService{
-		db:      db,
-		redis:   redis,
-		storage: storage,
-		logger:  logger.Sugar(),
+	s := &InspectionService{
+		db:        db,
+		redis:     redis,
+		storage:   storage,
+		notifier:  notifier,
+		logger:    logger.Sugar(),
+		vehicles:  NewVehicleWorkflow(db, redis, notifier),
+		media:     NewMediaLinks(db, storage),
+		persister: NewInspectionPersister(db, redis),
 	}
+	s.hub = realtime.NewHub(redis, s)
+	return s
+}
+
+// Hub returns the WebSocket hub editing inspections through this service
+func (s *InspectionService) Hub() *realtime.Hub {
+	return s.hub
+}
+
+// Vehicles returns the workflow moving vehicles between statuses
+func (s *InspectionService) Vehicles() *VehicleWorkflow {
+	return s.vehicles
+}
+
+// Persister returns the write-behind persister flushing edited inspections
+// to the database
+func (s *InspectionService) Persister() *InspectionPersister {
+	return s.persister
+}
+
+// MediaLinks returns the signer for links to photos and documents stored
+// through this service
+func (s *InspectionService) MediaLinks() *MediaLinks {
+	return s.media
 }
 
 // CreateInspection creates a new inspection
 func (s *InspectionService) CreateInspection(ctx context.Context, inspection *models.Inspection) error {
+	// Pin the template version the inspection is filled against
+	if inspection.FormTemplateID != nil {
+		var template models.FormTemplate
+		if err := s.db.WithContext(ctx).First(&template, "id = ?", *inspection.FormTemplateID).Error; err != nil {
+			return fmt.Errorf("failed to load form template: %w", err)
+		}
+		if !template.Active {
+			return ErrFormTemplateInactive
+		}
+		inspection.FormTemplateVersion = template.Version
+	}
+
 	// Save to database
 	if err := s.db.Create(inspection).Error; err != nil {
 		return err
@@ -52,6 +126,11 @@ func (s *InspectionService) CreateInspection(ctx context.Context, inspection *mo
 		Version:      inspection.Version,
 	})
 
+	// Entry inspections created already under way check the vehicle in
+	if inspection.Status == models.InspectionStatusInProgress {
+		s.vehicles.InspectionTransitioned(ctx, inspection, models.InspectionActionStart, inspection.InspectorID)
+	}
+
 	return nil
 }
 
@@ -75,9 +154,26 @@ func (s *InspectionService) GetInspection(ctx context.Context, id uuid.UUID) (*m
 	return inspection, nil
 }
 
-// UpdateInspectionField updates a specific field in real-time
+// UpdateInspectionField updates a specific field in real-time. Updates made
+// from an older version still merge when no concurrent write touched an
+// overlapping path; overlapping writes are resolved last-writer-wins and the
+// losing author is sent an update_conflict event. Updates without a version,
+// such as section completion, apply without conflict detection. Photo lists
+// append.
 func (s *InspectionService) UpdateInspectionField(ctx context.Context, update *models.InspectionUpdate) error {
 	key := fmt.Sprintf("inspection:%s", update.InspectionID)
+	writesKey := key + ":writes"
+
+	// Future client clocks must not win every conflict
+	now := time.Now()
+	if update.Timestamp.IsZero() || update.Timestamp.After(now) {
+		update.Timestamp = now
+	}
+
+	var (
+		decision models.MergeDecision
+		applied  *models.Inspection
+	)
 
 	// Use Redis transaction for atomic updates
 	err := s.redis.Watch(ctx, func(tx *redis.Tx) error {
@@ -87,71 +183,168 @@ func (s *InspectionService) UpdateInspectionField(ctx context.Context, update *m
 			return err
 		}
 
-		// Check version conflict
-		if currentVersion >= update.Version {
-			return fmt.Errorf("version conflict: current %d, update %d", currentVersion, update.Version)
+		writes, err := s.pathWrites(ctx, tx, writesKey)
+		if err != nil {
+			return err
 		}
 
-		// Marshal the update value
-		valueJSON, err := json.Marshal(update.Value)
+		decision = models.ResolveInspectionUpdate(update, writes)
+		if decision.Outcome == models.MergeReject {
+			return &UpdateConflictError{Path: update.Path, Winner: *decision.Winner}
+		}
+
+		// The cached document is authoritative; edits are applied into it
+		inspection, err := s.loadForUpdate(ctx, tx, key, update.InspectionID)
+		if err != nil {
+			return err
+		}
+		if !inspection.CanEdit() {
+			return fmt.Errorf("%w: status is %s", ErrInspectionNotEditable, inspection.Status)
+		}
+		if err := inspection.ApplyUpdate(update); err != nil {
+			return err
+		}
+
+		if currentVersion < inspection.Version {
+			currentVersion = inspection.Version
+		}
+		update.Version = currentVersion + 1
+		inspection.Version = update.Version
+		inspection.UpdatedAt = now
+
+		data, err := json.Marshal(inspection)
+		if err != nil {
+			return err
+		}
+		writeJSON, err := json.Marshal(models.PathWrite{
+			Path:      update.Path,
+			Version:   update.Version,
+			UpdatedBy: update.UpdatedBy,
+			Timestamp: update.Timestamp,
+		})
 		if err != nil {
 			return err
 		}
 
 		// Pipeline for atomic updates
 		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
-			pipe.HSet(ctx, key, update.Path, valueJSON)
-			pipe.HSet(ctx, key, "updated_at", time.Now())
-			pipe.HIncrBy(ctx, key, "version", 1)
+			pipe.HSet(ctx, key, "data", data)
+			pipe.HSet(ctx, key, "version", update.Version)
+			pipe.HSet(ctx, key, "updated_at", now)
 			pipe.Expire(ctx, key, 24*time.Hour)
+			pipe.HSet(ctx, writesKey, update.Path, writeJSON)
+			pipe.Expire(ctx, writesKey, 24*time.Hour)
+			markInspectionDirty(ctx, pipe, update.InspectionID)
 
 			// Publish update to subscribers
-			updateJSON, _ := json.Marshal(update)
-			pipe.Publish(ctx, fmt.Sprintf("inspection:%s:updates", update.InspectionID), updateJSON)
+			realtime.AppendUpdate(ctx, pipe, update)
 
 			return nil
 		})
+		if err != nil {
+			return err
+		}
 
-		return err
-	}, key)
+		applied = inspection
+		return nil
+	}, key, writesKey)
 
+	var conflict *UpdateConflictError
+	if errors.As(err, &conflict) {
+		s.publishConflict(ctx, update.InspectionID, update.Path, update.UpdatedBy, conflict.Winner)
+		return err
+	}
 	if err != nil {
 		return err
 	}
 
-	// Async save to database (debounced)
-	go s.persistToDB(ctx, update.InspectionID)
+	// Overwritten concurrent writes lost to this update
+	if decision.Outcome == models.MergeOverwrite {
+		winner := models.PathWrite{Path: update.Path, Version: update.Version, UpdatedBy: update.UpdatedBy, Timestamp: update.Timestamp}
+		for _, loser := range decision.Conflicts {
+			if loser.UpdatedBy != update.UpdatedBy {
+				s.publishConflict(ctx, update.InspectionID, loser.Path, loser.UpdatedBy, winner)
+			}
+		}
+	}
 
+	s.vehicles.InspectionEdited(ctx, applied, update)
 	return nil
 }
 
-// SubscribeToUpdates subscribes to real-time inspection updates
-func (s *InspectionService) SubscribeToUpdates(ctx context.Context, inspectionID uuid.UUID) (<-chan *models.InspectionUpdate, error) {
-	channel := fmt.Sprintf("inspection:%s:updates", inspectionID)
-	pubsub := s.redis.Subscribe(ctx, channel)
+// loadForUpdate reads the cached inspection document inside tx, falling
+// back to the database when the cache has expired
+func (s *InspectionService) loadForUpdate(ctx context.Context, tx *redis.Tx, key string, inspectionID uuid.UUID) (*models.Inspection, error) {
+	data, err := tx.HGet(ctx, key, "data").Bytes()
+	if err == redis.Nil {
+		var inspection models.Inspection
+		if err := s.db.WithContext(ctx).First(&inspection, "id = ?", inspectionID).Error; err != nil {
+			return nil, err
+		}
+		return &inspection, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	updates := make(chan *models.InspectionUpdate, 100)
+	var inspection models.Inspection
+	if err := json.Unmarshal(data, &inspection); err != nil {
+		return nil, fmt.Errorf("failed to decode cached inspection: %w", err)
+	}
+	return &inspection, nil
+}
 
-	go func() {
-		defer close(updates)
-		defer pubsub.Close()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case msg := <-pubsub.Channel():
-				var update models.InspectionUpdate
-				if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
-					s.logger.Errorf("Failed to unmarshal update: %v", err)
-					continue
-				}
-				updates <- &update
-			}
+// pathWrites loads the last write of every path of an inspection
+func (s *InspectionService) pathWrites(ctx context.Context, tx *redis.Tx, writesKey string) ([]models.PathWrite, error) {
+	raw, err := tx.HGetAll(ctx, writesKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	writes := make([]models.PathWrite, 0, len(raw))
+	for path, data := range raw {
+		var write models.PathWrite
+		if err := json.Unmarshal([]byte(data), &write); err != nil {
+			s.logger.Warnf("Ignoring unreadable write record for %s: %v", path, err)
+			continue
 		}
+		writes = append(writes, write)
+	}
+	return writes, nil
+}
+
+// publishConflict tells loser that its write to path was superseded
+func (s *InspectionService) publishConflict(ctx context.Context, inspectionID uuid.UUID, path string, loser uuid.UUID, winner models.PathWrite) {
+	s.publishUpdate(ctx, &models.InspectionUpdate{
+		InspectionID: inspectionID,
+		Path:         path,
+		UpdatedBy:    loser,
+		Version:      winner.Version,
+		Timestamp:    time.Now(),
+		Type:         "update_conflict",
+		Metadata: map[string]interface{}{
+			"winner":           winner.UpdatedBy,
+			"winner_path":      winner.Path,
+			"winner_timestamp": winner.Timestamp,
+		},
+	})
+}
+
+// SubscribeToUpdates subscribes to real-time inspection updates until ctx
+// ends. With lastEventID the updates missed since are replayed first, or a
+// snapshot is sent when they are no longer kept.
+func (s *InspectionService) SubscribeToUpdates(ctx context.Context, inspectionID uuid.UUID, lastEventID string) (*realtime.Subscription, error) {
+	sub, err := s.hub.Subscribe(ctx, inspectionID, lastEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		sub.Close()
 	}()
 
-	return updates, nil
+	return sub, nil
 }
 
 // CompleteSection marks a section as completed
@@ -168,10 +361,75 @@ func (s *InspectionService) CompleteSection(ctx context.Context, inspectionID uu
 	return s.UpdateInspectionField(ctx, update)
 }
 
+// CompleteInspection marks an inspection as completed on behalf of actorID
+// and notifies the client organizations following its vehicle
+func (s *InspectionService) CompleteInspection(ctx context.Context, inspectionID, actorID uuid.UUID) (*models.Inspection, error) {
+	return s.TransitionInspection(ctx, inspectionID, actorID, models.InspectionActionComplete, "")
+}
+
+// ValidateAgainstTemplate checks the inspection's answers against its
+// FormTemplate. Failures are returned as models.FormValidationErrors.
+func (s *InspectionService) ValidateAgainstTemplate(ctx context.Context, inspection *models.Inspection) error {
+	template, err := s.InspectionTemplate(ctx, inspection)
+	if err != nil || template == nil {
+		return err
+	}
+	config, err := template.ParseConfig()
+	if err != nil {
+		return fmt.Errorf("failed to parse form template: %w", err)
+	}
+
+	if errs := config.ValidateInspection(inspection); errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// InspectionTemplate returns the exact template version an inspection was
+// filled against, even if it has since been superseded or deleted. It
+// returns nil for free-form inspections.
+func (s *InspectionService) InspectionTemplate(ctx context.Context, inspection *models.Inspection) (*models.FormTemplate, error) {
+	if inspection.FormTemplateID == nil {
+		return nil, nil
+	}
+
+	var template models.FormTemplate
+	if err := s.db.WithContext(ctx).Unscoped().First(&template, "id = ?", *inspection.FormTemplateID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load form template: %w", err)
+	}
+	if inspection.FormTemplateVersion != 0 && template.Version != inspection.FormTemplateVersion {
+		s.logger.Warnf("Inspection %s pinned template version %d but %s is version %d",
+			inspection.ID, inspection.FormTemplateVersion, template.ID, template.Version)
+	}
+	return &template, nil
+}
+
 // AddPhotoToInspection adds a photo to an inspection item
 func (s *InspectionService) AddPhotoToInspection(ctx context.Context, inspectionID uuid.UUID, sectionName, itemID string, photoData []byte) (string, error) {
+	update := &models.InspectionUpdate{
+		InspectionID: inspectionID,
+		Timestamp:    time.Now(),
+	}
+	return s.addPhoto(ctx, update, sectionName, itemID, uuid.New().String(), photoData)
+}
+
+// addPhoto uploads a photo under name and appends its URL to the item
+// through update, which carries the author and time
+func (s *InspectionService) addPhoto(ctx context.Context, update *models.InspectionUpdate, sectionName, itemID, name string, photoData []byte) (string, error) {
+	// Named after what the data is, not what the client called it
+	format, err := imaging.Sniff(photoData)
+	if err != nil {
+		return "", err
+	}
+	if !format.Decodable() {
+		return "", fmt.Errorf("%w: %s", imaging.ErrUnsupportedFormat, format)
+	}
+	if _, err := s.GetInspection(ctx, update.InspectionID); err != nil {
+		return "", err
+	}
+
 	// Generate unique filename
-	filename := fmt.Sprintf("inspections/%s/%s/%s_%s.jpg", inspectionID, sectionName, itemID, uuid.New())
+	filename := fmt.Sprintf("inspections/%s/%s/%s_%s.%s", update.InspectionID, sectionName, itemID, name, format.Extension())
 
 	// Upload to storage
 	url, err := s.storage.Upload(ctx, filename, photoData)
@@ -179,24 +437,54 @@ func (s *InspectionService) AddPhotoToInspection(ctx context.Context, inspection
 		return "", err
 	}
 
+	if _, err := s.recordPhoto(ctx, update, sectionName, itemID, filename, url, format.ContentType(), int64(len(photoData))); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// recordPhoto appends a stored photo to the item through update and records
+// it as a VehiclePhoto so the photo pipeline processes it
+func (s *InspectionService) recordPhoto(ctx context.Context, update *models.InspectionUpdate, sectionName, itemID, filename, url, contentType string, size int64) (*models.VehiclePhoto, error) {
+	inspection, err := s.GetInspection(ctx, update.InspectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keyed by filename so a retried upload replaces its record and is
+	// processed again
+	photo := &models.VehiclePhoto{
+		ID:           uuid.NewSHA1(uuid.NameSpaceURL, []byte(filename)),
+		VehicleID:    inspection.VehicleID,
+		InspectionID: &update.InspectionID,
+		SectionID:    sectionName,
+		ItemID:       itemID,
+		Category:     models.PhotoCategoryInspection,
+		URL:          url,
+		ContentType:  contentType,
+		UploadedBy:   update.UpdatedBy,
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(photo).Error; err != nil {
+		return nil, fmt.Errorf("failed to record photo: %w", err)
+	}
+
 	// Update inspection with photo URL
-	update := &models.InspectionUpdate{
-		InspectionID: inspectionID,
-		Path:         fmt.Sprintf("sections.%s.items.%s.photos", sectionName, itemID),
-		Value:        url,
-		Type:         "photo_added",
-		Timestamp:    time.Now(),
-		Metadata: map[string]interface{}{
-			"filename": filename,
-			"size":     len(photoData),
-		},
+	update.Path = fmt.Sprintf("sections.%s.items.%s.photos", sectionName, itemID)
+	update.Value = url
+	update.Type = "photo_added"
+	if update.Metadata == nil {
+		update.Metadata = map[string]interface{}{}
 	}
+	update.Metadata["filename"] = filename
+	update.Metadata["size"] = size
+	update.Metadata["content_type"] = contentType
+	update.Metadata["photo_id"] = photo.ID
 
 	if err := s.UpdateInspectionField(ctx, update); err != nil {
-		return "", err
+		s.db.WithContext(ctx).Delete(photo)
+		return nil, err
 	}
-
-	return url, nil
+	return photo, nil
 }
 
 // GeneratePDF generates a PDF report for the inspection
@@ -212,8 +500,8 @@ func (s *InspectionService) GeneratePDF(ctx context.Context, inspectionID uuid.U
 		return cached, nil
 	}
 
-	// Generate PDF (implement PDF generation logic)
-	pdfData, err := s.generatePDFReport(inspection)
+	// Generate PDF
+	pdfData, err := s.generatePDFReport(ctx, inspection)
 	if err != nil {
 		return nil, err
 	}
@@ -269,28 +557,7 @@ func (s *InspectionService) getCachedInspection(ctx context.Context, id uuid.UUI
 }
 
 func (s *InspectionService) publishUpdate(ctx context.Context, update *models.InspectionUpdate) {
-	updateJSON, _ := json.Marshal(update)
-	s.redis.Publish(ctx, fmt.Sprintf("inspection:%s:updates", update.InspectionID), updateJSON)
-}
-
-func (s *InspectionService) persistToDB(ctx context.Context, inspectionID uuid.UUID) {
-	// Implement debounced database persistence
-	// This would batch updates and save to DB every few seconds
-	time.Sleep(5 * time.Second)
-
-	inspection, err := s.getCachedInspection(ctx, inspectionID)
-	if err != nil {
-		s.logger.Errorf("Failed to get cached inspection for persistence: %v", err)
-		return
-	}
-
-	if err := s.db.Save(inspection).Error; err != nil {
-		s.logger.Errorf("Failed to persist inspection to DB: %v", err)
+	if err := realtime.AppendUpdate(ctx, s.redis, update).Err(); err != nil {
+		s.logger.Errorf("Failed to publish %s for inspection %s: %v", update.Type, update.InspectionID, err)
 	}
 }
-
-func (s *InspectionService) generatePDFReport(inspection *models.Inspection) ([]byte, error) {
-	// TODO: Implement PDF generation using a library like gopdf or wkhtmltopdf
-	// For now, return a placeholder
-	return []byte("PDF Report Placeholder"), nil
-}
\ No newline at end of file
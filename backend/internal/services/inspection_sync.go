@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"gorm.io/gorm"
+)
+
+const (
+	syncResultTTL  = 30 * 24 * time.Hour // tablets may stay offline for weeks
+	syncClaimStale = 5 * time.Minute     // claims older than this were abandoned
+)
+
+// Replaces the claim in field ARGV[1] with ARGV[3] if it still holds ARGV[2]
+var reclaimSyncScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+	return 1
+end
+return 0`)
+
+// syncClaim marks an operation an API instance is applying
+type syncClaim struct {
+	Status    string    `json:"status"`
+	ClaimedAt time.Time `json:"claimed_at"`
+}
+
+// SyncInspection applies a queue of offline operations in order and returns
+// the canonical inspection. Operations are recorded by their client IDs, so
+// a queue sent again after a lost response is not applied twice. Edits made
+// offline go through the same merge as live ones: they carry the version and
+// time they were made, and lose to newer overlapping writes.
+func (s *InspectionService) SyncInspection(ctx context.Context, inspectionID, userID uuid.UUID, ops []models.SyncOperation, files map[string]*multipart.FileHeader) (*models.SyncResult, error) {
+	if _, err := s.GetInspection(ctx, inspectionID); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SyncOperationResult, 0, len(ops))
+	for i := range ops {
+		results = append(results, s.syncOperation(ctx, inspectionID, userID, &ops[i], files))
+	}
+
+	inspection, err := s.GetInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SyncResult{
+		Inspection: inspection,
+		Version:    inspection.Version,
+		Results:    results,
+	}, nil
+}
+
+// syncOperation applies one operation at most once
+func (s *InspectionService) syncOperation(ctx context.Context, inspectionID, userID uuid.UUID, op *models.SyncOperation, files map[string]*multipart.FileHeader) models.SyncOperationResult {
+	if err := op.Validate(); err != nil {
+		return models.SyncOperationResult{ID: op.ID, Status: models.SyncRejected, Error: err.Error()}
+	}
+
+	key := fmt.Sprintf("inspection:%s:sync", inspectionID)
+	claimed, recorded, err := s.claimSyncOperation(ctx, key, op.ID)
+	if err != nil {
+		return models.SyncOperationResult{ID: op.ID, Status: models.SyncFailed, Error: err.Error()}
+	}
+	if !claimed {
+		return recorded
+	}
+
+	result := s.applySyncOperation(ctx, inspectionID, userID, op, files)
+	if result.Status == models.SyncFailed {
+		// Let the next submission try again
+		s.redis.HDel(ctx, key, op.ID)
+		return result
+	}
+
+	data, err := json.Marshal(result)
+	if err == nil {
+		pipe := s.redis.Pipeline()
+		pipe.HSet(ctx, key, op.ID, data)
+		pipe.Expire(ctx, key, syncResultTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			s.logger.Errorf("Failed to record sync operation %s of inspection %s: %v", op.ID, inspectionID, err)
+		}
+	}
+	return result
+}
+
+// claimSyncOperation reserves an operation ID. When it was seen before, the
+// recorded result is returned instead.
+func (s *InspectionService) claimSyncOperation(ctx context.Context, key, id string) (bool, models.SyncOperationResult, error) {
+	claim, err := json.Marshal(syncClaim{Status: models.SyncInProgress, ClaimedAt: time.Now()})
+	if err != nil {
+		return false, models.SyncOperationResult{}, err
+	}
+
+	claimed, err := s.redis.HSetNX(ctx, key, id, claim).Result()
+	if err != nil {
+		return false, models.SyncOperationResult{}, err
+	}
+	if claimed {
+		s.redis.Expire(ctx, key, syncResultTTL)
+		return true, models.SyncOperationResult{}, nil
+	}
+
+	raw, err := s.redis.HGet(ctx, key, id).Result()
+	if err == redis.Nil {
+		// Released by a failed attempt in the meantime
+		return s.claimSyncOperation(ctx, key, id)
+	}
+	if err != nil {
+		return false, models.SyncOperationResult{}, err
+	}
+
+	var existing syncClaim
+	json.Unmarshal([]byte(raw), &existing)
+	if existing.Status == models.SyncInProgress {
+		if time.Since(existing.ClaimedAt) < syncClaimStale {
+			return false, models.SyncOperationResult{ID: id, Status: models.SyncInProgress, Duplicate: true}, nil
+		}
+		// The instance applying it died; take over
+		taken, err := reclaimSyncScript.Run(ctx, s.redis, []string{key}, id, raw, claim).Int()
+		if err != nil {
+			return false, models.SyncOperationResult{}, err
+		}
+		if taken == 1 {
+			return true, models.SyncOperationResult{}, nil
+		}
+		return false, models.SyncOperationResult{ID: id, Status: models.SyncInProgress, Duplicate: true}, nil
+	}
+
+	var recorded models.SyncOperationResult
+	if err := json.Unmarshal([]byte(raw), &recorded); err != nil {
+		return false, models.SyncOperationResult{}, fmt.Errorf("failed to decode recorded sync result: %w", err)
+	}
+	recorded.Duplicate = true
+	return false, recorded, nil
+}
+
+// applySyncOperation performs an operation and classifies the outcome
+func (s *InspectionService) applySyncOperation(ctx context.Context, inspectionID, userID uuid.UUID, op *models.SyncOperation, files map[string]*multipart.FileHeader) models.SyncOperationResult {
+	result := models.SyncOperationResult{ID: op.ID}
+	update := &models.InspectionUpdate{
+		InspectionID: inspectionID,
+		UpdatedBy:    userID,
+		Version:      op.Version,
+		Timestamp:    op.Timestamp,
+		Metadata:     map[string]interface{}{"sync_operation": op.ID},
+	}
+
+	var err error
+	switch op.Type {
+	case models.SyncOpUpdate:
+		update.Path = op.Path
+		update.Value = op.Value
+		update.Type = "field_update"
+		err = s.UpdateInspectionField(ctx, update)
+
+	case models.SyncOpPhoto:
+		header, ok := files[op.File]
+		if !ok {
+			// Not recorded, so the queue can be sent again with the file
+			result.Status = models.SyncFailed
+			result.Error = fmt.Sprintf("file %q was not uploaded", op.File)
+			return result
+		}
+		var data []byte
+		if data, err = readSyncPhoto(header); err != nil {
+			result.Status = models.SyncFailed
+			result.Error = fmt.Sprintf("failed to read file %q: %v", op.File, err)
+			return result
+		}
+		// Named after the operation so a retried upload replaces itself
+		result.URL, err = s.addPhoto(ctx, update, op.Section, op.Item, op.ID, data)
+	}
+
+	var conflict *UpdateConflictError
+	switch {
+	case err == nil:
+		result.Status = models.SyncApplied
+		result.Version = update.Version
+	case errors.As(err, &conflict):
+		result.Status = models.SyncConflict
+		result.Error = err.Error()
+		result.Winner = &conflict.Winner
+	case errors.Is(err, ErrInspectionNotEditable),
+		errors.Is(err, models.ErrInvalidPath),
+		errors.Is(err, imaging.ErrNotImage),
+		errors.Is(err, imaging.ErrUnsupportedFormat),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		result.Status = models.SyncRejected
+		result.Error = err.Error()
+	default:
+		result.Status = models.SyncFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// readSyncPhoto reads an uploaded photo, which the multipart form may have
+// spooled to disk, only when its operation is applied
+func readSyncPhoto(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
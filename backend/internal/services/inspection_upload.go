@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"github.com/macal/inventory/pkg/storage"
+)
+
+var (
+	// ErrDirectUploadUnsupported is returned when the configured storage
+	// cannot issue presigned upload URLs
+	ErrDirectUploadUnsupported = errors.New("storage does not support direct uploads")
+	// ErrUploadNotFound is returned for unknown or expired uploads
+	ErrUploadNotFound = errors.New("upload not found or expired")
+	// ErrUploadIncomplete is returned when confirming before the photo, or
+	// every part of it, reached storage
+	ErrUploadIncomplete = errors.New("upload is not complete")
+	// ErrUploadInProgress is returned while another request confirms the
+	// same upload
+	ErrUploadInProgress = errors.New("upload is already being confirmed")
+	// ErrUploadInvalid is returned when the stored object is not the photo
+	// that was announced; the object is discarded
+	ErrUploadInvalid = errors.New("uploaded photo is invalid")
+)
+
+const (
+	photoUploadExpiry    = time.Hour       // validity of the presigned URLs
+	photoUploadRetention = 2 * time.Hour   // pending and confirmed uploads are kept this long
+	photoUploadLockTTL   = 2 * time.Minute // bounds a crashed confirmation
+	photoSniffBytes      = 512
+)
+
+func photoUploadKey(inspectionID uuid.UUID, uploadID string) string {
+	return fmt.Sprintf("inspection:%s:upload:%s", inspectionID, uploadID)
+}
+
+func (s *InspectionService) directUploader() (storage.DirectUploader, error) {
+	uploader, ok := s.storage.(storage.DirectUploader)
+	if !ok {
+		return nil, ErrDirectUploadUnsupported
+	}
+	return uploader, nil
+}
+
+// CreatePhotoUpload issues presigned URLs to upload a photo for an item
+// straight to storage. Photos above the multipart threshold, or when asked
+// for, are uploaded in parts so a dropped connection only repeats a part.
+func (s *InspectionService) CreatePhotoUpload(ctx context.Context, inspectionID, userID uuid.UUID, req *models.PhotoUploadRequest) (*models.PhotoUploadTicket, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	uploader, err := s.directUploader()
+	if err != nil {
+		return nil, err
+	}
+
+	inspection, err := s.GetInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if !inspection.CanEdit() {
+		return nil, fmt.Errorf("%w: status is %s", ErrInspectionNotEditable, inspection.Status)
+	}
+
+	upload := &models.PhotoUpload{
+		ID:           uuid.New().String(),
+		InspectionID: inspectionID,
+		Section:      req.Section,
+		Item:         req.Item,
+		ContentType:  req.ContentType,
+		Size:         req.Size,
+		UploadedBy:   userID,
+		ExpiresAt:    time.Now().Add(photoUploadExpiry),
+	}
+	upload.Key = fmt.Sprintf("inspections/%s/%s/%s_%s.%s", inspectionID, req.Section, req.Item, upload.ID, req.Extension())
+
+	ticket := &models.PhotoUploadTicket{
+		UploadID:  upload.ID,
+		Method:    http.MethodPut,
+		ExpiresAt: upload.ExpiresAt,
+	}
+
+	if req.Multipart || req.Size > models.MultipartUploadThreshold {
+		upload.MultipartID, err = uploader.CreateMultipartUpload(ctx, upload.Key, req.ContentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start multipart upload: %w", err)
+		}
+		upload.PartCount = int((req.Size + models.UploadPartSize - 1) / models.UploadPartSize)
+
+		ticket.PartSize = models.UploadPartSize
+		for n := 1; n <= upload.PartCount; n++ {
+			url, err := uploader.PresignedPartURL(ctx, upload.Key, upload.MultipartID, n, photoUploadExpiry)
+			if err != nil {
+				uploader.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID)
+				return nil, fmt.Errorf("failed to presign part %d: %w", n, err)
+			}
+			ticket.Parts = append(ticket.Parts, models.UploadPartURL{Number: n, URL: url})
+		}
+	} else {
+		ticket.URL, err = uploader.PresignedPutURL(ctx, upload.Key, photoUploadExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign upload: %w", err)
+		}
+		ticket.Headers = map[string]string{"Content-Type": req.ContentType}
+	}
+
+	if err := s.savePhotoUpload(ctx, upload); err != nil {
+		if upload.MultipartID != "" {
+			uploader.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID)
+		}
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// ConfirmPhotoUpload checks that the photo reached storage as announced and
+// adds it to the item, publishing the photo_added update. Multipart uploads
+// are assembled from parts first. Confirming an upload again returns the
+// recorded photo.
+func (s *InspectionService) ConfirmPhotoUpload(ctx context.Context, inspectionID, userID uuid.UUID, uploadID string, parts []storage.CompletedPart) (*models.PhotoUpload, error) {
+	uploader, err := s.directUploader()
+	if err != nil {
+		return nil, err
+	}
+
+	key := photoUploadKey(inspectionID, uploadID)
+	upload, err := s.loadPhotoUpload(ctx, key)
+	if err != nil || upload.URL != "" {
+		return upload, err
+	}
+
+	lockKey := key + ":lock"
+	locked, err := s.redis.SetNX(ctx, lockKey, userID.String(), photoUploadLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrUploadInProgress
+	}
+	defer s.redis.Del(context.Background(), lockKey)
+
+	// Reread under the lock in case a concurrent confirmation just finished
+	upload, err = s.loadPhotoUpload(ctx, key)
+	if err != nil || upload.URL != "" {
+		return upload, err
+	}
+
+	if upload.MultipartID != "" {
+		if len(parts) != upload.PartCount {
+			return nil, fmt.Errorf("%w: expected %d parts, got %d", ErrUploadIncomplete, upload.PartCount, len(parts))
+		}
+		if err := uploader.CompleteMultipartUpload(ctx, upload.Key, upload.MultipartID, parts); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUploadIncomplete, err)
+		}
+	}
+
+	info, err := uploader.Stat(ctx, upload.Key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, fmt.Errorf("%w: photo was not uploaded", ErrUploadIncomplete)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Size != upload.Size {
+		s.discardPhotoUpload(ctx, uploader, key, upload)
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrUploadInvalid, upload.Size, info.Size)
+	}
+
+	head, err := uploader.DownloadRange(ctx, upload.Key, 0, photoSniffBytes)
+	if err != nil {
+		return nil, err
+	}
+	format, err := imaging.Sniff(head)
+	if err != nil {
+		s.discardPhotoUpload(ctx, uploader, key, upload)
+		return nil, fmt.Errorf("%w: %v", ErrUploadInvalid, err)
+	}
+	// The announced type is not trusted, and the pipeline would fail on the rest
+	if !format.Decodable() {
+		s.discardPhotoUpload(ctx, uploader, key, upload)
+		return nil, fmt.Errorf("%w: %v: %s", ErrUploadInvalid, imaging.ErrUnsupportedFormat, format)
+	}
+
+	update := &models.InspectionUpdate{
+		InspectionID: inspectionID,
+		UpdatedBy:    upload.UploadedBy,
+		Timestamp:    time.Now(),
+		Metadata:     map[string]interface{}{"upload_id": upload.ID},
+	}
+	url := uploader.ObjectURL(upload.Key)
+	photo, err := s.recordPhoto(ctx, update, upload.Section, upload.Item, upload.Key, url, format.ContentType(), info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	upload.URL = url
+	upload.PhotoID = photo.ID
+	upload.Version = update.Version
+	upload.ContentType = format.ContentType()
+	if err := s.savePhotoUpload(ctx, upload); err != nil {
+		// The photo is added; a repeated confirmation would add it twice
+		s.logger.Errorf("Failed to record confirmation of upload %s: %v", upload.ID, err)
+	}
+	return upload, nil
+}
+
+// AbortPhotoUpload discards an unconfirmed upload and whatever was uploaded
+func (s *InspectionService) AbortPhotoUpload(ctx context.Context, inspectionID uuid.UUID, uploadID string) error {
+	uploader, err := s.directUploader()
+	if err != nil {
+		return err
+	}
+
+	key := photoUploadKey(inspectionID, uploadID)
+	upload, err := s.loadPhotoUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+	if upload.URL != "" {
+		return fmt.Errorf("%w: upload was already confirmed", ErrUploadInvalid)
+	}
+	s.discardPhotoUpload(ctx, uploader, key, upload)
+	return nil
+}
+
+func (s *InspectionService) loadPhotoUpload(ctx context.Context, key string) (*models.PhotoUpload, error) {
+	data, err := s.redis.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var upload models.PhotoUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to decode upload: %w", err)
+	}
+	return &upload, nil
+}
+
+func (s *InspectionService) savePhotoUpload(ctx context.Context, upload *models.PhotoUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, photoUploadKey(upload.InspectionID, upload.ID), data, photoUploadRetention).Err()
+}
+
+// discardPhotoUpload removes the upload and anything stored for it
+func (s *InspectionService) discardPhotoUpload(ctx context.Context, uploader storage.DirectUploader, key string, upload *models.PhotoUpload) {
+	if upload.MultipartID != "" {
+		if err := uploader.AbortMultipartUpload(ctx, upload.Key, upload.MultipartID); err != nil {
+			s.logger.Warnf("Failed to abort multipart upload %s: %v", upload.ID, err)
+		}
+	}
+	if err := s.storage.Delete(ctx, upload.Key); err != nil {
+		s.logger.Warnf("Failed to delete upload %s: %v", upload.ID, err)
+	}
+	s.redis.Del(ctx, key)
+}
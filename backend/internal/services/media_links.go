@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Lifetimes of the links handed out in API responses. They are signed per
+// request, so they only need to outlast the page loading its images.
+const (
+	StaffLinkExpiry  = time.Hour
+	ClientLinkExpiry = 15 * time.Minute
+)
+
+// ErrMediaNotFound is returned for photos and documents with no stored object
+var ErrMediaNotFound = errors.New("media not found")
+
+// MediaLinks replaces stored object URLs with time limited signed links, so
+// a leaked response does not become a permanent link into the bucket
+type MediaLinks struct {
+	db      *gorm.DB
+	storage storage.Storage
+	logger  *zap.SugaredLogger
+}
+
+func NewMediaLinks(db *gorm.DB, storage storage.Storage) *MediaLinks {
+	logger, _ := zap.NewProduction()
+	return &MediaLinks{
+		db:      db,
+		storage: storage,
+		logger:  logger.Sugar(),
+	}
+}
+
+// Sign returns a link to the object behind url valid for expiry. URLs
+// outside storage, such as signature data URLs, are returned unchanged; an
+// object that cannot be signed is left out rather than linked permanently.
+func (l *MediaLinks) Sign(ctx context.Context, url string, expiry time.Duration) string {
+	if url == "" {
+		return ""
+	}
+	key, ok := l.storage.KeyFromURL(url)
+	if !ok {
+		return url
+	}
+	link, err := l.storage.PresignedURL(ctx, key, expiry)
+	if err != nil {
+		l.logger.Errorf("Failed to sign %s: %v", key, err)
+		return ""
+	}
+	return link
+}
+
+// Signer returns Sign bound to a request
+func (l *MediaLinks) Signer(ctx context.Context, expiry time.Duration) models.URLSigner {
+	return func(url string) string {
+		return l.Sign(ctx, url, expiry)
+	}
+}
+
+// SignInspection signs the PDF, signature and photo links of an inspection
+// in place. For a client, item photos are swapped for their processed
+// rendition, as originals may carry GPS, and photos and the PDF that embeds
+// them are removed without CanViewPhotos.
+func (l *MediaLinks) SignInspection(ctx context.Context, inspection *models.Inspection, client *models.ClientOrganization) error {
+	if client == nil {
+		sign := l.Signer(ctx, StaffLinkExpiry)
+		inspection.PDFUrl = sign(inspection.PDFUrl)
+		inspection.Signature = sign(inspection.Signature)
+		signPhotos(map[string]interface{}(inspection.Sections), sign)
+		return nil
+	}
+
+	sign := l.Signer(ctx, client.LinkExpiry(ClientLinkExpiry))
+	inspection.Signature = sign(inspection.Signature)
+	if !client.Permissions.CanViewPhotos {
+		inspection.PDFUrl = ""
+		signPhotos(map[string]interface{}(inspection.Sections), func(string) string { return "" })
+		return nil
+	}
+	inspection.PDFUrl = sign(inspection.PDFUrl)
+
+	var photos []models.VehiclePhoto
+	if err := l.db.WithContext(ctx).Where("inspection_id = ?", inspection.ID).Find(&photos).Error; err != nil {
+		return fmt.Errorf("failed to load photos: %w", err)
+	}
+	renditions := make(map[string]string, len(photos))
+	for i := range photos {
+		if photos[i].ClientVisible() {
+			renditions[photos[i].URL] = photos[i].Display
+		}
+	}
+	signPhotos(map[string]interface{}(inspection.Sections), func(url string) string {
+		display, ok := renditions[url]
+		if !ok {
+			return ""
+		}
+		return sign(display)
+	})
+	return nil
+}
+
+// SignEvents signs the photo links of timeline events in place
+func (l *MediaLinks) SignEvents(ctx context.Context, events []models.TimelineEvent, expiry time.Duration) {
+	sign := l.Signer(ctx, expiry)
+	for i := range events {
+		if events[i].Type != models.TimelinePhotoUploaded {
+			continue
+		}
+		for _, field := range []string{"url", "thumbnail"} {
+			if url, ok := events[i].Data[field].(string); ok {
+				events[i].Data[field] = sign(url)
+			}
+		}
+	}
+}
+
+// Open streams the object behind url
+func (l *MediaLinks) Open(ctx context.Context, url string) (io.ReadCloser, storage.ObjectInfo, error) {
+	key, ok := l.storage.KeyFromURL(url)
+	if url == "" || !ok {
+		return nil, storage.ObjectInfo{}, ErrMediaNotFound
+	}
+	reader, info, err := l.storage.Open(ctx, key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, storage.ObjectInfo{}, fmt.Errorf("%w: %s", ErrMediaNotFound, key)
+	}
+	return reader, info, err
+}
+
+// signPhotos replaces every "photos" list in a section document with the
+// links sign returns, dropping empty ones
+func signPhotos(value interface{}, sign models.URLSigner) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if urls, ok := child.([]interface{}); ok && key == "photos" {
+				signed := make([]interface{}, 0, len(urls))
+				for _, url := range urls {
+					if s, ok := url.(string); ok {
+						if link := sign(s); link != "" {
+							signed = append(signed, link)
+						}
+					}
+				}
+				v[key] = signed
+				continue
+			}
+			signPhotos(child, sign)
+		}
+	case []interface{}:
+		for _, child := range v {
+			signPhotos(child, sign)
+		}
+	}
+}
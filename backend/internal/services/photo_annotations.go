@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"github.com/macal/inventory/pkg/storage"
+)
+
+// ErrAnnotationNotFound is returned for annotations that are not on the photo
+var ErrAnnotationNotFound = errors.New("annotation not found")
+
+// Annotated copies are rendered at most at the display rendition's size
+const (
+	AnnotatedPhotoMaxSide  = 2048
+	annotatedPhotoQuality  = 85
+	annotationFillAlpha    = 60
+	annotationLabelPadding = 0.01 // of the shorter side, between a shape and its label
+)
+
+// PhotoAnnotations returns a photo taken during the inspection with the
+// annotations drawn on it, oldest first
+func (s *InspectionService) PhotoAnnotations(ctx context.Context, inspectionID, photoID uuid.UUID) (*models.VehiclePhoto, []models.InspectionAnnotation, error) {
+	photo, err := s.inspectionPhoto(ctx, inspectionID, photoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	inspection, err := s.GetInspection(ctx, inspectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return photo, inspection.AnnotationsByPhoto()[photo.ID], nil
+}
+
+// CreateAnnotation draws a new annotation on a photo. It is stored with the
+// photo's item and broadcast as an annotation_added update.
+func (s *InspectionService) CreateAnnotation(ctx context.Context, inspectionID, photoID, userID uuid.UUID, input *models.AnnotationInput) (*models.InspectionAnnotation, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	photo, err := s.inspectionPhoto(ctx, inspectionID, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	annotation := &models.InspectionAnnotation{
+		ID:        uuid.New().String(),
+		PhotoID:   photo.ID,
+		CreatedAt: time.Now(),
+		CreatedBy: userID,
+	}
+	input.ApplyTo(annotation)
+
+	update := annotationUpdate(photo, annotation.ID, "annotation_added", userID, input.Version)
+	update.Value = annotation
+	if err := s.UpdateInspectionField(ctx, update); err != nil {
+		return nil, err
+	}
+	return annotation, nil
+}
+
+// UpdateAnnotation replaces the shape, text and colour of an annotation.
+// Concurrent edits of the same annotation are resolved last-writer-wins.
+func (s *InspectionService) UpdateAnnotation(ctx context.Context, inspectionID, photoID uuid.UUID, annotationID string, userID uuid.UUID, input *models.AnnotationInput) (*models.InspectionAnnotation, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+	photo, annotation, err := s.photoAnnotation(ctx, inspectionID, photoID, annotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	input.ApplyTo(annotation)
+	annotation.Touch(userID, time.Now())
+
+	update := annotationUpdate(photo, annotation.ID, "annotation_updated", userID, input.Version)
+	update.Value = annotation
+	if err := s.UpdateInspectionField(ctx, update); err != nil {
+		return nil, err
+	}
+	return annotation, nil
+}
+
+// DeleteAnnotation removes an annotation from its photo
+func (s *InspectionService) DeleteAnnotation(ctx context.Context, inspectionID, photoID uuid.UUID, annotationID string, userID uuid.UUID, version int) error {
+	photo, annotation, err := s.photoAnnotation(ctx, inspectionID, photoID, annotationID)
+	if err != nil {
+		return err
+	}
+
+	// A null value removes the annotation from the item's list
+	update := annotationUpdate(photo, annotation.ID, "annotation_deleted", userID, version)
+	return s.UpdateInspectionField(ctx, update)
+}
+
+// AnnotatedPhoto renders a copy of the photo with its annotations burned in
+func (s *InspectionService) AnnotatedPhoto(ctx context.Context, inspectionID, photoID uuid.UUID, maxSide int) ([]byte, error) {
+	photo, annotations, err := s.PhotoAnnotations(ctx, inspectionID, photoID)
+	if err != nil {
+		return nil, err
+	}
+	return RenderAnnotatedPhoto(ctx, s.storage, photo, annotations, maxSide)
+}
+
+// inspectionPhoto loads a photo taken for an item of the inspection
+func (s *InspectionService) inspectionPhoto(ctx context.Context, inspectionID, photoID uuid.UUID) (*models.VehiclePhoto, error) {
+	var photo models.VehiclePhoto
+	if err := s.db.WithContext(ctx).First(&photo, "id = ? AND inspection_id = ?", photoID, inspectionID).Error; err != nil {
+		return nil, err
+	}
+	if photo.SectionID == "" || photo.ItemID == "" {
+		return nil, fmt.Errorf("%w: photo is not attached to an inspection item", models.ErrInvalidAnnotation)
+	}
+	return &photo, nil
+}
+
+func (s *InspectionService) photoAnnotation(ctx context.Context, inspectionID, photoID uuid.UUID, annotationID string) (*models.VehiclePhoto, *models.InspectionAnnotation, error) {
+	photo, annotations, err := s.PhotoAnnotations(ctx, inspectionID, photoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range annotations {
+		if annotations[i].ID == annotationID {
+			return photo, &annotations[i], nil
+		}
+	}
+	return nil, nil, ErrAnnotationNotFound
+}
+
+// annotationUpdate addresses an annotation inside its photo's item, e.g.
+// sections.exterior.items.scratches.annotations.<id>
+func annotationUpdate(photo *models.VehiclePhoto, annotationID, updateType string, userID uuid.UUID, version int) *models.InspectionUpdate {
+	return &models.InspectionUpdate{
+		InspectionID: *photo.InspectionID,
+		Path:         fmt.Sprintf("sections.%s.items.%s.annotations.%s", photo.SectionID, photo.ItemID, annotationID),
+		UpdatedBy:    userID,
+		Version:      version,
+		Timestamp:    time.Now(),
+		Type:         updateType,
+		Metadata: map[string]interface{}{
+			"photo_id":      photo.ID,
+			"annotation_id": annotationID,
+		},
+	}
+}
+
+// RenderAnnotatedPhoto burns annotations onto a copy of the photo and
+// returns it as a JPEG no larger than maxSide. The processed display
+// rendition is used when available, as it is upright and without GPS;
+// otherwise the original is rotated by its EXIF orientation.
+func RenderAnnotatedPhoto(ctx context.Context, store storage.Storage, photo *models.VehiclePhoto, annotations []models.InspectionAnnotation, maxSide int) ([]byte, error) {
+	source := photo.Display
+	if source == "" {
+		source = photo.URL
+	}
+	key, ok := store.KeyFromURL(source)
+	if !ok {
+		return nil, errPhotoNotInStorage
+	}
+	data, err := store.Download(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download photo: %w", err)
+	}
+
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if maxSide <= 0 || maxSide > AnnotatedPhotoMaxSide {
+		maxSide = AnnotatedPhotoMaxSide
+	}
+	img = imaging.Fit(img, maxSide)
+	if photo.Display == "" && format == imaging.FormatJPEG {
+		if exif := imaging.ReadEXIF(data); exif != nil {
+			img = imaging.Orient(img, exif.Orientation)
+		}
+	}
+
+	canvas := imaging.NewCanvas(img)
+	bounds := img.Bounds()
+	for i := range annotations {
+		drawAnnotation(canvas, &annotations[i], float64(bounds.Dx()), float64(bounds.Dy()))
+	}
+	return imaging.EncodeJPEG(canvas.Image(), annotatedPhotoQuality)
+}
+
+// drawAnnotation draws one annotation on a w x h canvas, with strokes and
+// labels sized to the photo so they read the same at any resolution
+func drawAnnotation(canvas *imaging.Canvas, a *models.InspectionAnnotation, w, h float64) {
+	short := math.Min(w, h)
+	stroke := math.Max(2, short/150)
+	labelSize := math.Max(14, short/28)
+	gap := short * annotationLabelPadding
+
+	r, g, b := a.RGB()
+	ink := color.NRGBA{R: r, G: g, B: b, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// Labels go next to the shape, each type anchoring them where they do
+	// not cover what is being pointed at
+	var labelX, labelY float64
+	switch a.Type {
+	case models.AnnotationArrow:
+		x1, y1 := a.Coordinate("x1")*w, a.Coordinate("y1")*h
+		x2, y2 := a.Coordinate("x2")*w, a.Coordinate("y2")*h
+		canvas.Arrow(x1, y1, x2, y2, stroke, math.Max(stroke*4, short/25), ink)
+		labelX, labelY = x1+gap, y1+gap
+
+	case models.AnnotationCircle:
+		x, y := a.Coordinate("x")*w, a.Coordinate("y")*h
+		radius := a.Coordinate("radius") * short
+		canvas.Circle(x, y, radius, stroke, ink)
+		labelX, labelY = x-radius, y+radius+gap
+
+	case models.AnnotationText:
+		labelX, labelY = a.Coordinate("x")*w, a.Coordinate("y")*h
+
+	case models.AnnotationDamage:
+		x, y := a.Coordinate("x")*w, a.Coordinate("y")*h
+		width, height := a.Coordinate("width")*w, a.Coordinate("height")*h
+		canvas.FillRect(x, y, width, height, color.NRGBA{R: r, G: g, B: b, A: annotationFillAlpha})
+		canvas.Rect(x, y, width, height, stroke, ink)
+		labelX, labelY = x, y+height+gap
+	}
+
+	if a.Text != "" {
+		canvas.Label(labelX, labelY, a.Text, labelSize, white, ink)
+	}
+}
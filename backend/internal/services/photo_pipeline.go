@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"github.com/macal/inventory/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultPhotoWorkers bounds how many photos are processed at once
+	DefaultPhotoWorkers = 4
+
+	photoPollInterval = 2 * time.Second
+	photoClaimLease   = 5 * time.Minute // a crashed worker's claims become due again
+	photoMaxAttempts  = 5
+	photoBaseBackoff  = 30 * time.Second
+	photoMaxBackoff   = time.Hour
+	photoMaxErrorLen  = 500
+)
+
+// errPhotoNotInStorage is returned for photos whose URL does not point into
+// the object store, so there is nothing to process
+var errPhotoNotInStorage = errors.New("photo is not in storage")
+
+// photoRendition is a resized copy generated for every photo
+type photoRendition struct {
+	name    string
+	maxSide int
+	quality int
+}
+
+// photoRenditions are generated in order, each from the previous one
+var photoRenditions = []photoRendition{
+	{name: "display", maxSide: 2048, quality: 85},
+	{name: "medium", maxSide: 1024, quality: 82},
+	{name: "thumbnail", maxSide: 320, quality: 75},
+}
+
+// ProcessedPhoto describes the renditions stored for an original
+type ProcessedPhoto struct {
+	Format imaging.Format
+	Width  int // of the original, upright
+	Height int
+	EXIF   *imaging.EXIF
+	Keys   map[string]string // rendition name to object key
+	URLs   map[string]string // rendition name to URL
+}
+
+// ProcessPhoto reads the original stored under key, extracts its EXIF and
+// stores the renditions next to it as <name>_display.jpg, _medium.jpg and
+// _thumbnail.jpg. Renditions are re-encoded upright without any metadata, so
+// they never carry GPS.
+func ProcessPhoto(ctx context.Context, store storage.Storage, key string) (*ProcessedPhoto, error) {
+	data, err := store.Download(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download original: %w", err)
+	}
+
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &ProcessedPhoto{
+		Format: format,
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Keys:   make(map[string]string, len(photoRenditions)),
+		URLs:   make(map[string]string, len(photoRenditions)),
+	}
+	orientation := 0
+	if format == imaging.FormatJPEG {
+		processed.EXIF = imaging.ReadEXIF(data)
+		if processed.EXIF != nil {
+			orientation = processed.EXIF.Orientation
+		}
+	}
+	if orientation >= 5 {
+		processed.Width, processed.Height = processed.Height, processed.Width
+	}
+
+	base := strings.TrimSuffix(key, path.Ext(key))
+	current := img
+	for i, rendition := range photoRenditions {
+		current = imaging.Fit(current, rendition.maxSide)
+		if i == 0 {
+			// Rotating after the first downscale touches fewer pixels
+			current = imaging.Orient(current, orientation)
+		}
+
+		encoded, err := imaging.EncodeJPEG(current, rendition.quality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s rendition: %w", rendition.name, err)
+		}
+		renditionKey := fmt.Sprintf("%s_%s.jpg", base, rendition.name)
+		url, err := store.Upload(ctx, renditionKey, encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload %s rendition: %w", rendition.name, err)
+		}
+		processed.Keys[rendition.name] = renditionKey
+		processed.URLs[rendition.name] = url
+	}
+	return processed, nil
+}
+
+// PhotoPipeline processes uploaded VehiclePhotos in the background. Pending
+// photos are claimed from the database with SKIP LOCKED, so every replica
+// can run a pipeline, and handed to a fixed pool of workers.
+type PhotoPipeline struct {
+	db      *gorm.DB
+	storage storage.Storage
+	workers int
+	logger  *zap.SugaredLogger
+}
+
+func NewPhotoPipeline(db *gorm.DB, storage storage.Storage, workers int) *PhotoPipeline {
+	if workers <= 0 {
+		workers = DefaultPhotoWorkers
+	}
+	logger, _ := zap.NewProduction()
+	return &PhotoPipeline{
+		db:      db,
+		storage: storage,
+		workers: workers,
+		logger:  logger.Sugar(),
+	}
+}
+
+// Run processes pending photos until ctx is cancelled
+func (p *PhotoPipeline) Run(ctx context.Context) {
+	jobs := make(chan *models.VehiclePhoto)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for photo := range jobs {
+				p.Process(ctx, photo)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(photoPollInterval)
+	defer ticker.Stop()
+
+	batch := p.workers * 2
+	for {
+		claimed := p.claim(ctx, batch)
+		for _, photo := range claimed {
+			select {
+			case jobs <- photo:
+			case <-ctx.Done():
+				return
+			}
+		}
+		// Keep draining while full batches come back
+		if len(claimed) == batch && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim leases up to limit due photos to this pipeline
+func (p *PhotoPipeline) claim(ctx context.Context, limit int) []*models.VehiclePhoto {
+	var due []*models.VehiclePhoto
+	now := time.Now()
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processing = ? AND (next_processing_at IS NULL OR next_processing_at <= ?)", models.PhotoProcessingPending, now).
+			Order("uploaded_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]interface{}, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+		}
+		return tx.Model(&models.VehiclePhoto{}).Where("id IN ?", ids).
+			Update("next_processing_at", now.Add(photoClaimLease)).Error
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Errorf("Failed to claim photos: %v", err)
+		}
+		return nil
+	}
+	return due
+}
+
+// Process generates the renditions of one photo and records the outcome.
+// Photos that are not decodable images fail at once; other errors are
+// retried with backoff.
+func (p *PhotoPipeline) Process(ctx context.Context, photo *models.VehiclePhoto) error {
+	key, ok := p.storage.KeyFromURL(photo.URL)
+	if !ok {
+		return p.recordFailure(ctx, photo, errPhotoNotInStorage)
+	}
+
+	processed, err := ProcessPhoto(ctx, p.storage, key)
+	if err != nil {
+		return p.recordFailure(ctx, photo, err)
+	}
+
+	metadata := models.JSONB{}
+	for k, v := range photo.Metadata {
+		metadata[k] = v
+	}
+	metadata["original_key"] = key
+	metadata["renditions"] = processed.Keys
+	if processed.EXIF != nil {
+		metadata["exif"] = processed.EXIF
+	} else {
+		delete(metadata, "exif")
+	}
+
+	now := time.Now()
+	err = p.db.WithContext(ctx).Model(photo).Updates(map[string]interface{}{
+		"display":            processed.URLs["display"],
+		"medium":             processed.URLs["medium"],
+		"thumbnail":          processed.URLs["thumbnail"],
+		"content_type":       processed.Format.ContentType(),
+		"width":              processed.Width,
+		"height":             processed.Height,
+		"metadata":           metadata,
+		"processing":         models.PhotoProcessingReady,
+		"processing_error":   "",
+		"next_processing_at": nil,
+		"processed_at":       now,
+	}).Error
+	if err != nil {
+		p.logger.Errorf("Failed to record processed photo %s: %v", photo.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (p *PhotoPipeline) recordFailure(ctx context.Context, photo *models.VehiclePhoto, cause error) error {
+	attempts := photo.ProcessingAttempts + 1
+	message := cause.Error()
+	if len(message) > photoMaxErrorLen {
+		message = message[:photoMaxErrorLen]
+	}
+	updates := map[string]interface{}{
+		"processing_attempts": attempts,
+		"processing_error":    message,
+	}
+
+	if permanentPhotoError(cause) || attempts >= photoMaxAttempts {
+		updates["processing"] = models.PhotoProcessingFailed
+		updates["next_processing_at"] = nil
+		p.logger.Warnf("Photo %s could not be processed: %v", photo.ID, cause)
+	} else {
+		updates["next_processing_at"] = time.Now().Add(photoBackoff(attempts))
+		p.logger.Errorf("Failed to process photo %s (attempt %d): %v", photo.ID, attempts, cause)
+	}
+
+	if err := p.db.WithContext(ctx).Model(photo).Updates(updates).Error; err != nil {
+		p.logger.Errorf("Failed to record failure of photo %s: %v", photo.ID, err)
+	}
+	return cause
+}
+
+// permanentPhotoError reports whether retrying cannot help
+func permanentPhotoError(err error) bool {
+	return errors.Is(err, imaging.ErrNotImage) ||
+		errors.Is(err, imaging.ErrUnsupportedFormat) ||
+		errors.Is(err, imaging.ErrCorrupt) ||
+		errors.Is(err, imaging.ErrTooLarge) ||
+		errors.Is(err, errPhotoNotInStorage)
+}
+
+func photoBackoff(attempts int) time.Duration {
+	delay := photoBaseBackoff << (attempts - 1)
+	if delay <= 0 || delay > photoMaxBackoff {
+		return photoMaxBackoff
+	}
+	return delay
+}
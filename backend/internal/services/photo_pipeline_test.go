@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/pkg/imaging"
+	"github.com/macal/inventory/pkg/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The test photo is 2400x1200 as stored, red in its top-left quadrant and
+// blue elsewhere, tagged orientation 6 (rotate 90 clockwise) with a GPS
+// position of 33°26'24"S 70°39'00"W
+const (
+	testPhotoWidth  = 2400
+	testPhotoHeight = 1200
+)
+
+var (
+	testPhotoRed  = color.NRGBA{R: 220, G: 20, B: 20, A: 255}
+	testPhotoBlue = color.NRGBA{R: 20, G: 20, B: 220, A: 255}
+)
+
+func TestProcessPhoto(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	store.Upload(context.Background(), "vehicles/v1/photo.jpg", testPhotoJPEG(t))
+
+	processed, err := ProcessPhoto(context.Background(), store, "vehicles/v1/photo.jpg")
+	if err != nil {
+		t.Fatalf("ProcessPhoto: %v", err)
+	}
+
+	if processed.Format != imaging.FormatJPEG {
+		t.Errorf("format = %q, want %q", processed.Format, imaging.FormatJPEG)
+	}
+	if processed.Width != testPhotoHeight || processed.Height != testPhotoWidth {
+		t.Errorf("size = %dx%d, want the upright %dx%d", processed.Width, processed.Height, testPhotoHeight, testPhotoWidth)
+	}
+	assertTestPhotoGPS(t, processed.EXIF)
+
+	sizes := map[string][2]int{
+		"display":   {1024, 2048},
+		"medium":    {512, 1024},
+		"thumbnail": {160, 320},
+	}
+	for name, size := range sizes {
+		key := "vehicles/v1/photo_" + name + ".jpg"
+		if processed.Keys[name] != key {
+			t.Errorf("%s key = %q, want %q", name, processed.Keys[name], key)
+		}
+		if processed.URLs[name] != store.ObjectURL(key) {
+			t.Errorf("%s url = %q, want %q", name, processed.URLs[name], store.ObjectURL(key))
+		}
+
+		data, err := store.Download(context.Background(), key)
+		if err != nil {
+			t.Fatalf("%s rendition was not stored: %v", name, err)
+		}
+		if exif := imaging.ReadEXIF(data); exif != nil {
+			t.Errorf("%s rendition carries EXIF: %+v", name, exif)
+		}
+		if bytes.Contains(data, []byte("Exif\x00\x00")) {
+			t.Errorf("%s rendition has an Exif segment", name)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode %s rendition: %v", name, err)
+		}
+		w, h := img.Bounds().Dx(), img.Bounds().Dy()
+		if w != size[0] || h != size[1] {
+			t.Errorf("%s rendition is %dx%d, want %dx%d", name, w, h, size[0], size[1])
+			continue
+		}
+
+		// Rotated clockwise, the red quadrant moves to the top right
+		if !nearColor(img.At(w*7/8, h/8), testPhotoRed) {
+			t.Errorf("%s rendition top right = %v, want red", name, img.At(w*7/8, h/8))
+		}
+		if !nearColor(img.At(w/8, h/8), testPhotoBlue) {
+			t.Errorf("%s rendition top left = %v, want blue", name, img.At(w/8, h/8))
+		}
+		if !nearColor(img.At(w*7/8, h*7/8), testPhotoBlue) {
+			t.Errorf("%s rendition bottom right = %v, want blue", name, img.At(w*7/8, h*7/8))
+		}
+	}
+}
+
+func TestPhotoPipelineProcess(t *testing.T) {
+	db := testPhotoDB(t)
+	store := storage.NewMemoryStorage()
+	url, _ := store.Upload(context.Background(), "vehicles/v1/photo.jpg", testPhotoJPEG(t))
+
+	photo := &models.VehiclePhoto{
+		VehicleID: uuid.New(),
+		URL:       url,
+		Metadata:  models.JSONB{"exif": map[string]interface{}{"stale": true}, "source": "camera"},
+	}
+	if err := db.Create(photo).Error; err != nil {
+		t.Fatalf("create photo: %v", err)
+	}
+
+	pipeline := NewPhotoPipeline(db, store, 1)
+	if err := pipeline.Process(context.Background(), photo); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	var stored models.VehiclePhoto
+	if err := db.First(&stored, "id = ?", photo.ID).Error; err != nil {
+		t.Fatalf("reload photo: %v", err)
+	}
+	if stored.Processing != models.PhotoProcessingReady || !stored.ClientVisible() {
+		t.Errorf("processing = %q, want ready and client visible", stored.Processing)
+	}
+	if stored.ProcessedAt == nil || stored.NextProcessingAt != nil {
+		t.Errorf("processed at %v, next processing at %v; want processed and nothing due", stored.ProcessedAt, stored.NextProcessingAt)
+	}
+	for name, got := range map[string]string{"display": stored.Display, "medium": stored.Medium, "thumbnail": stored.Thumbnail} {
+		if want := store.ObjectURL("vehicles/v1/photo_" + name + ".jpg"); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if stored.ContentType != "image/jpeg" || stored.Width != testPhotoHeight || stored.Height != testPhotoWidth {
+		t.Errorf("content type %q size %dx%d, want image/jpeg %dx%d", stored.ContentType, stored.Width, stored.Height, testPhotoHeight, testPhotoWidth)
+	}
+
+	if stored.Metadata["source"] != "camera" {
+		t.Errorf("metadata source = %v, want the uploaded value kept", stored.Metadata["source"])
+	}
+	if stored.Metadata["original_key"] != "vehicles/v1/photo.jpg" {
+		t.Errorf("metadata original_key = %v", stored.Metadata["original_key"])
+	}
+	exif, ok := stored.Metadata["exif"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("metadata exif = %#v, want an object", stored.Metadata["exif"])
+	}
+	if exif["orientation"] != float64(6) {
+		t.Errorf("exif orientation = %v, want 6", exif["orientation"])
+	}
+	gps, ok := exif["gps"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("exif gps = %#v, want an object", exif["gps"])
+	}
+	if lat, _ := gps["latitude"].(float64); math.Abs(lat+33.44) > 1e-9 {
+		t.Errorf("gps latitude = %v, want -33.44", gps["latitude"])
+	}
+	if lon, _ := gps["longitude"].(float64); math.Abs(lon+70.65) > 1e-9 {
+		t.Errorf("gps longitude = %v, want -70.65", gps["longitude"])
+	}
+}
+
+func TestPhotoPipelineProcessRejectsNonImage(t *testing.T) {
+	db := testPhotoDB(t)
+	store := storage.NewMemoryStorage()
+	url, _ := store.Upload(context.Background(), "vehicles/v1/notes.jpg", []byte("not a photo at all"))
+
+	photo := &models.VehiclePhoto{VehicleID: uuid.New(), URL: url}
+	if err := db.Create(photo).Error; err != nil {
+		t.Fatalf("create photo: %v", err)
+	}
+
+	err := NewPhotoPipeline(db, store, 1).Process(context.Background(), photo)
+	if !errors.Is(err, imaging.ErrNotImage) {
+		t.Fatalf("Process error = %v, want %v", err, imaging.ErrNotImage)
+	}
+
+	var stored models.VehiclePhoto
+	if err := db.First(&stored, "id = ?", photo.ID).Error; err != nil {
+		t.Fatalf("reload photo: %v", err)
+	}
+	if stored.Processing != models.PhotoProcessingFailed {
+		t.Errorf("processing = %q, want %q on the first attempt", stored.Processing, models.PhotoProcessingFailed)
+	}
+	if stored.ProcessingAttempts != 1 || stored.NextProcessingAt != nil {
+		t.Errorf("attempts %d, next processing at %v; want 1 and no retry", stored.ProcessingAttempts, stored.NextProcessingAt)
+	}
+	if stored.ProcessingError == "" {
+		t.Error("processing error is empty")
+	}
+	if keys := store.Keys(); len(keys) != 1 {
+		t.Errorf("stored objects = %v, want only the original", keys)
+	}
+}
+
+func testPhotoDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.VehiclePhoto{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// testPhotoJPEG encodes the test photo and inserts its EXIF block after the
+// start of image marker
+func testPhotoJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, testPhotoWidth, testPhotoHeight))
+	for y := 0; y < testPhotoHeight; y++ {
+		for x := 0; x < testPhotoWidth; x++ {
+			c := testPhotoBlue
+			if x < testPhotoWidth/2 && y < testPhotoHeight/2 {
+				c = testPhotoRed
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("encode test photo: %v", err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), testPhotoTIFF()...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte(nil), data[:2]...), segment...), data[2:]...)
+}
+
+// testPhotoTIFF builds a big-endian TIFF block with IFD0 holding the
+// orientation and a pointer to the GPS IFD
+func testPhotoTIFF() []byte {
+	const (
+		ifd0Offset = 8
+		gpsOffset  = ifd0Offset + 2 + 2*12 + 4
+		latOffset  = gpsOffset + 2 + 4*12 + 4
+		lonOffset  = latOffset + 24
+	)
+
+	var b bytes.Buffer
+	put := func(v interface{}) { binary.Write(&b, binary.BigEndian, v) }
+	entry := func(tag, typ uint16, count, value uint32) {
+		put(tag)
+		put(typ)
+		put(count)
+		put(value)
+	}
+
+	b.WriteString("MM")
+	put(uint16(42))
+	put(uint32(ifd0Offset))
+
+	put(uint16(2))
+	entry(0x0112, 3, 1, 6<<16) // orientation, a short left-aligned in the field
+	entry(0x8825, 4, 1, gpsOffset)
+	put(uint32(0))
+
+	put(uint16(4))
+	entry(0x0001, 2, 2, uint32('S')<<24)
+	entry(0x0002, 5, 3, latOffset)
+	entry(0x0003, 2, 2, uint32('W')<<24)
+	entry(0x0004, 5, 3, lonOffset)
+	put(uint32(0))
+
+	for _, v := range []uint32{33, 1, 26, 1, 24, 1, 70, 1, 39, 1, 0, 1} {
+		put(v)
+	}
+	return b.Bytes()
+}
+
+func assertTestPhotoGPS(t *testing.T, exif *imaging.EXIF) {
+	t.Helper()
+	if exif == nil {
+		t.Fatal("EXIF was not read")
+	}
+	if exif.Orientation != 6 {
+		t.Errorf("orientation = %d, want 6", exif.Orientation)
+	}
+	if exif.GPS == nil {
+		t.Fatal("GPS was not read")
+	}
+	if math.Abs(exif.GPS.Latitude+33.44) > 1e-9 || math.Abs(exif.GPS.Longitude+70.65) > 1e-9 {
+		t.Errorf("GPS = %v, %v; want -33.44, -70.65", exif.GPS.Latitude, exif.GPS.Longitude)
+	}
+}
+
+// nearColor allows for JPEG compression and the averaging at block edges
+func nearColor(c color.Color, want color.NRGBA) bool {
+	r, g, b, _ := c.RGBA()
+	diff := func(got uint32, want uint8) bool {
+		d := int(got>>8) - int(want)
+		return d > -40 && d < 40
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
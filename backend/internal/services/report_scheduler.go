@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/notifications"
+	"github.com/macal/inventory/internal/reports"
+	"github.com/macal/inventory/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	schedulerLeaderKey   = "report_scheduler:leader"
+	schedulerTick        = time.Minute
+	schedulerLeaderTTL   = 3 * schedulerTick
+	reportLinkExpiry     = 7 * 24 * time.Hour // S3 presigned URL maximum
+	reportRetryAfterFail = time.Hour
+)
+
+// Extends the leader lock only while we still hold it
+var renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// Releases the leader lock only while we still hold it
+var releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// ReportScheduler generates scheduled ClientReports. Every replica runs one,
+// but only the replica holding the Redis leader lock generates reports.
+type ReportScheduler struct {
+	db         *gorm.DB
+	redis      *redis.Client
+	storage    storage.Storage
+	notifier   *notifications.Notifier
+	logger     *zap.SugaredLogger
+	instanceID string
+	failures   map[uuid.UUID]time.Time
+}
+
+func NewReportScheduler(db *gorm.DB, redis *redis.Client, storage storage.Storage, notifier *notifications.Notifier) *ReportScheduler {
+	logger, _ := zap.NewProduction()
+	return &ReportScheduler{
+		db:         db,
+		redis:      redis,
+		storage:    storage,
+		notifier:   notifier,
+		logger:     logger.Sugar(),
+		instanceID: uuid.New().String(),
+		failures:   make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Run checks for due reports every minute until ctx is cancelled
+func (s *ReportScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+	defer s.releaseLeadership()
+
+	for {
+		if s.acquireLeadership(ctx) {
+			s.runDue(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReportScheduler) acquireLeadership(ctx context.Context) bool {
+	ok, err := s.redis.SetNX(ctx, schedulerLeaderKey, s.instanceID, schedulerLeaderTTL).Result()
+	if err != nil {
+		s.logger.Errorf("Failed to acquire report scheduler leadership: %v", err)
+		return false
+	}
+	if ok {
+		s.logger.Infof("Report scheduler leadership acquired by %s", s.instanceID)
+		return true
+	}
+
+	renewed, err := renewLeaderScript.Run(ctx, s.redis, []string{schedulerLeaderKey}, s.instanceID, schedulerLeaderTTL.Milliseconds()).Int()
+	if err != nil {
+		s.logger.Errorf("Failed to renew report scheduler leadership: %v", err)
+		return false
+	}
+	return renewed == 1
+}
+
+func (s *ReportScheduler) releaseLeadership() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	releaseLeaderScript.Run(ctx, s.redis, []string{schedulerLeaderKey}, s.instanceID)
+}
+
+// runDue generates every report whose next run is in the past. After
+// downtime an overdue report runs once, covering the whole missed window,
+// and its cadence restarts from that run.
+func (s *ReportScheduler) runDue(ctx context.Context) {
+	var due []models.ClientReport
+	err := s.db.WithContext(ctx).
+		Where("active = ? AND schedule IN ?", true, []string{models.ReportScheduleDaily, models.ReportScheduleWeekly, models.ReportScheduleMonthly}).
+		Find(&due).Error
+	if err != nil {
+		s.logger.Errorf("Failed to load scheduled reports: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range due {
+		report := &due[i]
+		next, scheduled := report.NextRun()
+		if !scheduled || next.After(now) {
+			continue
+		}
+		if failedAt, ok := s.failures[report.ID]; ok && now.Sub(failedAt) < reportRetryAfterFail {
+			continue
+		}
+
+		if err := s.Generate(ctx, report); err != nil {
+			s.failures[report.ID] = now
+			s.logger.Errorf("Failed to generate scheduled report %s: %v", report.ID, err)
+			continue
+		}
+		delete(s.failures, report.ID)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Generate builds a report, stores the artifact and notifies the client
+// organization with a signed download link
+func (s *ReportScheduler) Generate(ctx context.Context, report *models.ClientReport) error {
+	var org models.ClientOrganization
+	if err := s.db.WithContext(ctx).First(&org, "id = ?", report.OrganizationID).Error; err != nil {
+		return err
+	}
+	if !org.IsValid() {
+		return fmt.Errorf("client organization %s is inactive or expired", org.ID)
+	}
+
+	cfg, err := reports.ParseConfig(report.Config)
+	if err != nil {
+		return err
+	}
+	generator, err := reports.New(s.db, &org, report.Type, cfg.Options)
+	if err != nil {
+		return err
+	}
+
+	generatedAt := time.Now()
+	key := fmt.Sprintf("client-reports/%s/%s/%s_%s", org.ID, report.ID, generatedAt.Format("20060102-150405"), generator.Filename(cfg.Format))
+
+	// Stream rows straight into storage rather than holding the file in
+	// memory. A failed write fails the upload and a failed upload stops the
+	// write, so no partial report is stored.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(generator.Write(ctx, cfg.Format, pw))
+	}()
+	_, err = s.storage.UploadStream(ctx, key, pr, cfg.Format.ContentType())
+	pr.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+
+	expiry := org.LinkExpiry(reportLinkExpiry)
+	link, err := s.storage.PresignedURL(ctx, key, expiry)
+	if err != nil {
+		return err
+	}
+
+	// Only advance LastGenerated if nobody else did in the meantime, so a
+	// leadership change mid-run cannot produce a second notification
+	update := s.db.WithContext(ctx).Model(&models.ClientReport{}).Where("id = ?", report.ID)
+	if report.LastGenerated == nil {
+		update = update.Where("last_generated IS NULL")
+	} else {
+		update = update.Where("last_generated = ?", *report.LastGenerated)
+	}
+	result := update.Update("last_generated", generatedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.logger.Warnf("Report %s was generated concurrently, discarding %s", report.ID, key)
+		s.storage.Delete(ctx, key)
+		return nil
+	}
+	report.LastGenerated = &generatedAt
+
+	notification := models.ClientNotification{
+		Type:    models.NotificationReportReady,
+		Title:   fmt.Sprintf("Reporte disponible: %s", report.Name),
+		Message: fmt.Sprintf("El reporte \"%s\" fue generado el %s.", report.Name, generatedAt.Format("02/01/2006 15:04")),
+		Data: models.JSONB{
+			"report_id":  report.ID,
+			"format":     cfg.Format,
+			"url":        link,
+			"expires_at": generatedAt.Add(expiry),
+		},
+	}
+	return s.notifier.Notify(ctx, &org, &notification)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedPredefinedTemplates upserts the embedded template categories and
+// predefined templates, keyed by slug and ID, so it is safe to run on every
+// start and on several replicas at once. Templates no longer shipped are
+// removed from the catalogue; FormTemplates installed from them are kept.
+func SeedPredefinedTemplates(db *gorm.DB) (int, error) {
+	templates, categories, err := models.LoadPredefined()
+	if err != nil {
+		return 0, fmt.Errorf("invalid predefined templates: %w", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(categories) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "slug"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name", "description", "icon", "order"}),
+			}).Create(&categories).Error
+			if err != nil {
+				return fmt.Errorf("seed categories: %w", err)
+			}
+		}
+
+		ids := make([]string, 0, len(templates))
+		for i := range templates {
+			ids = append(ids, templates[i].ID)
+		}
+		if len(templates) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name", "description", "category", "icon", "config", "tags", "order", "updated_at"}),
+			}).Create(&templates).Error
+			if err != nil {
+				return fmt.Errorf("seed templates: %w", err)
+			}
+		}
+
+		query := tx.Model(&models.PredefinedTemplate{})
+		if len(ids) > 0 {
+			query = query.Where("id NOT IN ?", ids)
+		} else {
+			query = query.Where("1 = 1")
+		}
+		return query.Delete(&models.PredefinedTemplate{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(templates), nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"gorm.io/gorm"
+)
+
+// Timeline page sizes
+const (
+	DefaultTimelineLimit = 100
+	MaxTimelineLimit     = 500
+)
+
+// TimelineQuery selects a page of a vehicle's timeline
+type TimelineQuery struct {
+	Before time.Time // only events strictly older; zero for the newest
+	Limit  int
+
+	// Client restricts the timeline to what the organization's permissions
+	// allow: inspections and photos need their permission, access events are
+	// limited to its own, and staff identities and internal reasons are left
+	// out. Nil for staff.
+	Client *models.ClientOrganization
+}
+
+// clientInspectionActions are the lifecycle actions shown to clients; review
+// rejections and reopenings stay internal
+var clientInspectionActions = []string{
+	models.InspectionActionStart,
+	models.InspectionActionComplete,
+	models.InspectionActionApprove,
+}
+
+// VehicleTimeline assembles the chronological history of a vehicle
+type VehicleTimeline struct {
+	db *gorm.DB
+}
+
+func NewVehicleTimeline(db *gorm.DB) *VehicleTimeline {
+	return &VehicleTimeline{db: db}
+}
+
+// Events returns a page of the vehicle's timeline, newest first. Each source
+// is read up to the page size before merging, so a page is always complete.
+func (t *VehicleTimeline) Events(ctx context.Context, vehicle *models.Vehicle, q TimelineQuery) ([]models.TimelineEvent, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultTimelineLimit
+	}
+	if q.Limit > MaxTimelineLimit {
+		q.Limit = MaxTimelineLimit
+	}
+	client := q.Client
+	db := t.db.WithContext(ctx)
+
+	// page restricts a source to the events of this page
+	page := func(column string) *gorm.DB {
+		query := db
+		if !q.Before.IsZero() {
+			query = query.Where(column+" < ?", q.Before)
+		}
+		return query.Order(column + " DESC").Limit(q.Limit)
+	}
+	inPage := func(at time.Time) bool {
+		return !at.IsZero() && (q.Before.IsZero() || at.Before(q.Before))
+	}
+
+	var events []models.TimelineEvent
+
+	if inPage(vehicle.CheckInDate) {
+		events = append(events, models.TimelineEvent{
+			Type:       models.TimelineVehicleCheckedIn,
+			OccurredAt: vehicle.CheckInDate,
+			Title:      "Ingreso del vehículo",
+		})
+	}
+	if vehicle.CheckOutDate != nil && inPage(*vehicle.CheckOutDate) && (client == nil || !client.Permissions.HidesField("checkOutDate")) {
+		events = append(events, models.TimelineEvent{
+			Type:       models.TimelineVehicleCheckedOut,
+			OccurredAt: *vehicle.CheckOutDate,
+			Title:      "Salida del vehículo",
+		})
+	}
+
+	var history []models.VehicleStatusHistory
+	if err := page("created_at").Where("vehicle_id = ?", vehicle.ID).Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to load status history: %w", err)
+	}
+	for _, change := range history {
+		event := models.TimelineEvent{
+			Type:         models.TimelineStatusChanged,
+			OccurredAt:   change.CreatedAt,
+			Title:        fmt.Sprintf("Estado cambiado de %s a %s", change.FromStatus.Label(), change.ToStatus.Label()),
+			ActorID:      change.ChangedByID,
+			InspectionID: change.InspectionID,
+			Data: map[string]interface{}{
+				"from":    change.FromStatus,
+				"to":      change.ToStatus,
+				"trigger": change.Trigger,
+			},
+		}
+		if change.Reason != "" {
+			event.Data["reason"] = change.Reason
+		}
+		events = append(events, event)
+	}
+
+	// Inspections of a vehicle are few; all of them are needed to find their
+	// transitions and access events
+	var inspections []models.Inspection
+	err := db.Select("id", "type", "inspector_id", "created_at").
+		Where("vehicle_id = ?", vehicle.ID).
+		Find(&inspections).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load inspections: %w", err)
+	}
+	inspectionIDs := make([]uuid.UUID, len(inspections))
+	inspectionTypes := make(map[uuid.UUID]models.InspectionType, len(inspections))
+	for i, inspection := range inspections {
+		inspectionIDs[i] = inspection.ID
+		inspectionTypes[inspection.ID] = inspection.Type
+	}
+
+	if len(inspections) > 0 && (client == nil || client.Permissions.CanViewInspections) {
+		for i := range inspections {
+			inspection := &inspections[i]
+			if !inPage(inspection.CreatedAt) {
+				continue
+			}
+			events = append(events, models.TimelineEvent{
+				Type:         models.TimelineInspectionCreated,
+				OccurredAt:   inspection.CreatedAt,
+				Title:        fmt.Sprintf("Inspección de %s creada", inspectionTypeLabel(inspection.Type)),
+				ActorID:      &inspection.InspectorID,
+				InspectionID: &inspection.ID,
+				Data:         map[string]interface{}{"inspection_type": inspection.Type},
+			})
+		}
+
+		query := page("created_at").Where("inspection_id IN ?", inspectionIDs)
+		if client != nil {
+			query = query.Where("action IN ?", clientInspectionActions)
+		}
+		var transitions []models.InspectionTransition
+		if err := query.Find(&transitions).Error; err != nil {
+			return nil, fmt.Errorf("failed to load inspection transitions: %w", err)
+		}
+		for i := range transitions {
+			transition := &transitions[i]
+			inspectionType := inspectionTypes[transition.InspectionID]
+			event := models.TimelineEvent{
+				Type:         "inspection_" + inspectionActionEvents[transition.Action],
+				OccurredAt:   transition.CreatedAt,
+				Title:        fmt.Sprintf("Inspección de %s: %s", inspectionTypeLabel(inspectionType), inspectionStatusLabel(transition.ToStatus)),
+				ActorID:      &transition.ActorID,
+				InspectionID: &transition.InspectionID,
+				Data: map[string]interface{}{
+					"inspection_type": inspectionType,
+					"from":            transition.FromStatus,
+					"to":              transition.ToStatus,
+				},
+			}
+			if transition.Reason != "" {
+				event.Data["reason"] = transition.Reason
+			}
+			events = append(events, event)
+		}
+	}
+
+	if client == nil || client.Permissions.CanViewPhotos {
+		var photos []models.VehiclePhoto
+		if err := page("uploaded_at").Where("vehicle_id = ?", vehicle.ID).Find(&photos).Error; err != nil {
+			return nil, fmt.Errorf("failed to load photos: %w", err)
+		}
+		for i := range photos {
+			photo := &photos[i]
+			// Clients only see processed renditions, never the original
+			url := photo.URL
+			if client != nil {
+				if !photo.ClientVisible() || (photo.InspectionID != nil && !client.Permissions.CanViewInspections) {
+					continue
+				}
+				url = photo.Display
+			}
+			event := models.TimelineEvent{
+				Type:       models.TimelinePhotoUploaded,
+				OccurredAt: photo.UploadedAt,
+				Title:      "Foto agregada",
+				Data: map[string]interface{}{
+					"photo_id":  photo.ID,
+					"category":  photo.Category,
+					"url":       url,
+					"thumbnail": photo.Thumbnail,
+				},
+			}
+			if photo.InspectionID != nil {
+				event.InspectionID = photo.InspectionID
+			}
+			if photo.UploadedBy != uuid.Nil {
+				event.ActorID = &photo.UploadedBy
+			}
+			events = append(events, event)
+		}
+	}
+
+	query := page("created_at").Preload("Organization")
+	if len(inspectionIDs) > 0 {
+		ids := make([]string, len(inspectionIDs))
+		for i, id := range inspectionIDs {
+			ids[i] = id.String()
+		}
+		query = query.Where("(resource_type = ? AND resource_id = ?) OR (resource_type = ? AND resource_id IN ?)",
+			"vehicle", vehicle.ID.String(), "inspection", ids)
+	} else {
+		query = query.Where("resource_type = ? AND resource_id = ?", "vehicle", vehicle.ID.String())
+	}
+	if client != nil {
+		query = query.Where("organization_id = ?", client.ID)
+	}
+	var accesses []models.ClientAccessLog
+	if err := query.Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to load client access logs: %w", err)
+	}
+	for i := range accesses {
+		access := &accesses[i]
+		organization := ""
+		if access.Organization != nil {
+			organization = access.Organization.Name
+		}
+		event := models.TimelineEvent{
+			Type:       models.TimelineClientAccess,
+			OccurredAt: access.CreatedAt,
+			Title:      fmt.Sprintf("Consultado por %s", organization),
+			Data: map[string]interface{}{
+				"organization_id": access.OrganizationID,
+				"organization":    organization,
+				"action":          access.Action,
+			},
+		}
+		if access.ResourceType == "inspection" {
+			if id, err := uuid.Parse(access.ResourceID); err == nil {
+				event.InspectionID = &id
+			}
+		}
+		if client == nil {
+			event.Data["ip_address"] = access.IPAddress
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].OccurredAt.After(events[j].OccurredAt)
+	})
+	if len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+
+	if client != nil {
+		for i := range events {
+			events[i].ActorID = nil
+			delete(events[i].Data, "reason")
+		}
+		return events, nil
+	}
+	if err := t.resolveActors(ctx, events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// resolveActors fills in the names of the users behind the events
+func (t *VehicleTimeline) resolveActors(ctx context.Context, events []models.TimelineEvent) error {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, event := range events {
+		if event.ActorID != nil && !seen[*event.ActorID] {
+			seen[*event.ActorID] = true
+			ids = append(ids, *event.ActorID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := t.db.WithContext(ctx).Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	for i := range events {
+		if events[i].ActorID != nil {
+			events[i].ActorName = names[*events[i].ActorID]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/notifications"
+	"github.com/macal/inventory/internal/realtime"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidVehicleTransition is returned for status changes the vehicle's
+// current status does not allow
+var ErrInvalidVehicleTransition = errors.New("invalid vehicle status transition")
+
+// deliveryTemplateType is the FormTemplate.Type of delivery checklists: the
+// category of the predefined template they are installed from
+const deliveryTemplateType = "delivery"
+
+// VehicleWorkflow moves vehicles between statuses, by hand or in reaction to
+// their inspections, and records every change. Client notifications and
+// webhooks are sent once the change commits.
+type VehicleWorkflow struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	notifier *notifications.Notifier
+	logger   *zap.SugaredLogger
+}
+
+// VehicleStatusChange describes a requested status change
+type VehicleStatusChange struct {
+	To           models.VehicleStatus
+	Trigger      string
+	Reason       string
+	InspectionID *uuid.UUID
+	ChangedByID  *uuid.UUID
+}
+
+func NewVehicleWorkflow(db *gorm.DB, redis *redis.Client, notifier *notifications.Notifier) *VehicleWorkflow {
+	logger, _ := zap.NewProduction()
+	return &VehicleWorkflow{
+		db:       db,
+		redis:    redis,
+		notifier: notifier,
+		logger:   logger.Sugar(),
+	}
+}
+
+// Transition moves a vehicle to change.To and records it in the status
+// history. Moving a vehicle to the status it already has changes nothing.
+func (w *VehicleWorkflow) Transition(ctx context.Context, vehicleID uuid.UUID, change VehicleStatusChange) (*models.Vehicle, error) {
+	var (
+		vehicle models.Vehicle
+		from    models.VehicleStatus
+		changed bool
+	)
+
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vehicle, "id = ?", vehicleID).Error
+		if err != nil {
+			return err
+		}
+		from = vehicle.Status
+		if from == change.To {
+			return nil
+		}
+		if !from.CanTransitionTo(change.To) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidVehicleTransition, from, change.To)
+		}
+
+		err = tx.Create(&models.VehicleStatusHistory{
+			VehicleID:    vehicleID,
+			FromStatus:   from,
+			ToStatus:     change.To,
+			Trigger:      change.Trigger,
+			Reason:       change.Reason,
+			InspectionID: change.InspectionID,
+			ChangedByID:  change.ChangedByID,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": change.To}
+		if change.To == models.VehicleStatusDelivered {
+			updates["check_out_date"] = time.Now()
+		}
+		if err := tx.Model(&vehicle).Updates(updates).Error; err != nil {
+			return err
+		}
+		vehicle.Status = change.To
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The notifier's update callback skips changes made inside a
+	// transaction, they are only visible now
+	if changed && w.notifier != nil {
+		notified := vehicle
+		go w.notifier.VehicleStatusChanged(context.Background(), &notified, from)
+	}
+
+	if changed && change.InspectionID != nil {
+		// Live viewers of the inspection see the vehicle react
+		var updatedBy uuid.UUID
+		if change.ChangedByID != nil {
+			updatedBy = *change.ChangedByID
+		}
+		update := &models.InspectionUpdate{
+			InspectionID: *change.InspectionID,
+			Path:         "vehicle.status",
+			Value:        change.To,
+			UpdatedBy:    updatedBy,
+			Timestamp:    time.Now(),
+			Type:         "vehicle_status_changed",
+			Metadata: map[string]interface{}{
+				"vehicle_id": vehicleID,
+				"from":       from,
+				"trigger":    change.Trigger,
+			},
+		}
+		if err := realtime.AppendUpdate(ctx, w.redis, update).Err(); err != nil {
+			w.logger.Errorf("Failed to publish status change of vehicle %s: %v", vehicleID, err)
+		}
+	}
+	if changed {
+		w.logger.Infof("Vehicle %s moved from %s to %s (%s)", vehicleID, from, change.To, change.Trigger)
+	}
+	return &vehicle, nil
+}
+
+// History returns the status changes of a vehicle, newest first
+func (w *VehicleWorkflow) History(ctx context.Context, vehicleID uuid.UUID) ([]models.VehicleStatusHistory, error) {
+	var history []models.VehicleStatusHistory
+	err := w.db.WithContext(ctx).Preload("ChangedBy").
+		Where("vehicle_id = ?", vehicleID).
+		Order("created_at DESC").
+		Find(&history).Error
+	return history, err
+}
+
+// InspectionEdited reacts to a live edit: a failed item sends the vehicle to
+// repair and signing a delivery checklist delivers it
+func (w *VehicleWorkflow) InspectionEdited(ctx context.Context, inspection *models.Inspection, update *models.InspectionUpdate) {
+	segments := strings.Split(update.Path, ".")
+	switch {
+	case len(segments) == 5 && segments[0] == "sections" && segments[2] == "items" && segments[4] == "status":
+		if status, _ := update.Value.(string); status == string(models.ItemStatusFail) {
+			w.react(ctx, inspection, update.UpdatedBy, models.VehicleStatusRepairing, models.VehicleTriggerFailedItem,
+				fmt.Sprintf("%s failed", strings.Join(segments[1:4:4], ".")))
+		}
+
+	case update.Path == "signature":
+		if signature, _ := update.Value.(string); strings.TrimSpace(signature) != "" && w.isDeliveryChecklist(ctx, inspection) {
+			w.react(ctx, inspection, update.UpdatedBy, models.VehicleStatusDelivered, models.VehicleTriggerDeliverySigned, "")
+		}
+	}
+}
+
+// InspectionTransitioned reacts to a lifecycle action of an inspection
+func (w *VehicleWorkflow) InspectionTransitioned(ctx context.Context, inspection *models.Inspection, action string, actorID uuid.UUID) {
+	switch action {
+	case models.InspectionActionStart:
+		if inspection.Type == models.InspectionTypeEntry {
+			w.react(ctx, inspection, actorID, models.VehicleStatusInspecting, models.VehicleTriggerEntryStarted, "")
+		}
+
+	case models.InspectionActionComplete:
+		if inspection.HasFailedItems() {
+			w.react(ctx, inspection, actorID, models.VehicleStatusRepairing, models.VehicleTriggerFailedItem, "")
+		}
+		if strings.TrimSpace(inspection.Signature) != "" && w.isDeliveryChecklist(ctx, inspection) {
+			w.react(ctx, inspection, actorID, models.VehicleStatusDelivered, models.VehicleTriggerDeliverySigned, "")
+		}
+
+	case models.InspectionActionApprove:
+		if inspection.Type == models.InspectionTypeExit {
+			w.react(ctx, inspection, actorID, models.VehicleStatusCompleted, models.VehicleTriggerExitApproved, "")
+		}
+	}
+}
+
+// react applies an automatic status change. Changes the vehicle's status
+// does not allow are skipped, never failing the inspection that caused them.
+func (w *VehicleWorkflow) react(ctx context.Context, inspection *models.Inspection, actorID uuid.UUID, to models.VehicleStatus, trigger, reason string) {
+	change := VehicleStatusChange{
+		To:           to,
+		Trigger:      trigger,
+		Reason:       reason,
+		InspectionID: &inspection.ID,
+	}
+	if actorID != uuid.Nil {
+		change.ChangedByID = &actorID
+	}
+
+	_, err := w.Transition(ctx, inspection.VehicleID, change)
+	switch {
+	case errors.Is(err, ErrInvalidVehicleTransition):
+		w.logger.Infof("Vehicle %s not moved by inspection %s: %v", inspection.VehicleID, inspection.ID, err)
+	case err != nil:
+		w.logger.Errorf("Failed to move vehicle %s after inspection %s: %v", inspection.VehicleID, inspection.ID, err)
+	}
+}
+
+func (w *VehicleWorkflow) isDeliveryChecklist(ctx context.Context, inspection *models.Inspection) bool {
+	if inspection.FormTemplateID == nil {
+		return false
+	}
+	var template models.FormTemplate
+	err := w.db.WithContext(ctx).Unscoped().Select("type").First(&template, "id = ?", *inspection.FormTemplateID).Error
+	return err == nil && template.Type == deliveryTemplateType
+}
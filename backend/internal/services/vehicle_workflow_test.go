@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/macal/inventory/internal/models"
+	"github.com/macal/inventory/internal/notifications"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestVehicleWorkflowTransitionNotifiesAfterCommit(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// One connection, so the notifier's goroutines share the database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	err = db.AutoMigrate(&models.Vehicle{}, &models.VehicleStatusHistory{}, &models.ClientOrganization{}, &models.ClientNotification{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	org := models.ClientOrganization{
+		Name:        "Banco Uno",
+		Active:      true,
+		Permissions: models.ClientPermissions{CanViewVehicles: true},
+	}
+	vehicle := models.Vehicle{LicensePlate: "ABC123", VIN: "VIN123"}
+	if err := db.Create(&org).Error; err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	if err := db.Create(&vehicle).Error; err != nil {
+		t.Fatalf("create vehicle: %v", err)
+	}
+
+	notifier := notifications.NewNotifier(db)
+	if err := notifier.RegisterCallbacks(db); err != nil {
+		t.Fatalf("register callbacks: %v", err)
+	}
+	workflow := NewVehicleWorkflow(db, nil, notifier)
+
+	moved, err := workflow.Transition(context.Background(), vehicle.ID, VehicleStatusChange{
+		To:      models.VehicleStatusInspecting,
+		Trigger: models.VehicleTriggerManual,
+	})
+	if err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	if moved.Status != models.VehicleStatusInspecting {
+		t.Errorf("status = %q, want %q", moved.Status, models.VehicleStatusInspecting)
+	}
+
+	var inbox []models.ClientNotification
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if db.Where("organization_id = ?", org.ID).Find(&inbox); len(inbox) > 0 {
+			break
+		}
+	}
+	// Give a second notification, from the update callback, the chance to
+	// arrive
+	time.Sleep(100 * time.Millisecond)
+	db.Where("organization_id = ?", org.ID).Find(&inbox)
+	if len(inbox) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(inbox))
+	}
+	if inbox[0].Data["previous_status"] != string(models.VehicleStatusPending) ||
+		inbox[0].Data["status"] != string(models.VehicleStatusInspecting) {
+		t.Errorf("notification data = %v, want pending to inspecting", inbox[0].Data)
+	}
+
+	var history []models.VehicleStatusHistory
+	db.Find(&history, "vehicle_id = ?", vehicle.ID)
+	if len(history) != 1 || history[0].FromStatus != models.VehicleStatusPending || history[0].ToStatus != models.VehicleStatusInspecting {
+		t.Errorf("history = %+v, want one pending to inspecting entry", history)
+	}
+
+	_, err = workflow.Transition(context.Background(), vehicle.ID, VehicleStatusChange{
+		To:      models.VehicleStatusDelivered,
+		Trigger: models.VehicleTriggerManual,
+	})
+	if !errors.Is(err, ErrInvalidVehicleTransition) {
+		t.Errorf("inspecting to delivered: err = %v, want %v", err, ErrInvalidVehicleTransition)
+	}
+}
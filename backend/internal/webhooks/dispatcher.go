@@ -0,0 +1,393 @@
+// Package webhooks pushes client events to the HTTPS endpoints registered in
+// WebhookSubscriptions. Deliveries are queued in the database, signed with
+// HMAC-SHA256, retried with exponential backoff and moved to a dead-letter
+// table once retries are exhausted.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollInterval   = 5 * time.Second
+	claimBatchSize = 50
+	claimLease     = 2 * time.Minute // a crashed worker's claims become due again, renewed before each post
+	requestTimeout = 10 * time.Second
+	maxAttempts    = 10
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = 6 * time.Hour
+	maxErrorLength = 500
+)
+
+var (
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrSubscriptionInactive = errors.New("webhook subscription is inactive")
+)
+
+// notificationEvents maps client notification types to webhook events
+var notificationEvents = map[string]string{
+	models.NotificationVehicleAdded:         models.WebhookEventVehicleAdded,
+	models.NotificationVehicleStatusChanged: models.WebhookEventVehicleStatusChanged,
+	models.NotificationInspectionCompleted:  models.WebhookEventInspectionCompleted,
+	models.NotificationReportReady:          models.WebhookEventReportReady,
+}
+
+// Dispatcher queues and delivers webhook events. It is a notification
+// channel, so every ClientNotification with a matching event is queued for
+// the organization's subscriptions.
+type Dispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+	logger *zap.SugaredLogger
+}
+
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	logger, _ := zap.NewProduction()
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: requestTimeout},
+		logger: logger.Sugar(),
+	}
+}
+
+func (d *Dispatcher) Name() string {
+	return "webhook"
+}
+
+func (d *Dispatcher) Enabled(org *models.ClientOrganization, n *models.ClientNotification) bool {
+	_, ok := notificationEvents[n.Type]
+	return ok
+}
+
+// Send queues a delivery of the notification's event for every active
+// subscription of org that wants it
+func (d *Dispatcher) Send(ctx context.Context, org *models.ClientOrganization, n *models.ClientNotification) error {
+	eventType := notificationEvents[n.Type]
+
+	var subscriptions []models.WebhookSubscription
+	if err := d.db.WithContext(ctx).Where("organization_id = ? AND active = ?", org.ID, true).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	var payload models.JSONB
+	for i := range subscriptions {
+		if !subscriptions[i].Subscribes(eventType) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = d.buildPayload(ctx, org, n, eventType); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscriptions[i].ID,
+			OrganizationID: org.ID,
+			EventID:        n.ID,
+			EventType:      eventType,
+			Payload:        payload,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return d.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+// buildPayload wraps the notification in the event envelope. Vehicle data
+// goes through ClientPermissions.VehicleView so webhooks never expose more
+// than the portal does.
+func (d *Dispatcher) buildPayload(ctx context.Context, org *models.ClientOrganization, n *models.ClientNotification, eventType string) (models.JSONB, error) {
+	data := models.JSONB{
+		"title":   n.Title,
+		"message": n.Message,
+	}
+	for key, value := range n.Data {
+		data[key] = value
+	}
+
+	if vehicleID, ok := n.Data["vehicle_id"]; ok {
+		query := d.db.WithContext(ctx)
+		if org.Permissions.CanViewOwnerInfo {
+			query = query.Preload("Owner")
+		}
+		if org.Permissions.CanViewPhotos {
+			query = query.Preload("Photos")
+		}
+
+		var vehicle models.Vehicle
+		if err := query.First(&vehicle, "id = ?", vehicleID).Error; err != nil {
+			return nil, err
+		}
+		// Deliveries are stored and replayed long after signed links expire,
+		// so photos are sent by id for the receiver to fetch from the portal
+		data["vehicle"] = org.Permissions.VehicleView(&vehicle, func(string) string { return "" })
+		delete(data, "license_plate")
+	}
+
+	return models.JSONB{
+		"id":              n.ID,
+		"type":            eventType,
+		"created_at":      n.SentAt,
+		"organization_id": org.ID,
+		"data":            data,
+	}, nil
+}
+
+// Run delivers due webhooks until ctx is cancelled. Deliveries are claimed
+// with SKIP LOCKED, so every replica can run a dispatcher.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back
+		if d.processDue(ctx) == claimBatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processDue claims and attempts one batch of due deliveries, returning how
+// many were claimed
+func (d *Dispatcher) processDue(ctx context.Context) int {
+	var due []models.WebhookDelivery
+	// Postgres keeps microseconds, so the lease reads back equal in renewClaim
+	lease := time.Now().Add(claimLease).Truncate(time.Microsecond)
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(claimBatchSize).
+			Find(&due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+			due[i].NextAttemptAt = lease
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", lease).Error
+	})
+	if err != nil {
+		d.logger.Errorf("Failed to claim webhook deliveries: %v", err)
+		return 0
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		d.attempt(ctx, &due[i])
+	}
+	return len(due)
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	if !d.renewClaim(ctx, delivery) {
+		return
+	}
+
+	var subscription models.WebhookSubscription
+	if err := d.db.WithContext(ctx).First(&subscription, "id = ?", delivery.SubscriptionID).Error; err != nil || !subscription.Active {
+		d.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+			"status":     models.WebhookDeliveryCancelled,
+			"last_error": "subscription removed or disabled",
+		})
+		return
+	}
+
+	statusCode, err := d.post(ctx, &subscription, delivery)
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+
+	if err == nil {
+		now := time.Now()
+		d.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+			"status":           models.WebhookDeliveryDelivered,
+			"attempts":         delivery.Attempts,
+			"last_status_code": statusCode,
+			"last_error":       "",
+			"delivered_at":     now,
+		})
+		return
+	}
+
+	delivery.LastError = truncate(err.Error(), maxErrorLength)
+	if delivery.Attempts >= maxAttempts {
+		d.deadLetter(ctx, delivery)
+		return
+	}
+
+	d.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+		"attempts":         delivery.Attempts,
+		"last_status_code": statusCode,
+		"last_error":       delivery.LastError,
+		"next_attempt_at":  time.Now().Add(backoff(delivery.Attempts)),
+	})
+}
+
+// renewClaim extends the lease on a claimed delivery before it is posted,
+// since a batch can take longer than claimLease. It reports false when the
+// delivery is no longer pending or its lease expired and another worker
+// claimed it, so the webhook is not sent twice.
+func (d *Dispatcher) renewClaim(ctx context.Context, delivery *models.WebhookDelivery) bool {
+	lease := time.Now().Add(claimLease).Truncate(time.Microsecond)
+	result := d.db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.WebhookDeliveryPending, delivery.NextAttemptAt).
+		Update("next_attempt_at", lease)
+	if result.Error != nil {
+		d.logger.Errorf("Failed to renew claim on webhook delivery %s: %v", delivery.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	delivery.NextAttemptAt = lease
+	return true
+}
+
+func (d *Dispatcher) post(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MACAL-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderEventID, delivery.EventID.String())
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderSignature, Sign(subscription.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d: %s", resp.StatusCode, snippet)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, delivery *models.WebhookDelivery) {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(delivery).Updates(map[string]interface{}{
+			"status":           models.WebhookDeliveryDead,
+			"attempts":         delivery.Attempts,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.WebhookDeadLetter{
+			DeliveryID:     delivery.ID,
+			SubscriptionID: delivery.SubscriptionID,
+			OrganizationID: delivery.OrganizationID,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+			Attempts:       delivery.Attempts,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+		}).Error
+	})
+	if err != nil {
+		d.logger.Errorf("Failed to dead-letter webhook delivery %s: %v", delivery.ID, err)
+		return
+	}
+	d.logger.Warnf("Webhook delivery %s dead-lettered after %d attempts: %s", delivery.ID, delivery.Attempts, delivery.LastError)
+}
+
+// Replay queues a fresh copy of a delivery of organizationID. The copy keeps
+// the event ID and payload, so receivers can deduplicate.
+func (d *Dispatcher) Replay(ctx context.Context, organizationID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := d.db.WithContext(ctx).First(&original, "id = ? AND organization_id = ?", deliveryID, organizationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	var subscription models.WebhookSubscription
+	if err := d.db.WithContext(ctx).First(&subscription, "id = ?", original.SubscriptionID).Error; err != nil || !subscription.Active {
+		return nil, ErrSubscriptionInactive
+	}
+
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		OrganizationID: original.OrganizationID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		ReplayOf:       &original.ID,
+	}
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&replay).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WebhookDeadLetter{}).
+			Where("delivery_id = ? AND replayed_at IS NULL", original.ID).
+			Update("replayed_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &replay, nil
+}
+
+// backoff returns the delay before the next attempt, doubling from
+// baseBackoff with up to 20% jitter
+func backoff(attempts int) time.Duration {
+	delay := maxBackoff
+	if attempts < 20 {
+		if d := baseBackoff << (attempts - 1); d < maxBackoff {
+			delay = d
+		}
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/macal/inventory/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dispatcherTest wires a Dispatcher to an in-memory database and a receiver
+// that counts the webhooks posted to it
+type dispatcherTest struct {
+	db           *gorm.DB
+	dispatcher   *Dispatcher
+	subscription *models.WebhookSubscription
+	posts        atomic.Int32
+}
+
+func newDispatcherTest(t *testing.T) *dispatcherTest {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.WebhookDeadLetter{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	dt := &dispatcherTest{db: db}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dt.posts.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	dt.dispatcher = &Dispatcher{db: db, client: receiver.Client(), logger: zap.NewNop().Sugar()}
+	dt.subscription = &models.WebhookSubscription{OrganizationID: uuid.New(), URL: receiver.URL, Active: true}
+	if err := db.Create(dt.subscription).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	return dt
+}
+
+func (dt *dispatcherTest) queue(t *testing.T) *models.WebhookDelivery {
+	t.Helper()
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: dt.subscription.ID,
+		OrganizationID: dt.subscription.OrganizationID,
+		EventID:        uuid.New(),
+		EventType:      models.WebhookEventVehicleAdded,
+		Payload:        models.JSONB{"type": models.WebhookEventVehicleAdded},
+		NextAttemptAt:  time.Now().Add(-time.Second),
+	}
+	if err := dt.db.Create(delivery).Error; err != nil {
+		t.Fatalf("queue delivery: %v", err)
+	}
+	return delivery
+}
+
+func (dt *dispatcherTest) status(t *testing.T, delivery *models.WebhookDelivery) models.WebhookDeliveryStatus {
+	t.Helper()
+	var stored models.WebhookDelivery
+	if err := dt.db.First(&stored, "id = ?", delivery.ID).Error; err != nil {
+		t.Fatalf("load delivery: %v", err)
+	}
+	return stored.Status
+}
+
+func TestProcessDueDeliversEachWebhookOnce(t *testing.T) {
+	dt := newDispatcherTest(t)
+	first, second := dt.queue(t), dt.queue(t)
+
+	if claimed := dt.dispatcher.processDue(context.Background()); claimed != 2 {
+		t.Fatalf("claimed %d deliveries, want 2", claimed)
+	}
+	if posts := dt.posts.Load(); posts != 2 {
+		t.Errorf("receiver got %d posts, want 2", posts)
+	}
+	for _, delivery := range []*models.WebhookDelivery{first, second} {
+		if status := dt.status(t, delivery); status != models.WebhookDeliveryDelivered {
+			t.Errorf("delivery status = %q, want delivered", status)
+		}
+	}
+
+	if claimed := dt.dispatcher.processDue(context.Background()); claimed != 0 {
+		t.Errorf("claimed %d deliveries again, want none", claimed)
+	}
+}
+
+func TestAttemptSkipsDeliveryClaimedElsewhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		takeOver func(db *gorm.DB, delivery *models.WebhookDelivery) error
+	}{
+		{
+			name: "lease expired and reclaimed",
+			takeOver: func(db *gorm.DB, delivery *models.WebhookDelivery) error {
+				return db.Model(delivery).Update("next_attempt_at", time.Now().Add(claimLease)).Error
+			},
+		},
+		{
+			name: "delivered by another worker",
+			takeOver: func(db *gorm.DB, delivery *models.WebhookDelivery) error {
+				return db.Model(delivery).Update("status", models.WebhookDeliveryDelivered).Error
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt := newDispatcherTest(t)
+			dt.queue(t)
+
+			// Load the delivery as this worker claimed it, then let another
+			// worker take it over before this one gets to post it
+			var due []models.WebhookDelivery
+			dt.db.Find(&due)
+			stale := due[0]
+			if err := tt.takeOver(dt.db, &models.WebhookDelivery{ID: stale.ID}); err != nil {
+				t.Fatalf("take delivery over: %v", err)
+			}
+
+			dt.dispatcher.attempt(context.Background(), &stale)
+			if posts := dt.posts.Load(); posts != 0 {
+				t.Errorf("receiver got %d posts, want the delivery left to the other worker", posts)
+			}
+		})
+	}
+}
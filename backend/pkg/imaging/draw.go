@@ -0,0 +1,238 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+// Canvas is an editable copy of an image that shapes and labels are drawn
+// onto. Edges are antialiased by how much of each pixel a shape covers.
+type Canvas struct {
+	img *image.NRGBA
+}
+
+// NewCanvas copies img so drawing never alters the original
+func NewCanvas(img image.Image) *Canvas {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return &Canvas{img: dst}
+}
+
+// Image returns the drawn image
+func (c *Canvas) Image() image.Image {
+	return c.img
+}
+
+// Line strokes a segment width pixels wide with round ends
+func (c *Canvas) Line(x1, y1, x2, y2, width float64, col color.NRGBA) {
+	half := width / 2
+	c.cover(math.Min(x1, x2)-half, math.Min(y1, y2)-half, math.Max(x1, x2)+half, math.Max(y1, y2)+half, col, func(px, py float64) float64 {
+		return half - segmentDistance(px, py, x1, y1, x2, y2)
+	})
+}
+
+// Arrow strokes a line from the tail to the head, with a head head pixels
+// long
+func (c *Canvas) Arrow(x1, y1, x2, y2, width, head float64, col color.NRGBA) {
+	c.Line(x1, y1, x2, y2, width, col)
+
+	angle := math.Atan2(y1-y2, x1-x2)
+	for _, side := range []float64{-1, 1} {
+		a := angle + side*math.Pi/7
+		c.Line(x2, y2, x2+head*math.Cos(a), y2+head*math.Sin(a), width, col)
+	}
+}
+
+// Circle strokes a circle centred on cx, cy
+func (c *Canvas) Circle(cx, cy, radius, width float64, col color.NRGBA) {
+	half := width / 2
+	outer := radius + half
+	c.cover(cx-outer, cy-outer, cx+outer, cy+outer, col, func(px, py float64) float64 {
+		return half - math.Abs(math.Hypot(px-cx, py-cy)-radius)
+	})
+}
+
+// Rect strokes the outline of a rectangle
+func (c *Canvas) Rect(x, y, w, h, width float64, col color.NRGBA) {
+	c.Line(x, y, x+w, y, width, col)
+	c.Line(x+w, y, x+w, y+h, width, col)
+	c.Line(x+w, y+h, x, y+h, width, col)
+	c.Line(x, y+h, x, y, width, col)
+}
+
+// FillRect fills a rectangle; a translucent col tints what is below
+func (c *Canvas) FillRect(x, y, w, h float64, col color.NRGBA) {
+	c.cover(x, y, x+w, y+h, col, func(px, py float64) float64 {
+		return math.Min(math.Min(px-x, x+w-px), math.Min(py-y, y+h-py))
+	})
+}
+
+// Label writes text in upper case with the built-in 5x7 font, glyphs size
+// pixels tall, on a background box whose top left corner is at x, y. The
+// box is moved inside the image when it would overflow.
+func (c *Canvas) Label(x, y float64, text string, size float64, fg, bg color.NRGBA) {
+	text = strings.ToUpper(text)
+	dot := math.Max(1, math.Round(size/glyphHeight))
+	pad := dot * 2
+	runes := []rune(text)
+	w := float64(len(runes))*glyphAdvance*dot - dot + 2*pad
+	h := glyphHeight*dot + 2*pad
+
+	bounds := c.img.Rect
+	x = math.Max(0, math.Min(x, float64(bounds.Dx())-w))
+	y = math.Max(0, math.Min(y, float64(bounds.Dy())-h))
+	c.FillRect(x, y, w, h, bg)
+
+	for i, r := range runes {
+		glyph := glyphFor(r)
+		gx := x + pad + float64(i)*glyphAdvance*dot
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) != 0 {
+					c.FillRect(gx+float64(col)*dot, y+pad+float64(row)*dot, dot, dot, fg)
+				}
+			}
+		}
+	}
+}
+
+// cover blends col into the pixels of a box, weighted by coverage: the
+// signed distance in pixels from each pixel centre to the shape's edge,
+// positive inside
+func (c *Canvas) cover(minX, minY, maxX, maxY float64, col color.NRGBA, coverage func(px, py float64) float64) {
+	bounds := c.img.Rect
+	x0 := max(bounds.Min.X, int(math.Floor(minX)))
+	y0 := max(bounds.Min.Y, int(math.Floor(minY)))
+	x1 := min(bounds.Max.X, int(math.Ceil(maxX))+1)
+	y1 := min(bounds.Max.Y, int(math.Ceil(maxY))+1)
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			alpha := math.Min(1, coverage(float64(x)+0.5, float64(y)+0.5)+0.5)
+			if alpha > 0 {
+				c.blend(x, y, col, alpha)
+			}
+		}
+	}
+}
+
+// blend composites col with the given coverage over the pixel at x, y
+func (c *Canvas) blend(x, y int, col color.NRGBA, coverage float64) {
+	i := c.img.PixOffset(x, y)
+	pix := c.img.Pix[i : i+4 : i+4]
+
+	srcA := float64(col.A) / 255 * coverage
+	dstA := float64(pix[3]) / 255
+	outA := srcA + dstA*(1-srcA)
+	if outA == 0 {
+		return
+	}
+	for ch, src := range []uint8{col.R, col.G, col.B} {
+		value := (float64(src)*srcA + float64(pix[ch])*dstA*(1-srcA)) / outA
+		pix[ch] = uint8(math.Round(value))
+	}
+	pix[3] = uint8(math.Round(outA * 255))
+}
+
+// segmentDistance is the distance from p to the segment a-b
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	length := dx*dx + dy*dy
+	t := 0.0
+	if length > 0 {
+		t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/length))
+	}
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// Built-in font metrics, in dots
+const (
+	glyphWidth   = 5
+	glyphHeight  = 7
+	glyphAdvance = glyphWidth + 1
+)
+
+// glyphAccents maps the accented letters of Spanish text to their base
+// glyph
+var glyphAccents = map[rune]rune{
+	'Á': 'A', 'É': 'E', 'Í': 'I', 'Ó': 'O', 'Ú': 'U', 'Ü': 'U', 'Ñ': 'N',
+}
+
+// glyphs are 5x7 bitmaps, one byte per row with the leftmost dot in bit 4
+var glyphs = map[rune][glyphHeight]uint8{
+	' ':  {},
+	'A':  {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B':  {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C':  {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D':  {0x1E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1E},
+	'E':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G':  {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H':  {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I':  {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M':  {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P':  {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q':  {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R':  {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S':  {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T':  {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X':  {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'0':  {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1':  {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3':  {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4':  {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5':  {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6':  {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9':  {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	':':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	';':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08},
+	'-':  {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'=':  {0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'?':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'¿':  {0x04, 0x00, 0x04, 0x08, 0x10, 0x11, 0x0E},
+	'¡':  {0x04, 0x00, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'\'': {0x0C, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'"':  {0x0A, 0x0A, 0x0A, 0x00, 0x00, 0x00, 0x00},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'#':  {0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A},
+	'%':  {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'&':  {0x0C, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0D},
+	'*':  {0x00, 0x04, 0x15, 0x0E, 0x15, 0x04, 0x00},
+	'<':  {0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02},
+	'>':  {0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08},
+	'_':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+}
+
+// glyphFor returns the bitmap of an upper-case rune, falling back to '?'
+func glyphFor(r rune) [glyphHeight]uint8 {
+	if base, ok := glyphAccents[r]; ok {
+		r = base
+	}
+	if glyph, ok := glyphs[r]; ok {
+		return glyph
+	}
+	return glyphs['?']
+}
@@ -0,0 +1,314 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// EXIF holds the fields read from a photo's EXIF block
+type EXIF struct {
+	Orientation int    `json:"orientation,omitempty"` // 1-8, 0 when absent
+	CapturedAt  string `json:"captured_at,omitempty"` // ISO 8601, with the offset when the camera recorded one
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Software    string `json:"software,omitempty"`
+	GPS         *GPS   `json:"gps,omitempty"`
+}
+
+// GPS is the location a photo was taken at
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"` // meters above sea level
+}
+
+// EXIF and TIFF tags read by ReadEXIF
+const (
+	tagMake               = 0x010f
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagSoftware           = 0x0131
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+	tagGPSAltitudeRef     = 0x0005
+	tagGPSAltitude        = 0x0006
+)
+
+// TIFF field types
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]int{
+	typeByte: 1, typeASCII: 1, typeShort: 2, typeLong: 4,
+	typeRational: 8, typeUndefined: 1, typeSLong: 4, typeSRational: 8,
+}
+
+// maxIFDEntries bounds the entries read from one directory of a malformed file
+const maxIFDEntries = 512
+
+// ReadEXIF extracts the EXIF block of a JPEG. It returns nil when the image
+// has none; malformed fields are skipped rather than failing the photo.
+func ReadEXIF(data []byte) *EXIF {
+	tiff := jpegEXIFSegment(data)
+	if tiff == nil {
+		return nil
+	}
+
+	r, ifd0, ok := newTIFFReader(tiff)
+	if !ok {
+		return nil
+	}
+	entries := r.directory(ifd0)
+	if entries == nil {
+		return nil
+	}
+
+	exif := &EXIF{
+		Make:     r.ascii(entries[tagMake]),
+		Model:    r.ascii(entries[tagModel]),
+		Software: r.ascii(entries[tagSoftware]),
+	}
+	if orientation, ok := r.uint(entries[tagOrientation]); ok && orientation >= 1 && orientation <= 8 {
+		exif.Orientation = int(orientation)
+	}
+
+	captured := r.ascii(entries[tagDateTime])
+	offset := ""
+	if pointer, ok := r.uint(entries[tagExifIFD]); ok {
+		sub := r.directory(pointer)
+		if original := r.ascii(sub[tagDateTimeOriginal]); original != "" {
+			captured = original
+			offset = r.ascii(sub[tagOffsetTimeOriginal])
+		}
+	}
+	exif.CapturedAt = exifTime(captured, offset)
+
+	if pointer, ok := r.uint(entries[tagGPSIFD]); ok {
+		exif.GPS = r.gps(r.directory(pointer))
+	}
+
+	if *exif == (EXIF{}) {
+		return nil
+	}
+	return exif
+}
+
+// jpegEXIFSegment returns the TIFF payload of the APP1 Exif segment
+func jpegEXIFSegment(data []byte) []byte {
+	if !bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff:
+			i++ // fill byte
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			i += 2 // standalone markers
+			continue
+		case marker == 0xda || marker == 0xd9:
+			return nil // image data starts, metadata is over
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[i+4 : end]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i = end
+	}
+	return nil
+}
+
+// tiffEntry is one field of an image file directory
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // the field's bytes, resolved from its offset when needed
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTIFFReader(data []byte) (*tiffReader, uint32, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return nil, 0, false
+	}
+	return r, r.order.Uint32(data[4:]), true
+}
+
+// directory reads the entries of the IFD at offset, keyed by tag
+func (r *tiffReader) directory(offset uint32) map[uint16]tiffEntry {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return nil
+	}
+	count := int(r.order.Uint16(r.data[offset:]))
+	if count > maxIFDEntries {
+		count = maxIFDEntries
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for n := 0; n < count; n++ {
+		pos := int(offset) + 2 + n*12
+		if pos+12 > len(r.data) {
+			break
+		}
+		tag := r.order.Uint16(r.data[pos:])
+		typ := r.order.Uint16(r.data[pos+2:])
+		fieldCount := r.order.Uint32(r.data[pos+4:])
+
+		size, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+		length := uint64(size) * uint64(fieldCount)
+		var value []byte
+		if length <= 4 {
+			value = r.data[pos+8 : pos+8+int(length)]
+		} else {
+			start := uint64(r.order.Uint32(r.data[pos+8:]))
+			if start+length > uint64(len(r.data)) {
+				continue
+			}
+			value = r.data[start : start+length]
+		}
+		entries[tag] = tiffEntry{typ: typ, count: fieldCount, value: value}
+	}
+	return entries
+}
+
+func (r *tiffReader) ascii(e tiffEntry) string {
+	if e.typ != typeASCII {
+		return ""
+	}
+	s := string(e.value)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// uint reads the first value of a byte, short or long field
+func (r *tiffReader) uint(e tiffEntry) (uint32, bool) {
+	if e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case typeByte, typeUndefined:
+		return uint32(e.value[0]), true
+	case typeShort:
+		return uint32(r.order.Uint16(e.value)), true
+	case typeLong:
+		return r.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// rationals reads an unsigned rational field
+func (r *tiffReader) rationals(e tiffEntry) ([]float64, bool) {
+	if e.typ != typeRational || e.count == 0 {
+		return nil, false
+	}
+	values := make([]float64, e.count)
+	for i := range values {
+		num := r.order.Uint32(e.value[i*8:])
+		den := r.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return nil, false
+		}
+		values[i] = float64(num) / float64(den)
+	}
+	return values, true
+}
+
+func (r *tiffReader) gps(entries map[uint16]tiffEntry) *GPS {
+	lat, ok := r.coordinate(entries[tagGPSLatitude], r.ascii(entries[tagGPSLatitudeRef]), "S")
+	if !ok {
+		return nil
+	}
+	lon, ok := r.coordinate(entries[tagGPSLongitude], r.ascii(entries[tagGPSLongitudeRef]), "W")
+	if !ok {
+		return nil
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+
+	gps := &GPS{Latitude: lat, Longitude: lon}
+	if altitude, ok := r.rationals(entries[tagGPSAltitude]); ok {
+		alt := altitude[0]
+		if ref, ok := r.uint(entries[tagGPSAltitudeRef]); ok && ref == 1 {
+			alt = -alt // below sea level
+		}
+		gps.Altitude = &alt
+	}
+	return gps
+}
+
+// coordinate converts degrees, minutes and seconds to signed decimal degrees
+func (r *tiffReader) coordinate(e tiffEntry, ref, negative string) (float64, bool) {
+	dms, ok := r.rationals(e)
+	if !ok || len(dms) < 3 {
+		return 0, false
+	}
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+	if ref == negative {
+		value = -value
+	}
+	return value, true
+}
+
+// exifTime converts an EXIF "2006:01:02 15:04:05" timestamp to ISO 8601.
+// Cameras rarely record the offset, in which case the local time is kept
+// without one rather than guessing a zone.
+func exifTime(value, offset string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return ""
+	}
+	if zone, err := time.Parse("-07:00", offset); err == nil {
+		_, seconds := zone.Zone()
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0,
+			time.FixedZone("", seconds)).Format(time.RFC3339)
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,135 @@
+// Package imaging sniffs, decodes and transforms uploaded photos using only
+// the standard library decoders
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	// Register decoders used by Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+var (
+	// ErrNotImage is returned for data that is not an image
+	ErrNotImage = errors.New("not an image")
+	// ErrUnsupportedFormat is returned for images that cannot be decoded,
+	// such as HEIC or WebP
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrCorrupt is returned for images that fail to decode
+	ErrCorrupt = errors.New("corrupt image")
+	// ErrTooLarge is returned for images above MaxPixels
+	ErrTooLarge = errors.New("image too large")
+)
+
+// MaxPixels bounds the decoded size of an image, so a small file cannot
+// expand into gigabytes of pixels
+const MaxPixels = 60_000_000
+
+// Format is the real type of an image, whatever its file name says
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatGIF  Format = "gif"
+	FormatWebP Format = "webp"
+	FormatHEIC Format = "heic"
+)
+
+var formatContentTypes = map[Format]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatGIF:  "image/gif",
+	FormatWebP: "image/webp",
+	FormatHEIC: "image/heic",
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	return formatContentTypes[f]
+}
+
+// Extension returns the file extension of the format, without the dot
+func (f Format) Extension() string {
+	if f == FormatJPEG {
+		return "jpg"
+	}
+	return string(f)
+}
+
+// Decodable reports whether Decode supports the format
+func (f Format) Decodable() bool {
+	return f == FormatJPEG || f == FormatPNG || f == FormatGIF
+}
+
+// heifBrands are the ISO BMFF brands of HEIF still images
+var heifBrands = []string{"heic", "heix", "hevc", "heim", "heis", "mif1", "msf1"}
+
+// Sniff identifies an image from its leading bytes
+func Sniff(data []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, nil
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		for _, brand := range heifBrands {
+			if string(data[8:12]) == brand {
+				return FormatHEIC, nil
+			}
+		}
+	}
+	return "", ErrNotImage
+}
+
+// Decode sniffs and decodes an image, refusing formats the standard library
+// cannot read and images above MaxPixels
+func Decode(data []byte) (image.Image, Format, error) {
+	format, err := Sniff(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if !format.Decodable() {
+		return nil, format, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, format, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, format, fmt.Errorf("%w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, format, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return img, format, nil
+}
+
+// EncodeJPEG encodes img as a JPEG, compositing transparent pixels over
+// white. The output carries no metadata.
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Orient applies an EXIF orientation (1-8) so the image displays upright
+// without it. Other values return img unchanged.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // 5-8 swap the axes
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
+
+// Fit scales img down so neither side exceeds maxSide, averaging the source
+// pixels each output pixel covers. Images already small enough are returned
+// unchanged; images are never enlarged.
+func Fit(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	dw, dh := maxSide, maxSide
+	if w >= h {
+		dh = max(1, (h*maxSide+w/2)/w)
+	} else {
+		dw = max(1, (w*maxSide+h/2)/h)
+	}
+	return resize(toNRGBA(img), dw, dh)
+}
+
+// contribution is the share of one source pixel in an output pixel
+type contribution struct {
+	index  int
+	weight float32
+}
+
+// areaWeights maps every output index to the source pixels it covers when
+// scaling src pixels down to dst
+func areaWeights(src, dst int) [][]contribution {
+	scale := float64(src) / float64(dst)
+	weights := make([][]contribution, dst)
+	for i := range weights {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < src && float64(j) < end; j++ {
+			lo, hi := max(start, float64(j)), min(end, float64(j+1))
+			if hi > lo {
+				weights[i] = append(weights[i], contribution{index: j, weight: float32((hi - lo) / scale)})
+			}
+		}
+	}
+	return weights
+}
+
+// resize scales separably, first the rows then the columns. Colors are
+// averaged premultiplied so transparent pixels do not bleed into edges.
+func resize(src *image.NRGBA, dw, dh int) *image.NRGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+
+	// Horizontal pass into premultiplied float rows
+	xWeights := areaWeights(sw, dw)
+	tmp := make([]float32, dw*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, contributions := range xWeights {
+			var r, g, b, a float32
+			for _, c := range contributions {
+				p := row[c.index*4:]
+				alpha := float32(p[3]) * c.weight
+				r += float32(p[0]) * alpha
+				g += float32(p[1]) * alpha
+				b += float32(p[2]) * alpha
+				a += alpha
+			}
+			t := tmp[(y*dw+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	// Vertical pass, back to straight alpha
+	yWeights := areaWeights(sh, dh)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y, contributions := range yWeights {
+		for x := 0; x < dw; x++ {
+			var r, g, b, a float32
+			for _, c := range contributions {
+				t := tmp[(c.index*dw+x)*4:]
+				r += t[0] * c.weight
+				g += t[1] * c.weight
+				b += t[2] * c.weight
+				a += t[3] * c.weight
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			if a > 0 {
+				p[0], p[1], p[2] = clamp(r/a), clamp(g/a), clamp(b/a)
+			}
+			p[3] = clamp(a)
+		}
+	}
+	return dst
+}
+
+func clamp(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// toNRGBA returns img as an NRGBA image with its origin at zero
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) {
+		return n
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
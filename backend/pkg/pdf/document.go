@@ -0,0 +1,333 @@
+// Package pdf is a small PDF 1.4 writer built on the standard library. It
+// supports the standard Helvetica fonts, filled/stroked shapes and JPEG or
+// raster images, and streams every finished page straight to the underlying
+// writer so long reports never have to be held in memory.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Page sizes in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Color is an RGB colour
+type Color struct {
+	R, G, B uint8
+}
+
+var (
+	Black = Color{0, 0, 0}
+	White = Color{255, 255, 255}
+)
+
+// PaintStyle controls how shapes are painted
+type PaintStyle string
+
+const (
+	Stroke     PaintStyle = "S"
+	Fill       PaintStyle = "f"
+	FillStroke PaintStyle = "B"
+)
+
+const (
+	catalogID = 1
+	pagesID   = 2
+)
+
+// Document writes a PDF to an io.Writer. Pages are flushed as soon as the
+// next one is started, so callers must not keep references to old pages.
+type Document struct {
+	w       *countingWriter
+	width   float64
+	height  float64
+	offsets map[int]int64
+	nextID  int
+	fontIDs map[Font]int
+	pageIDs []int
+	page    *Page
+	title   string
+	err     error
+	closed  bool
+}
+
+// New starts a document whose pages are width x height points
+func New(w io.Writer, width, height float64) *Document {
+	d := &Document{
+		w:       &countingWriter{w: w},
+		width:   width,
+		height:  height,
+		offsets: make(map[int]int64),
+		nextID:  pagesID + 1,
+		fontIDs: make(map[Font]int),
+	}
+
+	d.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	for _, font := range fonts {
+		id := d.newObject()
+		d.fontIDs[font] = id
+		d.writeObject(id, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", font))
+	}
+
+	return d
+}
+
+// SetTitle sets the document title shown by PDF readers
+func (d *Document) SetTitle(title string) {
+	d.title = title
+}
+
+// Width returns the page width in points
+func (d *Document) Width() float64 {
+	return d.width
+}
+
+// Height returns the page height in points
+func (d *Document) Height() float64 {
+	return d.height
+}
+
+// PageCount returns the number of pages started so far
+func (d *Document) PageCount() int {
+	n := len(d.pageIDs)
+	if d.page != nil {
+		n++
+	}
+	return n
+}
+
+// AddPage flushes the current page and starts a new one
+func (d *Document) AddPage() *Page {
+	d.flushPage()
+	d.page = &Page{
+		doc:    d,
+		images: make(map[string]int),
+		font:   Helvetica,
+		size:   10,
+	}
+	return d.page
+}
+
+// Close finishes the document. It must be called exactly once.
+func (d *Document) Close() error {
+	if d.closed {
+		return d.err
+	}
+	d.closed = true
+
+	if d.page == nil && len(d.pageIDs) == 0 {
+		d.AddPage()
+	}
+	d.flushPage()
+
+	kids := make([]string, len(d.pageIDs))
+	for i, id := range d.pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	d.writeObject(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	d.writeObject(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	infoID := d.newObject()
+	info := fmt.Sprintf("/Producer %s /CreationDate %s", literal("MACAL Inventario"), literal(time.Now().Format("D:20060102150405")))
+	if d.title != "" {
+		info += " /Title " + literal(d.title)
+	}
+	d.writeObject(infoID, "<< "+info+" >>")
+
+	xrefOffset := d.w.n
+	d.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", d.nextID))
+	for id := 1; id < d.nextID; id++ {
+		d.write(fmt.Sprintf("%010d 00000 n \n", d.offsets[id]))
+	}
+	d.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", d.nextID, catalogID, infoID, xrefOffset))
+
+	return d.err
+}
+
+func (d *Document) flushPage() {
+	page := d.page
+	if page == nil {
+		return
+	}
+	d.page = nil
+
+	contentID := d.newObject()
+	d.writeStream(contentID, "", page.content.Bytes())
+
+	var fontRefs strings.Builder
+	for i, font := range fonts {
+		fmt.Fprintf(&fontRefs, "/F%d %d 0 R ", i+1, d.fontIDs[font])
+	}
+	var imageRefs strings.Builder
+	for name, id := range page.images {
+		fmt.Fprintf(&imageRefs, "/%s %d 0 R ", name, id)
+	}
+
+	pageID := d.newObject()
+	d.writeObject(pageID, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> /XObject << %s>> >> /Contents %d 0 R >>",
+		pagesID, num(d.width), num(d.height), fontRefs.String(), imageRefs.String(), contentID,
+	))
+	d.pageIDs = append(d.pageIDs, pageID)
+}
+
+func (d *Document) newObject() int {
+	id := d.nextID
+	d.nextID++
+	return id
+}
+
+func (d *Document) writeObject(id int, body string) {
+	d.offsets[id] = d.w.n
+	d.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", id, body))
+}
+
+// writeStream writes a Flate compressed stream object. dict holds any extra
+// dictionary entries besides /Length and /Filter.
+func (d *Document) writeStream(id int, dict string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	d.writeRawStream(id, dict+" /Filter /FlateDecode", compressed.Bytes())
+}
+
+func (d *Document) writeRawStream(id int, dict string, data []byte) {
+	d.offsets[id] = d.w.n
+	d.write(fmt.Sprintf("%d 0 obj\n<< %s /Length %d >>\nstream\n", id, strings.TrimSpace(dict), len(data)))
+	d.writeBytes(data)
+	d.write("\nendstream\nendobj\n")
+}
+
+func (d *Document) write(s string) {
+	d.writeBytes([]byte(s))
+}
+
+func (d *Document) writeBytes(b []byte) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = d.w.Write(b)
+}
+
+// Page is a single page being drawn. Coordinates are in points with the
+// origin at the top-left corner, y growing downwards.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+	images  map[string]int
+	font    Font
+	size    float64
+}
+
+// SetFont selects the font used by subsequent Text calls
+func (p *Page) SetFont(font Font, size float64) {
+	p.font = font
+	p.size = size
+}
+
+// SetFillColor sets the colour used for text and filled shapes
+func (p *Page) SetFillColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", channel(c.R), channel(c.G), channel(c.B))
+}
+
+// SetStrokeColor sets the colour used for lines and outlines
+func (p *Page) SetStrokeColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s RG\n", channel(c.R), channel(c.G), channel(c.B))
+}
+
+// SetLineWidth sets the width of lines and outlines in points
+func (p *Page) SetLineWidth(w float64) {
+	fmt.Fprintf(&p.content, "%s w\n", num(w))
+}
+
+// Text draws s with its baseline at (x, y)
+func (p *Page) Text(x, y float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td %s Tj ET\n",
+		p.fontIndex(), num(p.size), num(x), num(p.doc.height-y), literal(s))
+}
+
+// TextRight draws s so that it ends at x
+func (p *Page) TextRight(x, y float64, s string) {
+	p.Text(x-TextWidth(p.font, p.size, s), y, s)
+}
+
+// TextWidth measures s with the current font
+func (p *Page) TextWidth(s string) float64 {
+	return TextWidth(p.font, p.size, s)
+}
+
+// Rect draws a rectangle whose top-left corner is (x, y)
+func (p *Page) Rect(x, y, w, h float64, style PaintStyle) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re %s\n", num(x), num(p.doc.height-y-h), num(w), num(h), style)
+}
+
+// Line draws a straight line between two points
+func (p *Page) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "%s %s m %s %s l S\n", num(x1), num(p.doc.height-y1), num(x2), num(p.doc.height-y2))
+}
+
+// DrawImage places img in the box whose top-left corner is (x, y)
+func (p *Page) DrawImage(img *Image, x, y, w, h float64) {
+	p.images[img.name] = img.id
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(p.doc.height-y-h), img.name)
+}
+
+func (p *Page) fontIndex() int {
+	for i, font := range fonts {
+		if font == p.font {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// literal encodes s as a PDF string literal
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range encodeWinAnsi(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func channel(v uint8) string {
+	return num(float64(v) / 255)
+}
@@ -0,0 +1,161 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Font is one of the standard Type1 fonts every PDF reader ships with
+type Font string
+
+const (
+	Helvetica        Font = "Helvetica"
+	HelveticaBold    Font = "Helvetica-Bold"
+	HelveticaOblique Font = "Helvetica-Oblique"
+)
+
+var fonts = []Font{Helvetica, HelveticaBold, HelveticaOblique}
+
+// Glyph widths for WinAnsi codes 32-126, in 1/1000 of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Latin-1 letters are measured as their unaccented base letter
+var latin1Base = map[rune]byte{
+	'À': 'A', 'Á': 'A', 'Â': 'A', 'Ã': 'A', 'Ä': 'A', 'Å': 'A',
+	'Ç': 'C', 'È': 'E', 'É': 'E', 'Ê': 'E', 'Ë': 'E',
+	'Ì': 'I', 'Í': 'I', 'Î': 'I', 'Ï': 'I', 'Ñ': 'N',
+	'Ò': 'O', 'Ó': 'O', 'Ô': 'O', 'Õ': 'O', 'Ö': 'O',
+	'Ù': 'U', 'Ú': 'U', 'Û': 'U', 'Ü': 'U', 'Ý': 'Y',
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'ç': 'c', 'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ñ': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ý': 'y', 'ÿ': 'y',
+	'¡': '!', '¿': '?', 'º': 'o', 'ª': 'a', '°': 'o',
+}
+
+// Code points 0x80-0x9F of WinAnsiEncoding that differ from Latin-1
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// encodeWinAnsi converts UTF-8 text to the single byte encoding used by the
+// standard fonts. Characters outside WinAnsi (emoji, CJK...) become '?'.
+func encodeWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 32 && r < 127:
+			out = append(out, byte(r))
+		case r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsiSpecials[r]; ok {
+				out = append(out, b)
+			} else if r >= 32 {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+func runeWidth(font Font, r rune) int {
+	table := &helveticaWidths
+	if font == HelveticaBold {
+		table = &helveticaBoldWidths
+	}
+
+	if r >= 32 && r < 127 {
+		return table[r-32]
+	}
+	if base, ok := latin1Base[r]; ok {
+		return table[base-32]
+	}
+	switch r {
+	case '—':
+		return 1000
+	case '–', '€':
+		return 556
+	case '•':
+		return 350
+	}
+	return 556
+}
+
+// TextWidth returns the width in points of s rendered with font at size
+func TextWidth(font Font, size float64, s string) float64 {
+	total := 0
+	for _, r := range s {
+		total += runeWidth(font, r)
+	}
+	return float64(total) * size / 1000
+}
+
+// WrapText splits s into lines no wider than width points. Words longer than
+// a full line are broken at character boundaries.
+func WrapText(font Font, size float64, s string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			for TextWidth(font, size, word) > width {
+				cut := fitRunes(font, size, word, width)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func fitRunes(font Font, size float64, s string, width float64) int {
+	w := 0.0
+	for i, r := range s {
+		w += float64(runeWidth(font, r)) * size / 1000
+		if w > width {
+			if i == 0 {
+				// Always make progress, even if a single glyph does not fit
+				_, n := utf8.DecodeRuneInString(s)
+				return n
+			}
+			return i
+		}
+	}
+	return len(s)
+}
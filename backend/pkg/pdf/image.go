@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// Register decoders used by AddImageData
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Image is an image object embedded in the document. It can be drawn on any
+// number of pages.
+type Image struct {
+	id     int
+	name   string
+	Width  int
+	Height int
+}
+
+// AddJPEG embeds JPEG data as-is, without re-encoding
+func (d *Document) AddJPEG(data []byte) (*Image, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	colorSpace := "/DeviceRGB"
+	extra := ""
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		colorSpace = "/DeviceGray"
+	case color.CMYKModel:
+		// Adobe writes inverted CMYK JPEGs, which is what phones and
+		// scanners produce in practice
+		colorSpace = "/DeviceCMYK"
+		extra = " /Decode [1 0 1 0 1 0 1 0]"
+	}
+
+	img := d.newImage(cfg.Width, cfg.Height)
+	d.writeRawStream(img.id, fmt.Sprintf(
+		"/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8%s /Filter /DCTDecode",
+		cfg.Width, cfg.Height, colorSpace, extra,
+	), data)
+
+	return img, d.err
+}
+
+// AddImage embeds a decoded image as Flate compressed RGB
+func (d *Document) AddImage(src image.Image) (*Image, error) {
+	bounds := src.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := src.At(x, y).RGBA()
+			// Composite transparent pixels over white
+			r = (r*a + 0xffff*(0xffff-a)) / 0xffff
+			g = (g*a + 0xffff*(0xffff-a)) / 0xffff
+			b = (b*a + 0xffff*(0xffff-a)) / 0xffff
+			pixels = append(pixels, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+	}
+
+	img := d.newImage(bounds.Dx(), bounds.Dy())
+	d.writeStream(img.id, fmt.Sprintf(
+		"/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+		bounds.Dx(), bounds.Dy(),
+	), pixels)
+
+	return img, d.err
+}
+
+// AddImageData embeds encoded image bytes. JPEGs are passed through, any
+// other supported format (PNG, GIF) is decoded first.
+func (d *Document) AddImageData(data []byte) (*Image, error) {
+	if bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return d.AddJPEG(data)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return d.AddImage(src)
+}
+
+func (d *Document) newImage(width, height int) *Image {
+	id := d.newObject()
+	return &Image{
+		id:     id,
+		name:   fmt.Sprintf("Im%d", id),
+		Width:  width,
+		Height: height,
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const memoryBaseURL = "memory://objects/"
+
+// MemoryStorage keeps objects in memory. It stands in for the object store
+// in tests and local tools; presigned URLs are not served, tests upload
+// through Upload and PutPart instead.
+type MemoryStorage struct {
+	mu         sync.RWMutex
+	objects    map[string][]byte
+	multiparts map[string]*memoryMultipart
+}
+
+type memoryMultipart struct {
+	key   string
+	parts map[int][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects:    make(map[string][]byte),
+		multiparts: make(map[string]*memoryMultipart),
+	}
+}
+
+func (s *MemoryStorage) Upload(ctx context.Context, key string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[key] = append([]byte(nil), data...)
+	return memoryBaseURL + key, nil
+}
+
+func (s *MemoryStorage) UploadStream(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return s.Upload(ctx, key, data)
+}
+
+func (s *MemoryStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	data, err := s.Download(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	info := ObjectInfo{Key: key, Size: int64(len(data)), ContentType: http.DetectContentType(data), ETag: etag(data)}
+	return io.NopCloser(bytes.NewReader(data)), info, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStorage) KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, memoryBaseURL) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, memoryBaseURL), true
+}
+
+func (s *MemoryStorage) PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("%s%s?expires=%d", memoryBaseURL, key, time.Now().Add(expiry).Unix()), nil
+}
+
+func (s *MemoryStorage) PresignedPutURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("%s%s?method=PUT&expires=%d", memoryBaseURL, key, time.Now().Add(expiry).Unix()), nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return ObjectInfo{Key: key, Size: int64(len(data)), ContentType: http.DetectContentType(data), ETag: etag(data)}, nil
+}
+
+func (s *MemoryStorage) DownloadRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	data, err := s.Download(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= int64(len(data)) {
+		return nil, nil
+	}
+	end := offset + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return data[offset:end], nil
+}
+
+func (s *MemoryStorage) ObjectURL(key string) string {
+	return memoryBaseURL + key
+}
+
+func (s *MemoryStorage) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploadID := uuid.New().String()
+	s.multiparts[uploadID] = &memoryMultipart{key: key, parts: make(map[int][]byte)}
+	return uploadID, nil
+}
+
+func (s *MemoryStorage) PresignedPartURL(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	return fmt.Sprintf("%s%s?uploadId=%s&partNumber=%d&expires=%d", memoryBaseURL, key, uploadID, partNumber, time.Now().Add(expiry).Unix()), nil
+}
+
+// PutPart stores one part of a multipart upload, as a client PUT to the
+// presigned part URL would, and returns its ETag
+func (s *MemoryStorage) PutPart(key, uploadID string, partNumber int, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.multiparts[uploadID]
+	if !ok || upload.key != key {
+		return "", fmt.Errorf("%w: upload %s", ErrObjectNotFound, uploadID)
+	}
+	upload.parts[partNumber] = append([]byte(nil), data...)
+	return etag(data), nil
+}
+
+func (s *MemoryStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.multiparts[uploadID]
+	if !ok || upload.key != key {
+		return fmt.Errorf("%w: upload %s", ErrObjectNotFound, uploadID)
+	}
+
+	sorted := append([]CompletedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+	var data []byte
+	for _, part := range sorted {
+		stored, ok := upload.parts[part.Number]
+		if !ok || etag(stored) != part.ETag {
+			return fmt.Errorf("part %d was not uploaded", part.Number)
+		}
+		data = append(data, stored...)
+	}
+
+	s.objects[key] = data
+	delete(s.multiparts, uploadID)
+	return nil
+}
+
+func (s *MemoryStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.multiparts, uploadID)
+	return nil
+}
+
+// Keys returns the stored object keys
+func (s *MemoryStorage) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/macal/inventory/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Storage is the object store used for photos, signatures and reports
+type Storage interface {
+	// Upload stores data under key and returns its public URL
+	Upload(ctx context.Context, key string, data []byte) (string, error)
+	// UploadStream stores everything read from r under key, for objects too
+	// large to hold in memory, and returns its public URL
+	UploadStream(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// Download returns the full contents of the object stored under key
+	Download(ctx context.Context, key string) ([]byte, error)
+	// Open returns a reader over the object stored under key, for streaming
+	// it without holding it in memory
+	Open(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+	// KeyFromURL resolves a URL returned by Upload back to its object key
+	KeyFromURL(url string) (string, bool)
+	// PresignedURL returns a time limited download URL for key
+	PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// streamPartSize is the part size of uploads of unknown length. The client
+// buffers one part at a time.
+const streamPartSize = 16 << 20
+
+// ErrObjectNotFound is returned for keys with no stored object
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ETag        string
+}
+
+// CompletedPart is one uploaded part of a multipart upload
+type CompletedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// DirectUploader is implemented by stores clients can upload to directly
+// through presigned URLs, without the data passing through the API
+type DirectUploader interface {
+	// PresignedPutURL returns a time limited URL accepting a PUT of key
+	PresignedPutURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// Stat describes the object stored under key
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// DownloadRange returns up to length bytes of key starting at offset
+	DownloadRange(ctx context.Context, key string, offset, length int64) ([]byte, error)
+	// ObjectURL returns the URL Upload would have returned for key
+	ObjectURL(key string) string
+
+	// CreateMultipartUpload starts an upload of key sent in parts
+	CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	// PresignedPartURL returns a time limited URL accepting a PUT of one part
+	PresignedPartURL(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error)
+	// CompleteMultipartUpload assembles the uploaded parts into key
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error
+	// AbortMultipartUpload discards an unfinished upload and its parts
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// MinIOStorage implements Storage on top of MinIO or any S3 compatible service
+type MinIOStorage struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+func NewMinIOStorage(cfg config.StorageConfig) (*MinIOStorage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, err
+		}
+	}
+
+	scheme := "http"
+	if cfg.UseSSL {
+		scheme = "https"
+	}
+
+	return &MinIOStorage{
+		client:  client,
+		bucket:  cfg.Bucket,
+		baseURL: fmt.Sprintf("%s://%s/%s/", scheme, cfg.Endpoint, cfg.Bucket),
+	}, nil
+}
+
+func (s *MinIOStorage) Upload(ctx context.Context, key string, data []byte) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: http.DetectContentType(data),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.baseURL + key, nil
+}
+
+func (s *MinIOStorage) UploadStream(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    streamPartSize,
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.baseURL + key, nil
+}
+
+func (s *MinIOStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+func (s *MinIOStorage) Open(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	// GetObject is lazy, Stat surfaces a missing key
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, ObjectInfo{}, err
+	}
+	return object, ObjectInfo{Key: key, Size: info.Size, ContentType: info.ContentType, ETag: info.ETag}, nil
+}
+
+func (s *MinIOStorage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *MinIOStorage) KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.baseURL) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, s.baseURL), true
+}
+
+func (s *MinIOStorage) PresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *MinIOStorage) PresignedPutURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, key, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *MinIOStorage) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ContentType: info.ContentType, ETag: info.ETag}, nil
+}
+
+func (s *MinIOStorage) DownloadRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+func (s *MinIOStorage) ObjectURL(key string) string {
+	return s.baseURL + key
+}
+
+func (s *MinIOStorage) CreateMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	core := minio.Core{Client: s.client}
+	return core.NewMultipartUpload(ctx, s.bucket, key, minio.PutObjectOptions{ContentType: contentType})
+}
+
+func (s *MinIOStorage) PresignedPartURL(ctx context.Context, key, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+	u, err := s.client.Presign(ctx, http.MethodPut, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *MinIOStorage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) error {
+	completed := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		completed[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+	// S3 requires ascending part numbers
+	sort.Slice(completed, func(i, j int) bool { return completed[i].PartNumber < completed[j].PartNumber })
+
+	core := minio.Core{Client: s.client}
+	_, err := core.CompleteMultipartUpload(ctx, s.bucket, key, uploadID, completed, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *MinIOStorage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	core := minio.Core{Client: s.client}
+	return core.AbortMultipartUpload(ctx, s.bucket, key, uploadID)
+}